@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/cartridge"
+)
+
+// runConvertSave converts a battery save between the layouts used by different emulators:
+//
+//	lucky-boy convert-save -rom game.gb [-rtc none|44|48] input.sav output.sav
+func runConvertSave(args []string) error {
+	flags := flag.NewFlagSet("convert-save", flag.ExitOnError)
+	rom := flags.String("rom", "", "ROM the save belongs to")
+	rtc := flags.String("rtc", "48", "RTC footer of the output save (none, 44, 48)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: lucky-boy convert-save -rom game.gb [-rtc none|44|48] input.sav output.sav")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if *rom == "" || flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("ROM, input and output saves are required")
+	}
+	footer, err := cartridge.ParseRTCFooter(*rtc)
+	if err != nil {
+		return err
+	}
+
+	romData, err := os.ReadFile(*rom)
+	if err != nil {
+		return err
+	}
+	savData, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	data, _, err := cartridge.ImportSave(romData, savData)
+	if err != nil {
+		return err
+	}
+	data, err = cartridge.ExportSave(romData, data, footer)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(flags.Arg(1), data, 0644)
+}
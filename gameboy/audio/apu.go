@@ -60,11 +60,20 @@ func (apu *APU) Tick(ticks int) {
 		apu.sampleCounter -= ticksPerSample
 
 		left, right := apu.sample()
-		apu.sampleBuffer <- left
-		apu.sampleBuffer <- right
+		apu.pushSample(left, right)
 	}
 }
 
+// pushSample sends the sample to the buffer without blocking:
+// if nobody is consuming samples (or the buffer is nil) they are dropped
+func (apu *APU) pushSample(left, right float32) {
+	if cap(apu.sampleBuffer)-len(apu.sampleBuffer) < 2 {
+		return
+	}
+	apu.sampleBuffer <- left
+	apu.sampleBuffer <- right
+}
+
 func (apu *APU) sample() (left, right float32) {
 	if !apu.active {
 		return
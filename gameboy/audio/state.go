@@ -0,0 +1,159 @@
+package audio
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+
+func (apu *APU) SaveState(e *savestate.Encoder) {
+	apu.channel1.saveState(e)
+	apu.channel2.saveState(e)
+	apu.channel3.saveState(e)
+	apu.channel4.saveState(e)
+
+	e.U8(apu.nr50)
+	e.U8(apu.nr51)
+	e.Bool(apu.active)
+
+	e.U8(apu.frameSequencer.position)
+	e.Float64(apu.sampleCounter)
+}
+
+func (apu *APU) LoadState(d *savestate.Decoder) {
+	apu.channel1.loadState(d)
+	apu.channel2.loadState(d)
+	apu.channel3.loadState(d)
+	apu.channel4.loadState(d)
+
+	d.U8(&apu.nr50)
+	d.U8(&apu.nr51)
+	d.Bool(&apu.active)
+
+	d.U8(&apu.frameSequencer.position)
+	d.Float64(&apu.sampleCounter)
+}
+
+func (ch *SquareChannel) saveState(e *savestate.Encoder) {
+	e.Bool(ch.dacEnabled)
+	e.Bool(ch.active)
+	ch.sweep.saveState(e)
+	e.U8(ch.waveDuty)
+	ch.LengthTimer.saveState(e)
+	ch.envelope.saveState(e)
+	e.U16(ch.period)
+	e.U16(ch.periodCounter)
+	e.U8(ch.wavePosition)
+	e.Int(ch.ticks)
+}
+
+func (ch *SquareChannel) loadState(d *savestate.Decoder) {
+	d.Bool(&ch.dacEnabled)
+	d.Bool(&ch.active)
+	ch.sweep.loadState(d)
+	d.U8(&ch.waveDuty)
+	ch.LengthTimer.loadState(d)
+	ch.envelope.loadState(d)
+	d.U16(&ch.period)
+	d.U16(&ch.periodCounter)
+	d.U8(&ch.wavePosition)
+	d.Int(&ch.ticks)
+}
+
+func (ch *WaveChannel) saveState(e *savestate.Encoder) {
+	e.Bool(ch.dacEnabled)
+	e.Bool(ch.active)
+	ch.LengthTimer.saveState(e)
+	e.U8(ch.volume)
+	e.U16(ch.period)
+	e.U16(ch.periodCounter)
+	e.U8(ch.wavePosition)
+	e.Bool(ch.justRead)
+	e.Int(ch.triggerCycleDelay)
+	e.Bytes(ch.WaveRam[:])
+	e.U8(ch.bufferSample)
+	e.Int(ch.ticks)
+}
+
+func (ch *WaveChannel) loadState(d *savestate.Decoder) {
+	d.Bool(&ch.dacEnabled)
+	d.Bool(&ch.active)
+	ch.LengthTimer.loadState(d)
+	d.U8(&ch.volume)
+	d.U16(&ch.period)
+	d.U16(&ch.periodCounter)
+	d.U8(&ch.wavePosition)
+	d.Bool(&ch.justRead)
+	d.Int(&ch.triggerCycleDelay)
+	d.BytesInto(ch.WaveRam[:])
+	d.U8(&ch.bufferSample)
+	d.Int(&ch.ticks)
+}
+
+func (ch *NoiseChannel) saveState(e *savestate.Encoder) {
+	e.Bool(ch.dacEnabled)
+	e.Bool(ch.active)
+	e.U16(ch.lfsr)
+	e.U16(ch.frequencyCounter)
+	ch.LengthTimer.saveState(e)
+	ch.envelope.saveState(e)
+	e.U8(ch.clockShift)
+	e.U8(ch.lfsrWidth)
+	e.U8(ch.clockDivider)
+	e.Int(ch.ticks)
+}
+
+func (ch *NoiseChannel) loadState(d *savestate.Decoder) {
+	d.Bool(&ch.dacEnabled)
+	d.Bool(&ch.active)
+	d.U16(&ch.lfsr)
+	d.U16(&ch.frequencyCounter)
+	ch.LengthTimer.loadState(d)
+	ch.envelope.loadState(d)
+	d.U8(&ch.clockShift)
+	d.U8(&ch.lfsrWidth)
+	d.U8(&ch.clockDivider)
+	d.Int(&ch.ticks)
+}
+
+func (lt *LengthTimer) saveState(e *savestate.Encoder) {
+	e.Uint(lt.length)
+	e.Bool(lt.enabled)
+}
+
+func (lt *LengthTimer) loadState(d *savestate.Decoder) {
+	d.Uint(&lt.length)
+	d.Bool(&lt.enabled)
+}
+
+func (env *Envelope) saveState(e *savestate.Encoder) {
+	e.U8(env.volumeInit)
+	e.Bool(env.isIncreasing)
+	e.U8(env.pace)
+	e.U8(env.timer)
+	e.U8(env.volume)
+}
+
+func (env *Envelope) loadState(d *savestate.Decoder) {
+	d.U8(&env.volumeInit)
+	d.Bool(&env.isIncreasing)
+	d.U8(&env.pace)
+	d.U8(&env.timer)
+	d.U8(&env.volume)
+}
+
+func (sw *Sweep) saveState(e *savestate.Encoder) {
+	e.U8(sw.pace)
+	e.Bool(sw.isDecreasing)
+	e.U8(sw.step)
+	e.U8(sw.timer)
+	e.Bool(sw.enabled)
+	e.U16(sw.shadow)
+	e.Bool(sw.negativeFreqCalcPerformed)
+}
+
+func (sw *Sweep) loadState(d *savestate.Decoder) {
+	d.U8(&sw.pace)
+	d.Bool(&sw.isDecreasing)
+	d.U8(&sw.step)
+	d.U8(&sw.timer)
+	d.Bool(&sw.enabled)
+	d.U16(&sw.shadow)
+	d.Bool(&sw.negativeFreqCalcPerformed)
+}
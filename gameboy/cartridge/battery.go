@@ -0,0 +1,27 @@
+package cartridge
+
+// Saver is implemented by cartridges with battery backed memory (RAM, EEPROM or flash).
+// Writes that leave the memory unchanged, and writes to registers mapped in the RAM area
+// (clock, sensors), do not change the save.
+type Saver interface {
+	// SaveChanged reports whether the battery backed memory changed since the last call
+	SaveChanged() bool
+}
+
+type saveTracker struct {
+	dirty bool
+}
+
+func (t *saveTracker) SaveChanged() bool {
+	changed := t.dirty
+	t.dirty = false
+	return changed
+}
+
+// store writes a byte of the battery backed memory, recording whether it changed
+func (t *saveTracker) store(mem []uint8, i uint, value uint8) {
+	if mem[i] != value {
+		mem[i] = value
+		t.dirty = true
+	}
+}
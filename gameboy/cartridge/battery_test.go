@@ -0,0 +1,100 @@
+package cartridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSaveChanged(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func(t *testing.T) Cartridge
+		// registers writes registers mapped in the RAM area, change writes the save
+		registers, change func(c Cartridge)
+	}{
+		{
+			name: "MBC3 RTC",
+			new: func(t *testing.T) Cartridge {
+				return newRTCCartridge(t, nil, &manualClock{now: time.Unix(0, 0)})
+			},
+			registers: func(c Cartridge) {
+				c.Write(0x0000, 0x0A)
+				c.Write(0x4000, 0x08)
+				c.Write(0xA000, 0x12)
+				c.Write(0x4000, 0x0C)
+				c.Write(0xA000, 0x40)
+			},
+			change: func(c Cartridge) {
+				c.Write(0x4000, 0x00)
+				c.Write(0xA000, 0x12)
+			},
+		},
+		{
+			name: "Camera sensor",
+			new: func(t *testing.T) Cartridge {
+				return newTestCamera(t, nil)
+			},
+			registers: func(c Cartridge) {
+				setupSensor(c.(*Camera))
+			},
+			change: func(c Cartridge) {
+				c.Write(0x0000, 0x0A)
+				c.Write(0x4000, 0x00)
+				c.Write(0xA000, 0x12)
+			},
+		},
+		{
+			name: "MBC7 accelerometer",
+			new: func(t *testing.T) Cartridge {
+				return newTestMBC7(t, nil)
+			},
+			registers: func(c Cartridge) {
+				c.Write(0xA000, 0x55)
+				c.Write(0xA010, 0xAA)
+				sendEEPROM(c.(*MBC7), "1"+"10"+"00000101", 16) // READ
+			},
+			change: func(c Cartridge) {
+				sendEEPROM(c.(*MBC7), "1"+"00"+"11000000", 0) // EWEN
+				sendEEPROM(c.(*MBC7), "1"+"01"+"00000101"+"1010101111001101", 0)
+			},
+		},
+		{
+			name: "TAMA5",
+			new: func(t *testing.T) Cartridge {
+				return newTestTAMA5(t, nil)
+			},
+			registers: func(c Cartridge) {
+				tama5Command(c.(*TAMA5), tama5RAMRead, 0x03, 0)
+			},
+			change: func(c Cartridge) {
+				tama5Command(c.(*TAMA5), tama5RAMWrite, 0x03, 0x5A)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := test.new(t)
+			saver := c.(Saver)
+
+			test.registers(c)
+			if saver.SaveChanged() {
+				t.Errorf("save changed writing registers")
+			}
+
+			test.change(c)
+			if !saver.SaveChanged() {
+				t.Errorf("write not detected")
+			}
+			if saver.SaveChanged() {
+				t.Errorf("change reported twice")
+			}
+
+			// Writing the same data again does not change the save
+			test.change(c)
+			if saver.SaveChanged() {
+				t.Errorf("save changed writing the same data")
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package cartridge
+
+import (
+	"image"
+	"math"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+)
+
+const (
+	CameraWidth  = 128
+	CameraHeight = 112
+
+	cameraRAMLen     = 0x20000 // 128 KiB
+	cameraRegsLen    = 0x36
+	cameraImageAddr  = 0x0100 // Captured image in RAM bank 0
+	cameraRegsBank   = 0x10   // Bit 4 of RAM bank number maps the registers
+	cameraDitherAddr = 0x06
+
+	// Exposure time that leaves the source image unchanged
+	cameraExposureNormal = 0x0300
+)
+
+// Edge enhancement ratio selected by bits 4-6 of register A004
+var cameraEdgeRatio = [8]float64{0.50, 0.75, 1.00, 1.25, 2.00, 3.00, 4.00, 5.00}
+
+// Camera is the Game Boy Camera (Pocket Camera) mapper with its M64282FP image sensor.
+//
+// Sensor registers ($A000-$A035 when RAM bank $10 is selected):
+//   - A000: bit 0 starts the capture (reads 1 while busy)
+//   - A001: bit 7 N, bits 6-5 VH (edge enhancement mode), bits 4-0 gain
+//   - A002-A003: exposure time (big endian)
+//   - A004: bits 6-4 edge enhancement ratio, bit 3 invert output, bits 2-0 output reference voltage
+//   - A005: bits 7-6 zero point, bits 5-0 output offset
+//   - A006-A035: 4x4 dithering matrix, 3 thresholds per pixel
+//
+// Output reference voltage, zero point and offset are stored but not emulated.
+type Camera struct {
+	header *Header
+	saveTracker
+
+	ROMBanks uint8
+
+	ROM []uint8
+	RAM []uint8
+
+	// Registers
+	ramEnabled    bool
+	romBankNumber uint8 // 6 bit register
+	ramBankNumber uint8 // 4 bit register, bit 4 selects the sensor registers
+
+	regs [cameraRegsLen]uint8
+
+	// Remaining ticks before the capture is complete
+	captureTicks int
+
+	source ImageSource
+}
+
+func (mbc *Camera) RAMDump() []uint8 {
+	return mbc.RAM
+}
+
+func (mbc *Camera) Header() *Header {
+	return mbc.header
+}
+
+func (mbc *Camera) SaveState(e *savestate.Encoder) {
+	e.Bool(mbc.ramEnabled)
+	e.U8(mbc.romBankNumber)
+	e.U8(mbc.ramBankNumber)
+	e.U8s(mbc.regs[:])
+	e.Int(mbc.captureTicks)
+	e.Bytes(mbc.RAM)
+}
+
+func (mbc *Camera) LoadState(d *savestate.Decoder) {
+	d.Bool(&mbc.ramEnabled)
+	d.U8(&mbc.romBankNumber)
+	d.U8(&mbc.ramBankNumber)
+	d.U8s(mbc.regs[:])
+	d.Int(&mbc.captureTicks)
+	d.BytesInto(mbc.RAM)
+}
+
+func NewCamera(rom []uint8, savData []uint8, header *Header) (*Camera, error) {
+	mbc := &Camera{
+		header:   header,
+		ROMBanks: uint8(header.ROMBanks),
+		ROM:      rom,
+	}
+
+	switch {
+	case savData == nil:
+		savData = make([]uint8, cameraRAMLen)
+	case len(savData) != cameraRAMLen:
+		return nil, &SaveSizeError{Expected: cameraRAMLen, Size: len(savData)}
+	}
+	mbc.RAM = savData
+
+	return mbc, nil
+}
+
+// SetImageSource sets the source of the images seen by the sensor (a test pattern if nil)
+func (mbc *Camera) SetImageSource(source ImageSource) {
+	mbc.source = source
+}
+
+func (mbc *Camera) busy() bool {
+	return mbc.captureTicks > 0
+}
+
+func (mbc *Camera) Tick(ticks int) {
+	if !mbc.busy() {
+		return
+	}
+
+	mbc.captureTicks -= ticks
+	if mbc.captureTicks <= 0 {
+		mbc.captureTicks = 0
+		mbc.regs[0] &^= 1
+		mbc.capture()
+	}
+}
+
+func (mbc *Camera) Write(addr uint16, value uint8) {
+	switch {
+	case addr < 0x2000:
+		mbc.ramEnabled = value&0x0F == 0xA
+
+	case addr < 0x4000:
+		mbc.romBankNumber = value & 0x3F
+
+	case addr < 0x6000:
+		mbc.ramBankNumber = value & 0x1F
+
+	case 0xA000 <= addr && addr < 0xC000:
+		if mbc.ramBankNumber&cameraRegsBank != 0 {
+			mbc.writeRegister(addr&0x7F, value)
+			return
+		}
+		if mbc.ramEnabled && !mbc.busy() {
+			mbc.store(mbc.RAM, mbc.computeRamAddress(addr), value)
+		}
+	}
+}
+
+func (mbc *Camera) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0x4000:
+		return mbc.ROM[addr]
+
+	case addr < 0x8000:
+		bank := mbc.romBankNumber % mbc.ROMBanks
+		return mbc.ROM[uint(bank)<<14|uint(addr&0x3FFF)]
+
+	case 0xA000 <= addr && addr < 0xC000:
+		if mbc.ramBankNumber&cameraRegsBank != 0 {
+			// Only A000 can be read
+			if addr&0x7F == 0 {
+				return mbc.regs[0]
+			}
+			return 0x00
+		}
+		// RAM cannot be read while capturing
+		if mbc.busy() {
+			return 0x00
+		}
+		return mbc.RAM[mbc.computeRamAddress(addr)]
+	}
+
+	return 0xFF
+}
+
+func (mbc *Camera) computeRamAddress(cpuAddress uint16) uint {
+	return uint(mbc.ramBankNumber&0x0F)<<13 | uint(cpuAddress&0x1FFF)
+}
+
+func (mbc *Camera) writeRegister(reg uint16, value uint8) {
+	if reg >= cameraRegsLen {
+		return
+	}
+	if reg != 0 {
+		mbc.regs[reg] = value
+		return
+	}
+
+	// The capture can't be stopped once started
+	mbc.regs[0] = value&0x07 | mbc.regs[0]&1
+	if value&1 == 1 && !mbc.busy() {
+		mbc.captureTicks = mbc.captureDuration()
+	}
+}
+
+func (mbc *Camera) exposure() int {
+	return int(mbc.regs[2])<<8 | int(mbc.regs[3])
+}
+
+// captureDuration returns the time the sensor takes to capture the image (in ticks)
+func (mbc *Camera) captureDuration() int {
+	cycles := 32446 + 16*mbc.exposure()
+	if mbc.regs[1]&0x80 == 0 { // N bit
+		cycles += 512
+	}
+	return 4 * cycles
+}
+
+// capture processes the source image and writes it to RAM as 2bpp tiles
+func (mbc *Camera) capture() {
+	source := mbc.source
+	if source == nil {
+		source = TestPattern{}
+	}
+	pixels := cameraSample(source.Image())
+
+	// Exposure and gain
+	gainDB := 14 + 1.5*float64(mbc.regs[1]&0x1F)
+	amplification := float64(mbc.exposure()) / cameraExposureNormal * math.Pow(10, (gainDB-26)/20)
+	for i := range pixels {
+		pixels[i] *= amplification
+	}
+
+	// Edge enhancement
+	ratio := cameraEdgeRatio[(mbc.regs[4]>>4)&7]
+	switch (mbc.regs[1] >> 5) & 3 { // VH bits
+	case 1:
+		pixels = cameraEdgeEnhance(pixels, ratio, true, false)
+	case 2:
+		pixels = cameraEdgeEnhance(pixels, ratio, false, true)
+	case 3:
+		pixels = cameraEdgeEnhance(pixels, ratio, true, true)
+	}
+
+	invert := mbc.regs[4]&0x08 != 0
+	for y := range CameraHeight {
+		for x := range CameraWidth {
+			v := pixels[y*CameraWidth+x]
+			if invert {
+				v = 1 - v
+			}
+			mbc.setPixel(x, y, mbc.dither(x, y, uint8(math.Round(255*min(max(v, 0), 1)))))
+		}
+	}
+}
+
+// dither converts the pixel value to a color using the thresholds of the dithering matrix
+func (mbc *Camera) dither(x, y int, value uint8) uint8 {
+	base := cameraDitherAddr + ((y&3)*4+(x&3))*3
+	switch {
+	case value < mbc.regs[base]:
+		return 3
+	case value < mbc.regs[base+1]:
+		return 2
+	case value < mbc.regs[base+2]:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (mbc *Camera) setPixel(x, y int, color uint8) {
+	tile := (y/8)*(CameraWidth/8) + x/8
+	addr := cameraImageAddr + tile*16 + (y%8)*2
+	bit := uint(7 - x%8)
+
+	mbc.store(mbc.RAM, uint(addr), mbc.RAM[addr]&^(1<<bit)|(color&1)<<bit)
+	mbc.store(mbc.RAM, uint(addr+1), mbc.RAM[addr+1]&^(1<<bit)|(color>>1)<<bit)
+}
+
+// cameraSample converts the image to the sensor resolution, returning luminance values in [0, 1].
+// The image is scaled to cover the sensor and centered.
+func cameraSample(img image.Image) []float64 {
+	pixels := make([]float64, CameraWidth*CameraHeight)
+	if img == nil || img.Bounds().Empty() {
+		return pixels
+	}
+
+	b := img.Bounds()
+	scale := min(float64(b.Dx())/CameraWidth, float64(b.Dy())/CameraHeight)
+	offsetX := (float64(b.Dx()) - scale*CameraWidth) / 2
+	offsetY := (float64(b.Dy()) - scale*CameraHeight) / 2
+
+	for y := range CameraHeight {
+		for x := range CameraWidth {
+			sx := b.Min.X + int(offsetX+(float64(x)+0.5)*scale)
+			sy := b.Min.Y + int(offsetY+(float64(y)+0.5)*scale)
+			r, g, bl, _ := img.At(sx, sy).RGBA()
+			pixels[y*CameraWidth+x] = (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)) / 0xFFFF
+		}
+	}
+	return pixels
+}
+
+// cameraEdgeEnhance applies the sensor edge enhancement: each pixel is amplified by the
+// difference with its horizontal and/or vertical neighbors
+func cameraEdgeEnhance(pixels []float64, ratio float64, horizontal, vertical bool) []float64 {
+	at := func(x, y int) float64 {
+		x = min(max(x, 0), CameraWidth-1)
+		y = min(max(y, 0), CameraHeight-1)
+		return pixels[y*CameraWidth+x]
+	}
+
+	out := make([]float64, len(pixels))
+	for y := range CameraHeight {
+		for x := range CameraWidth {
+			p := at(x, y)
+			v := p
+			if horizontal {
+				v += ratio * (2*p - at(x-1, y) - at(x+1, y))
+			}
+			if vertical {
+				v += ratio * (2*p - at(x, y-1) - at(x, y+1))
+			}
+			out[y*CameraWidth+x] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,123 @@
+package cartridge
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ImageSource provides the images seen by the Game Boy Camera sensor.
+// Images are converted to grayscale and scaled to cover 128x112 pixels.
+type ImageSource interface {
+	Image() image.Image
+}
+
+// NewImageSource returns a source for the path: a PNG file, a directory of PNG frames,
+// or the test pattern if the path is empty
+func NewImageSource(path string) (ImageSource, error) {
+	if path == "" {
+		return TestPattern{}, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return NewFrameDirSource(path)
+	}
+	return NewPNGSource(path)
+}
+
+// PNGSource always shows the same picture
+type PNGSource struct {
+	img image.Image
+}
+
+func NewPNGSource(path string) (*PNGSource, error) {
+	img, err := loadPNG(path)
+	if err != nil {
+		return nil, err
+	}
+	return &PNGSource{img: img}, nil
+}
+
+func (s *PNGSource) Image() image.Image {
+	return s.img
+}
+
+// FrameDirSource shows the PNG files of a directory in name order, one per capture, looping
+type FrameDirSource struct {
+	frames []string
+	next   int
+}
+
+func NewFrameDirSource(dir string) (*FrameDirSource, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FrameDirSource{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".png") {
+			s.frames = append(s.frames, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(s.frames) == 0 {
+		return nil, fmt.Errorf("no PNG frames in %s", dir)
+	}
+	slices.Sort(s.frames)
+	return s, nil
+}
+
+func (s *FrameDirSource) Image() image.Image {
+	path := s.frames[s.next]
+	s.next = (s.next + 1) % len(s.frames)
+
+	// Frames are loaded lazily, a broken frame is seen as a black image
+	img, err := loadPNG(path)
+	if err != nil {
+		return nil
+	}
+	return img
+}
+
+// TestPattern shows gray bars with a diagonal black to white gradient
+type TestPattern struct{}
+
+func (TestPattern) Image() image.Image {
+	img := image.NewGray(image.Rect(0, 0, CameraWidth, CameraHeight))
+	for y := range CameraHeight {
+		for x := range CameraWidth {
+			var v uint8
+			if y < CameraHeight/2 {
+				// 4 vertical bars
+				v = uint8(255 - (x/(CameraWidth/4))*85)
+			} else {
+				v = uint8((x + y - CameraHeight/2) * 255 / (CameraWidth + CameraHeight/2 - 2))
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func loadPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return img, nil
+}
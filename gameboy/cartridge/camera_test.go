@@ -0,0 +1,155 @@
+package cartridge
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type grayImage uint8
+
+func (g grayImage) Image() image.Image {
+	return image.NewUniform(color.Gray{Y: uint8(g)})
+}
+
+func newTestCamera(t *testing.T, sav []uint8) *Camera {
+	c, err := NewCartridge(testROM(0x80000, 0xFC, 0x04, 0x04), sav)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*Camera)
+}
+
+// setupSensor selects the registers and sets neutral exposure and gain with thresholds $40, $80, $C0
+func setupSensor(mbc *Camera) {
+	mbc.Write(0x4000, 0x10)
+	mbc.Write(0xA001, 0x08) // Gain with no amplification, no edge enhancement
+	mbc.Write(0xA002, cameraExposureNormal>>8)
+	mbc.Write(0xA003, cameraExposureNormal&0xFF)
+	for i := uint16(0); i < 16; i++ {
+		mbc.Write(0xA006+i*3, 0x40)
+		mbc.Write(0xA007+i*3, 0x80)
+		mbc.Write(0xA008+i*3, 0xC0)
+	}
+}
+
+// capture takes a picture and returns the color of the pixel from RAM
+func capture(t *testing.T, mbc *Camera) func(x, y int) uint8 {
+	mbc.Write(0x4000, 0x10)
+	mbc.Write(0xA000, 0x01)
+	if mbc.Read(0xA000)&1 != 1 {
+		t.Fatalf("camera not busy after starting capture")
+	}
+
+	mbc.Write(0x4000, 0x00)
+	if v := mbc.Read(0xA100); v != 0x00 {
+		t.Errorf("RAM readable while capturing: %02X", v)
+	}
+	for mbc.busy() {
+		mbc.Tick(4)
+	}
+
+	return func(x, y int) uint8 {
+		addr := cameraImageAddr + ((y/8)*16+x/8)*16 + (y%8)*2
+		bit := 7 - x%8
+		return (mbc.RAM[addr]>>bit)&1 | ((mbc.RAM[addr+1]>>bit)&1)<<1
+	}
+}
+
+func TestCameraCapture(t *testing.T) {
+	mbc := newTestCamera(t, nil)
+	setupSensor(mbc)
+
+	t.Run("duration", func(t *testing.T) {
+		// N bit clear adds 512 cycles
+		if d := mbc.captureDuration(); d != 4*(32446+512+16*cameraExposureNormal) {
+			t.Errorf("got %d ticks", d)
+		}
+	})
+
+	t.Run("test pattern", func(t *testing.T) {
+		pixel := capture(t, mbc)
+		for bar, expected := range []uint8{0, 1, 2, 3} {
+			if c := pixel(bar*32+16, 10); c != expected {
+				t.Errorf("bar %d: got color %d, expected %d", bar, c, expected)
+			}
+		}
+	})
+
+	t.Run("invert", func(t *testing.T) {
+		mbc.SetImageSource(grayImage(0xFF))
+		mbc.Write(0x4000, 0x10)
+		mbc.Write(0xA004, 0x08)
+		if c := capture(t, mbc)(0, 0); c != 3 {
+			t.Errorf("got color %d, expected 3", c)
+		}
+		mbc.Write(0x4000, 0x10)
+		mbc.Write(0xA004, 0x00)
+	})
+
+	t.Run("exposure", func(t *testing.T) {
+		mbc.SetImageSource(grayImage(0x50))
+		if c := capture(t, mbc)(0, 0); c != 2 {
+			t.Errorf("got color %d, expected 2", c)
+		}
+
+		// Doubling the exposure time makes the image brighter
+		mbc.Write(0x4000, 0x10)
+		mbc.Write(0xA002, cameraExposureNormal>>7)
+		if c := capture(t, mbc)(0, 0); c != 1 {
+			t.Errorf("got color %d, expected 1", c)
+		}
+	})
+
+	t.Run("edge enhancement", func(t *testing.T) {
+		mbc.SetImageSource(grayImage(0x50))
+		mbc.Write(0x4000, 0x10)
+		mbc.Write(0xA001, 0x68) // 2D enhancement
+		mbc.Write(0xA002, cameraExposureNormal>>8)
+
+		// Uniform images are not affected
+		if c := capture(t, mbc)(50, 50); c != 2 {
+			t.Errorf("got color %d, expected 2", c)
+		}
+	})
+
+	t.Run("save", func(t *testing.T) {
+		loaded := newTestCamera(t, mbc.RAMDump())
+		if string(loaded.RAM[cameraImageAddr:0x1000]) != string(mbc.RAM[cameraImageAddr:0x1000]) {
+			t.Errorf("photo not restored from save")
+		}
+	})
+}
+
+func TestFrameDirSource(t *testing.T) {
+	dir := t.TempDir()
+	for i, name := range []string{"b.png", "a.png"} {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := image.NewGray(image.Rect(0, 0, 4, 4))
+		img.SetGray(0, 0, color.Gray{Y: uint8(i + 1)})
+		if err = png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+
+	source, err := NewImageSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []uint8{2, 1, 2} {
+		if v := source.Image().(*image.Gray).GrayAt(0, 0).Y; v != expected {
+			t.Errorf("got frame %d, expected %d", v, expected)
+		}
+	}
+
+	if _, err = NewImageSource(t.TempDir()); err == nil {
+		t.Errorf("expected error for empty directory")
+	}
+}
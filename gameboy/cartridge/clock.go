@@ -0,0 +1,108 @@
+package cartridge
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Clock provides the current time to cartridges with a real time clock.
+// It timestamps the RTC data stored in the SAV file, so that the RTC can be advanced for the
+// time elapsed since saving, and drives the MBC3 RTC in wall time mode.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is the system clock
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// FrozenClock always returns the same time: no time elapses between saving and loading,
+// making runs deterministic
+type FrozenClock struct {
+	Time time.Time
+}
+
+func (c FrozenClock) Now() time.Time {
+	return c.Time
+}
+
+// OffsetClock is the system clock shifted by a fixed amount (e.g. to trigger events in the future)
+type OffsetClock struct {
+	Offset time.Duration
+}
+
+func (c OffsetClock) Now() time.Time {
+	return time.Now().Add(c.Offset)
+}
+
+// ParseClock parses a clock description:
+//   - "real" (or empty): the system clock
+//   - "frozen" or "frozen=<RFC 3339 time>": a clock stopped at the given time (Unix epoch by default)
+//   - "offset=<duration>": the system clock shifted by the duration (e.g. "offset=-36h")
+func ParseClock(s string) (Clock, error) {
+	name, arg, hasArg := strings.Cut(s, "=")
+	switch strings.ToLower(name) {
+	case "real", "":
+		if !hasArg {
+			return RealClock{}, nil
+		}
+
+	case "frozen":
+		if !hasArg {
+			return FrozenClock{Time: time.Unix(0, 0)}, nil
+		}
+		t, err := time.Parse(time.RFC3339, arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid frozen clock time: %w", err)
+		}
+		return FrozenClock{Time: t}, nil
+
+	case "offset":
+		offset, err := time.ParseDuration(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid clock offset: %w", err)
+		}
+		return OffsetClock{Offset: offset}, nil
+	}
+
+	return nil, fmt.Errorf("invalid clock %q (real, frozen[=time], offset=duration)", s)
+}
+
+// RTCMode selects how the MBC3 RTC advances while the game runs
+type RTCMode uint8
+
+const (
+	// RTCEmulated counts emulated cycles: the clock follows fast forward and stops with the emulator
+	RTCEmulated RTCMode = iota
+	// RTCWallTime follows the cartridge Clock
+	RTCWallTime
+)
+
+func ParseRTCMode(mode string) (RTCMode, error) {
+	switch strings.ToLower(mode) {
+	case "emulated", "":
+		return RTCEmulated, nil
+	case "wall":
+		return RTCWallTime, nil
+	default:
+		return RTCEmulated, fmt.Errorf("invalid RTC mode %q (emulated, wall)", mode)
+	}
+}
+
+func (m RTCMode) String() string {
+	if m == RTCWallTime {
+		return "wall"
+	}
+	return "emulated"
+}
+
+// Apply sets the RTC mode of MBC3 cartridges, other cartridges are left untouched
+func (m RTCMode) Apply(c Cartridge) {
+	if mbc, ok := c.(*MBC3); ok {
+		mbc.SetRTCMode(m)
+	}
+}
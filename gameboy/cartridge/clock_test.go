@@ -0,0 +1,40 @@
+package cartridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		clock    string
+		expected Clock
+	}{
+		{"", RealClock{}},
+		{"real", RealClock{}},
+		{"frozen", FrozenClock{Time: time.Unix(0, 0)}},
+		{"offset=-36h", OffsetClock{Offset: -36 * time.Hour}},
+	}
+	for _, test := range tests {
+		clock, err := ParseClock(test.clock)
+		if err != nil {
+			t.Errorf("%q: %v", test.clock, err)
+		} else if clock != test.expected {
+			t.Errorf("%q: got %#v, expected %#v", test.clock, clock, test.expected)
+		}
+	}
+
+	clock, err := ParseClock("frozen=2001-09-01T12:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if now := clock.Now(); !now.Equal(time.Date(2001, 9, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("frozen clock: got %v", now)
+	}
+
+	for _, invalid := range []string{"atomic", "real=now", "offset=soon", "frozen=yesterday"} {
+		if _, err := ParseClock(invalid); err == nil {
+			t.Errorf("%q: expected error", invalid)
+		}
+	}
+}
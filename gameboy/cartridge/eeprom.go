@@ -0,0 +1,205 @@
+package cartridge
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+
+const (
+	eepromIdle uint8 = iota
+	eepromCommand
+	eepromRead
+	eepromWrite
+)
+
+// eeprom emulates the 93LC56 (256 bytes) and 93LC66 (512 bytes) serial EEPROMs
+// in 16-bit organization. Bits are shifted in on DI and out on DO on the rising edge of CLK
+// while CS is high. Words are stored little endian in data.
+type eeprom struct {
+	data []uint8
+	saveTracker
+
+	cs, clk, di, do bool
+
+	state        uint8
+	writeEnabled bool
+	writeAll     bool
+
+	shift uint16 // Bits shifted in
+	bits  int    // Number of bits shifted in (or out)
+	addr  uint8
+}
+
+func newEEPROM(data []uint8) *eeprom {
+	return &eeprom{data: data, do: true}
+}
+
+func (e *eeprom) words() int {
+	return len(e.data) / 2
+}
+
+func (e *eeprom) readWord(addr uint8) uint16 {
+	i := int(addr) % e.words() * 2
+	return uint16(e.data[i]) | uint16(e.data[i+1])<<8
+}
+
+func (e *eeprom) writeWord(addr uint8, value uint16) {
+	i := uint(addr) % uint(e.words()) * 2
+	e.store(e.data, i, uint8(value))
+	e.store(e.data, i+1, uint8(value>>8))
+}
+
+// Read returns the pins: bit 7 CS, bit 6 CLK, bit 1 DI, bit 0 DO
+func (e *eeprom) Read() uint8 {
+	var v uint8
+	if e.cs {
+		v |= 0x80
+	}
+	if e.clk {
+		v |= 0x40
+	}
+	if e.di {
+		v |= 0x02
+	}
+	if e.do {
+		v |= 0x01
+	}
+	return v
+}
+
+// Write sets CS (bit 7), CLK (bit 6) and DI (bit 1)
+func (e *eeprom) Write(v uint8) {
+	cs, clk, di := v&0x80 != 0, v&0x40 != 0, v&0x02 != 0
+	risingEdge := cs && clk && !e.clk
+	e.cs, e.clk, e.di = cs, clk, di
+
+	// Deselecting the chip aborts the current command
+	if !cs {
+		e.state = eepromIdle
+		e.do = true
+		return
+	}
+	if risingEdge {
+		e.clock()
+	}
+}
+
+func (e *eeprom) clock() {
+	switch e.state {
+	case eepromIdle:
+		// Wait for start bit
+		if e.di {
+			e.state = eepromCommand
+			e.shift, e.bits = 0, 0
+		}
+
+	case eepromCommand:
+		e.shiftIn()
+		// 2 bits opcode + 8 bits address
+		if e.bits == 10 {
+			e.execute(uint8(e.shift>>8), uint8(e.shift))
+		}
+
+	case eepromRead:
+		// A dummy 0 is output before the data, then words are read sequentially
+		if e.bits == 16 {
+			e.addr++
+			e.shift, e.bits = e.readWord(e.addr), 0
+		}
+		e.do = e.shift&0x8000 != 0
+		e.shift <<= 1
+		e.bits++
+
+	case eepromWrite:
+		e.shiftIn()
+		if e.bits == 16 {
+			if e.writeEnabled {
+				if e.writeAll {
+					for addr := range e.words() {
+						e.writeWord(uint8(addr), e.shift)
+					}
+				} else {
+					e.writeWord(e.addr, e.shift)
+				}
+			}
+			// Ready immediately
+			e.do = true
+			e.state = eepromIdle
+		}
+	}
+}
+
+func (e *eeprom) shiftIn() {
+	e.shift <<= 1
+	if e.di {
+		e.shift |= 1
+	}
+	e.bits++
+}
+
+func (e *eeprom) execute(opcode uint8, addr uint8) {
+	e.state = eepromIdle
+	e.addr = addr
+
+	switch opcode {
+	case 0b10: // READ
+		e.state = eepromRead
+		e.shift, e.bits = e.readWord(addr), 0
+		e.do = false
+
+	case 0b01: // WRITE
+		e.state = eepromWrite
+		e.writeAll = false
+		e.shift, e.bits = 0, 0
+
+	case 0b11: // ERASE
+		if e.writeEnabled {
+			e.writeWord(addr, 0xFFFF)
+		}
+		e.do = true
+
+	case 0b00:
+		switch addr >> 6 {
+		case 0b00: // EWDS
+			e.writeEnabled = false
+		case 0b11: // EWEN
+			e.writeEnabled = true
+		case 0b10: // ERAL
+			if e.writeEnabled {
+				for i := range e.data {
+					e.store(e.data, uint(i), 0xFF)
+				}
+			}
+			e.do = true
+		case 0b01: // WRAL
+			e.state = eepromWrite
+			e.writeAll = true
+			e.shift, e.bits = 0, 0
+		}
+	}
+}
+
+func (e *eeprom) saveState(enc *savestate.Encoder) {
+	enc.Bool(e.cs)
+	enc.Bool(e.clk)
+	enc.Bool(e.di)
+	enc.Bool(e.do)
+	enc.U8(e.state)
+	enc.Bool(e.writeEnabled)
+	enc.Bool(e.writeAll)
+	enc.U16(e.shift)
+	enc.Int(e.bits)
+	enc.U8(e.addr)
+	enc.Bytes(e.data)
+}
+
+func (e *eeprom) loadState(d *savestate.Decoder) {
+	d.Bool(&e.cs)
+	d.Bool(&e.clk)
+	d.Bool(&e.di)
+	d.Bool(&e.do)
+	d.U8(&e.state)
+	d.Bool(&e.writeEnabled)
+	d.Bool(&e.writeAll)
+	d.U16(&e.shift)
+	d.Int(&e.bits)
+	d.U8(&e.addr)
+	d.BytesInto(e.data)
+}
@@ -0,0 +1,51 @@
+package cartridge
+
+import "fmt"
+
+// UnsupportedMapperError is returned when the cartridge type (byte 0147) is not emulated
+type UnsupportedMapperError struct {
+	Type uint8
+}
+
+func (e *UnsupportedMapperError) Error() string {
+	return fmt.Sprintf("cartridge type %02X not supported", e.Type)
+}
+
+// TruncatedROMError is returned when the ROM is too small to contain the header
+type TruncatedROMError struct {
+	Size int
+}
+
+func (e *TruncatedROMError) Error() string {
+	return fmt.Sprintf("ROM is truncated: %d bytes, the header alone requires %d", e.Size, headerEnd)
+}
+
+// InvalidHeaderError is returned when a header field has a value that is not valid
+type InvalidHeaderError struct {
+	Field string
+	Value uint8
+}
+
+func (e *InvalidHeaderError) Error() string {
+	return fmt.Sprintf("invalid %s in cartridge header: %02X", e.Field, e.Value)
+}
+
+// ROMSizeMismatchError is returned when the ROM is smaller than the size declared in the header
+type ROMSizeMismatchError struct {
+	HeaderSize int
+	Size       int
+}
+
+func (e *ROMSizeMismatchError) Error() string {
+	return fmt.Sprintf("ROM size (%d bytes) does not match header (%d bytes)", e.Size, e.HeaderSize)
+}
+
+// SaveSizeError is returned when the save file has not the expected size
+type SaveSizeError struct {
+	Expected int
+	Size     int
+}
+
+func (e *SaveSizeError) Error() string {
+	return fmt.Sprintf("save file is %d bytes, expected %d", e.Size, e.Expected)
+}
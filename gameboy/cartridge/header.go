@@ -1,8 +1,8 @@
 package cartridge
 
 import (
+	"bytes"
 	"fmt"
-	"log"
 )
 
 type CGBMode int
@@ -14,7 +14,9 @@ const (
 )
 
 const (
+	logo          = 0x0104
 	cgbFlag       = 0x0143
+	sgbFlag       = 0x0146
 	cartridgeType = 0x0147
 	romSize       = 0x0148
 	ramSize       = 0x0149
@@ -22,12 +24,27 @@ const (
 	title    = 0x134
 	titleLen = 16
 
+	manufacturerCode    = 0x013F
+	manufacturerCodeLen = 4
+
 	destinationCode = 0x014A
 	oldLicenseeCode = 0x014B
 	newLicenseeCode = 0x0144
 	gameVersion     = 0x014C
+	headerChecksum  = 0x014D
+	globalChecksum  = 0x014E
+
+	// First byte after the header
+	headerEnd = 0x0150
 )
 
+// NintendoLogo is the bitmap that the boot ROM compares with bytes 0104-0133
+var NintendoLogo = [48]uint8{
+	0xCE, 0xED, 0x66, 0x66, 0xCC, 0x0D, 0x00, 0x0B, 0x03, 0x73, 0x00, 0x83, 0x00, 0x0C, 0x00, 0x0D,
+	0x00, 0x08, 0x11, 0x1F, 0x88, 0x89, 0x00, 0x0E, 0xDC, 0xCC, 0x6E, 0xE6, 0xDD, 0xDD, 0xD9, 0x99,
+	0xBB, 0xBB, 0x67, 0x63, 0x6E, 0x0E, 0xEC, 0xCC, 0xDD, 0xDC, 0x99, 0x9F, 0xBB, 0xB9, 0x33, 0x3E,
+}
+
 type Header struct {
 	// ROMSize = 16 KiB * ROMBanks
 	ROMBanks uint
@@ -35,22 +52,66 @@ type Header struct {
 	// RAMSize = 8 KiB * RAMBanks
 	RAMBanks uint
 
-	// Bytes 0134-0143
+	// Bytes 0134-0143 (0134-0142 for CGB games, 0134-013E if the manufacturer code is present)
 	Title string
+	// Bytes 013F-0142 (only in newer cartridges, empty otherwise)
+	ManufacturerCode string
 	// Licensee code
 	Licensee string
 	// Destination (00=Japan, 01=Overseas)
 	Destination uint8
 	// Game version (usually 00)
 	GameVersion uint8
+	// Byte 014D
+	HeaderChecksum uint8
+	// Bytes 014E-014F (big endian)
+	GlobalChecksum uint16
 
 	// Byte 0143
 	CgbMode CGBMode
+	// Byte 0146 (game supports SGB functions)
+	SgbSupport bool
+	// Byte 0147 (mapper and hardware present on the cartridge)
+	CartridgeType uint8
+
+	// Results of the header verification: the boot ROM locks up if the logo or the header checksum
+	// are wrong (the CGB checks only the top half of the logo), the global checksum is never verified
+	LogoValid           bool
+	LogoTopHalfValid    bool
+	HeaderChecksumValid bool
+	GlobalChecksumValid bool
+
+	// Problems found while parsing the header
+	Warnings []string
+
+	oldLicensee uint8
+}
+
+func parseHeader(data []byte) (*Header, error) {
+	return parseHeaderAt(data, 0)
 }
 
-func parseHeader(data []byte) *Header {
-	// Parse title
-	Title := parseTitle(data[title : title+titleLen])
+// parseHeaderAt parses the header of the 32 KiB starting at offset (MMM01 compilations boot from
+// their last 32 KiB), the global checksum is still computed over the whole ROM
+func parseHeaderAt(rom []byte, offset int) (*Header, error) {
+	if len(rom)-offset < headerEnd {
+		return nil, &TruncatedROMError{Size: len(rom)}
+	}
+	data := rom[offset:]
+
+	// Parse title and manufacturer code (CGB games use the last byte of the title as CGB flag)
+	titleData := data[title : title+titleLen]
+	var ManufacturerCode string
+	if data[cgbFlag]&0x80 != 0 {
+		titleData = titleData[:titleLen-1]
+
+		code := data[manufacturerCode : manufacturerCode+manufacturerCodeLen]
+		if isManufacturerCode(code) {
+			ManufacturerCode = string(code)
+			titleData = data[title:manufacturerCode]
+		}
+	}
+	Title := parseTitle(titleData)
 
 	// Parse licensee code
 	var LicenseeCode string
@@ -60,7 +121,14 @@ func parseHeader(data []byte) *Header {
 		LicenseeCode = string(data[newLicenseeCode : newLicenseeCode+2])
 	}
 
-	RAMBanks := computeRAMSize(data[ramSize])
+	ROMBanks, err := computeROMBanks(data[romSize])
+	if err != nil {
+		return nil, err
+	}
+	RAMBanks, err := computeRAMSize(data[ramSize])
+	if err != nil {
+		return nil, err
+	}
 
 	var cgbMode CGBMode
 	switch data[cgbFlag] {
@@ -72,15 +140,88 @@ func parseHeader(data []byte) *Header {
 		cgbMode = DmgOnly
 	}
 
-	return &Header{
-		ROMBanks:    computeROMBanks(data[romSize]),
-		RAMBanks:    RAMBanks,
-		Title:       Title,
-		Licensee:    LicenseeCode,
-		Destination: data[destinationCode],
-		GameVersion: data[gameVersion],
-		CgbMode:     cgbMode,
+	header := &Header{
+		ROMBanks:         ROMBanks,
+		RAMBanks:         RAMBanks,
+		Title:            Title,
+		ManufacturerCode: ManufacturerCode,
+		Licensee:         LicenseeCode,
+		Destination:      data[destinationCode],
+		GameVersion:      data[gameVersion],
+		HeaderChecksum:   data[headerChecksum],
+		GlobalChecksum:   uint16(data[globalChecksum])<<8 | uint16(data[globalChecksum+1]),
+		CgbMode:          cgbMode,
+		SgbSupport:       data[sgbFlag] == 0x03,
+		CartridgeType:    data[cartridgeType],
+		oldLicensee:      data[oldLicenseeCode],
 	}
+	header.verify(rom, offset)
+
+	return header, nil
+}
+
+// verify checks the Nintendo logo and the checksums, adding a warning for each mismatch
+func (h *Header) verify(rom []byte, offset int) {
+	data := rom[offset:]
+	logoData := data[logo : logo+len(NintendoLogo)]
+	h.LogoValid = bytes.Equal(logoData, NintendoLogo[:])
+	h.LogoTopHalfValid = bytes.Equal(logoData[:len(NintendoLogo)/2], NintendoLogo[:len(NintendoLogo)/2])
+	if !h.LogoValid {
+		h.Warnings = append(h.Warnings, "Nintendo logo does not match, the boot ROM would lock up")
+	}
+
+	// x = 0; for each byte in 0134-014C: x = x - byte - 1
+	var checksum uint8
+	for _, b := range data[title:headerChecksum] {
+		checksum = checksum - b - 1
+	}
+	h.HeaderChecksumValid = checksum == h.HeaderChecksum
+	if !h.HeaderChecksumValid {
+		h.Warnings = append(h.Warnings, fmt.Sprintf(
+			"header checksum is %02X, expected %02X: the boot ROM would lock up", h.HeaderChecksum, checksum))
+	}
+
+	// Sum of all ROM bytes, except the global checksum
+	var sum uint16
+	for i, b := range rom {
+		if i != offset+globalChecksum && i != offset+globalChecksum+1 {
+			sum += uint16(b)
+		}
+	}
+	h.GlobalChecksumValid = sum == h.GlobalChecksum
+	if !h.GlobalChecksumValid {
+		h.Warnings = append(h.Warnings, fmt.Sprintf("global checksum is %04X, expected %04X", h.GlobalChecksum, sum))
+	}
+}
+
+// BootCheckPassed reports whether the boot ROM would accept the cartridge
+func (h *Header) BootCheckPassed(cgb bool) bool {
+	if cgb {
+		return h.LogoTopHalfValid && h.HeaderChecksumValid
+	}
+	return h.LogoValid && h.HeaderChecksumValid
+}
+
+// SGBFunctions reports whether the Super Game Boy enables its functions for the game:
+// the SGB flag must be set and the old licensee code must be $33
+func (h *Header) SGBFunctions() bool {
+	return h.SgbSupport && h.oldLicensee == 0x33
+}
+
+// Publisher returns the name of the publisher from the licensee code
+func (h *Header) Publisher() string {
+	var name string
+	var ok bool
+	if h.oldLicensee == 0x33 {
+		name, ok = newLicensees[h.Licensee]
+	} else {
+		name, ok = oldLicensees[h.oldLicensee]
+	}
+
+	if !ok {
+		return "Unknown"
+	}
+	return name
 }
 
 func parseTitle(titleData []byte) string {
@@ -94,24 +235,37 @@ func parseTitle(titleData []byte) string {
 	return string(titleData[:first0])
 }
 
-func computeROMBanks(v uint8) uint {
-	return 1 << (v + 1)
+// isManufacturerCode checks if the code is made of uppercase letters and digits
+func isManufacturerCode(code []byte) bool {
+	for _, c := range code {
+		if !('A' <= c && c <= 'Z') && !('0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func computeROMBanks(v uint8) (uint, error) {
+	// From 32 KiB (2 banks) to 8 MiB (512 banks)
+	if v > 0x08 {
+		return 0, &InvalidHeaderError{Field: "ROM size", Value: v}
+	}
+	return 1 << (v + 1), nil
 }
 
-func computeRAMSize(v uint8) uint {
+func computeRAMSize(v uint8) (uint, error) {
 	switch v {
 	case 0x00:
-		return 0
+		return 0, nil
 	case 0x02:
-		return 1
+		return 1, nil
 	case 0x03:
-		return 4
+		return 4, nil
 	case 0x04:
-		return 16
+		return 16, nil
 	case 0x05:
-		return 8
+		return 8, nil
 	default:
-		log.Fatalf("Unsupported RAM size: %02X\n", v)
-		return 0
+		return 0, &InvalidHeaderError{Field: "RAM size", Value: v}
 	}
 }
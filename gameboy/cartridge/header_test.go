@@ -0,0 +1,130 @@
+package cartridge
+
+import "testing"
+
+// validROM returns a 32 KiB ROM with a valid header
+func validROM(title string) []uint8 {
+	rom := make([]uint8, 0x8000)
+	copy(rom[logo:], NintendoLogo[:])
+	copy(rom[0x134:], title)
+	return fixChecksums(rom)
+}
+
+func fixChecksums(rom []uint8) []uint8 {
+	var checksum uint8
+	for _, b := range rom[title:headerChecksum] {
+		checksum = checksum - b - 1
+	}
+	rom[headerChecksum] = checksum
+
+	var sum uint16
+	for i, b := range rom {
+		if i != globalChecksum && i != globalChecksum+1 {
+			sum += uint16(b)
+		}
+	}
+	rom[globalChecksum] = uint8(sum >> 8)
+	rom[globalChecksum+1] = uint8(sum)
+	return rom
+}
+
+func TestHeaderVerification(t *testing.T) {
+	header, err := parseHeader(validROM("VALID"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !header.LogoValid || !header.HeaderChecksumValid || !header.GlobalChecksumValid || len(header.Warnings) > 0 {
+		t.Errorf("valid header reported as invalid: %v", header.Warnings)
+	}
+	if !header.BootCheckPassed(false) || !header.BootCheckPassed(true) {
+		t.Errorf("boot check failed on valid header")
+	}
+
+	// Global checksum is not checked by the boot ROM
+	rom := validROM("VALID")
+	rom[0x4000] = 0xFF
+	header, _ = parseHeader(rom)
+	if header.GlobalChecksumValid || len(header.Warnings) != 1 || !header.BootCheckPassed(false) {
+		t.Errorf("global checksum: valid = %v, warnings = %v", header.GlobalChecksumValid, header.Warnings)
+	}
+
+	// Header checksum
+	rom = validROM("VALID")
+	rom[headerChecksum]++
+	header, _ = parseHeader(rom)
+	if header.HeaderChecksumValid || header.BootCheckPassed(false) || header.BootCheckPassed(true) {
+		t.Errorf("wrong header checksum not detected")
+	}
+
+	// The CGB only checks the top half of the logo
+	rom = validROM("VALID")
+	rom[logo+40] = 0
+	header, _ = parseHeader(fixChecksums(rom))
+	if header.LogoValid || !header.LogoTopHalfValid || header.BootCheckPassed(false) || !header.BootCheckPassed(true) {
+		t.Errorf("logo bottom half: valid = %v, top half valid = %v", header.LogoValid, header.LogoTopHalfValid)
+	}
+}
+
+func TestHeaderFields(t *testing.T) {
+	rom := validROM("")
+	copy(rom[title:], "PM_CRYSTAL\x00BYTE")
+	rom[cgbFlag] = 0xC0
+	copy(rom[newLicenseeCode:], "01")
+	rom[sgbFlag] = 0x03
+	rom[cartridgeType] = 0x10
+	rom[oldLicenseeCode] = 0x33
+
+	header, err := parseHeader(fixChecksums(rom))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header.Title != "PM_CRYSTAL" || header.ManufacturerCode != "BYTE" {
+		t.Errorf("title = %q, manufacturer code = %q", header.Title, header.ManufacturerCode)
+	}
+	if header.CgbMode != CgbOnly || !header.SgbSupport || header.CartridgeType != 0x10 {
+		t.Errorf("CGB mode = %d, SGB = %v, type = %02X", header.CgbMode, header.SgbSupport, header.CartridgeType)
+	}
+	if publisher := header.Publisher(); publisher != "Nintendo Research & Development 1" {
+		t.Errorf("publisher = %q", publisher)
+	}
+
+	// Old licensee code
+	rom = validROM("TETRIS")
+	rom[oldLicenseeCode] = 0x01
+	header, _ = parseHeader(fixChecksums(rom))
+	if header.Title != "TETRIS" || header.ManufacturerCode != "" || header.Publisher() != "Nintendo" {
+		t.Errorf("title = %q, manufacturer code = %q, publisher = %q", header.Title, header.ManufacturerCode, header.Publisher())
+	}
+}
+
+func TestHeaderMMM01(t *testing.T) {
+	// The global checksum of a compilation covers the whole ROM, not only the menu
+	rom := mmm01ROM()
+	menu := rom[len(rom)-0x8000:]
+	copy(menu[logo:], NintendoLogo[:])
+	fixChecksums(menu)
+
+	var sum uint16
+	for i, b := range rom {
+		if i != len(rom)-0x8000+globalChecksum && i != len(rom)-0x8000+globalChecksum+1 {
+			sum += uint16(b)
+		}
+	}
+	menu[globalChecksum] = uint8(sum >> 8)
+	menu[globalChecksum+1] = uint8(sum)
+
+	header, err := readHeader(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !header.GlobalChecksumValid || len(header.Warnings) > 0 {
+		t.Errorf("valid MMM01 header reported as invalid: %v", header.Warnings)
+	}
+
+	rom[0x4000]++
+	header, _ = readHeader(rom)
+	if header.GlobalChecksumValid {
+		t.Errorf("wrong global checksum not detected")
+	}
+}
@@ -0,0 +1,118 @@
+package cartridge
+
+import (
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+	"log"
+)
+
+// HuC1 is the Hudson mapper with battery backed RAM and an IR port
+type HuC1 struct {
+	header *Header
+	infraredPort
+	saveTracker
+
+	ROMBanks uint8
+	RAMBanks uint8
+
+	ROM []uint8
+	RAM []uint8
+
+	// Registers
+	irMode        bool  // When set $A000-$BFFF accesses the IR port instead of RAM
+	romBankNumber uint8 // 6 bit register
+	ramBankNumber uint8 // 2 bit register
+}
+
+func (mbc *HuC1) RAMDump() []uint8 {
+	return mbc.RAM
+}
+
+func (mbc *HuC1) Header() *Header {
+	return mbc.header
+}
+
+func (mbc *HuC1) SaveState(e *savestate.Encoder) {
+	e.Bool(mbc.irMode)
+	e.U8(mbc.romBankNumber)
+	e.U8(mbc.ramBankNumber)
+	mbc.infraredPort.saveState(e)
+	e.Bytes(mbc.RAM)
+}
+
+func (mbc *HuC1) LoadState(d *savestate.Decoder) {
+	d.Bool(&mbc.irMode)
+	d.U8(&mbc.romBankNumber)
+	d.U8(&mbc.ramBankNumber)
+	mbc.infraredPort.loadState(d)
+	d.BytesInto(mbc.RAM)
+}
+
+func NewHuC1(rom []uint8, savData []uint8, header *Header) (*HuC1, error) {
+	mbc := &HuC1{
+		header:        header,
+		ROMBanks:      uint8(header.ROMBanks),
+		RAMBanks:      uint8(header.RAMBanks),
+		ROM:           rom,
+		romBankNumber: 1,
+	}
+	if header.RAMBanks == 0 {
+		log.Println("[WARN] Cartridge header specifies RAM present, but RAM banks is set to 0")
+		mbc.RAMBanks = 1
+	}
+
+	ramLen := int(mbc.RAMBanks) * 0x2000
+	switch {
+	case savData == nil:
+		savData = make([]uint8, ramLen)
+	case len(savData) != ramLen:
+		return nil, &SaveSizeError{Expected: ramLen, Size: len(savData)}
+	}
+	mbc.RAM = savData
+
+	return mbc, nil
+}
+
+func (mbc *HuC1) Write(addr uint16, value uint8) {
+	switch {
+	case addr < 0x2000:
+		// $0E selects the IR port, anything else RAM
+		mbc.irMode = value&0x0F == 0x0E
+
+	case addr < 0x4000:
+		mbc.romBankNumber = value & 0x3F
+
+	case addr < 0x6000:
+		mbc.ramBankNumber = value & 0x3
+
+	case 0xA000 <= addr && addr < 0xC000:
+		if mbc.irMode {
+			mbc.infraredPort.write(value)
+		} else {
+			mbc.store(mbc.RAM, mbc.computeRamAddress(addr), value)
+		}
+	}
+}
+
+func (mbc *HuC1) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0x4000:
+		return mbc.ROM[addr]
+
+	case addr < 0x8000:
+		bank := mbc.romBankNumber % mbc.ROMBanks
+		return mbc.ROM[uint(bank)<<14|uint(addr&0x3FFF)]
+
+	case 0xA000 <= addr && addr < 0xC000:
+		if mbc.irMode {
+			return mbc.infraredPort.read()
+		}
+		return mbc.RAM[mbc.computeRamAddress(addr)]
+	}
+
+	return 0xFF
+}
+
+func (mbc *HuC1) computeRamAddress(cpuAddress uint16) uint {
+	bank := mbc.ramBankNumber % mbc.RAMBanks
+	return uint(bank)<<13 | uint(cpuAddress&0x1FFF)
+}
@@ -0,0 +1,283 @@
+package cartridge
+
+import (
+	"encoding/binary"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+	"log"
+	"time"
+)
+
+const (
+	// RTC data stored after RAM in SAV file
+	huc3RTCLen = 17
+
+	minutesPerDay = 24 * 60
+
+	// Nibble addresses in the RTC memory
+	huc3TimeAddr         = 0x00 // 3 nibbles of minutes + 3 nibbles of days
+	huc3AlarmAddr        = 0x58 // 3 nibbles of minutes + 3 nibbles of days
+	huc3AlarmEnabledAddr = 0x5E
+)
+
+// HuC3 is the Hudson mapper with battery backed RAM, RTC and an IR port.
+// The RTC is accessed with commands written in mode $B, executed in mode $D and
+// whose results are read in mode $C.
+type HuC3 struct {
+	header *Header
+	infraredPort
+	saveTracker
+
+	ROMBanks uint8
+	RAMBanks uint8
+
+	ROM []uint8
+	RAM []uint8
+
+	// Registers
+	mode          uint8 // Selects what $A000-$BFFF accesses
+	romBankNumber uint8 // 7 bit register
+	ramBankNumber uint8 // 2 bit register
+
+	// RTC
+	minutes         uint16 // Minutes of the day (0-1439)
+	days            uint16 // 12 bit day counter
+	seconds         int
+	rtcClockCounter int
+	clock           Clock
+
+	rtcMemory [256]uint8 // Nibbles
+	command   uint8
+	response  uint8
+	index     uint8
+}
+
+func (mbc *HuC3) RAMDump() []uint8 {
+	dump := make([]uint8, len(mbc.RAM), len(mbc.RAM)+huc3RTCLen)
+	copy(dump, mbc.RAM)
+
+	// RTC data:
+	// offset  size    desc
+	// 0       8       unix timestamp when saving
+	// 8       2       minutes
+	// 10      2       days
+	// 12      2       alarm minutes
+	// 14      2       alarm days
+	// 16      1       alarm enabled
+	dump, _ = binary.Append(dump, binary.LittleEndian, mbc.clock.Now().Unix())
+	dump, _ = binary.Append(dump, binary.LittleEndian, mbc.minutes)
+	dump, _ = binary.Append(dump, binary.LittleEndian, mbc.days)
+	dump, _ = binary.Append(dump, binary.LittleEndian, mbc.readMemory12(huc3AlarmAddr))
+	dump, _ = binary.Append(dump, binary.LittleEndian, mbc.readMemory12(huc3AlarmAddr+3))
+	dump = append(dump, mbc.rtcMemory[huc3AlarmEnabledAddr])
+	return dump
+}
+
+func (mbc *HuC3) Header() *Header {
+	return mbc.header
+}
+
+func (mbc *HuC3) SaveState(e *savestate.Encoder) {
+	e.U8(mbc.mode)
+	e.U8(mbc.romBankNumber)
+	e.U8(mbc.ramBankNumber)
+	mbc.infraredPort.saveState(e)
+	e.Bytes(mbc.RAM)
+
+	// RTC
+	e.U16(mbc.minutes)
+	e.U16(mbc.days)
+	e.Int(mbc.seconds)
+	e.Int(mbc.rtcClockCounter)
+	e.U8s(mbc.rtcMemory[:])
+	e.U8(mbc.command)
+	e.U8(mbc.response)
+	e.U8(mbc.index)
+}
+
+func (mbc *HuC3) LoadState(d *savestate.Decoder) {
+	d.U8(&mbc.mode)
+	d.U8(&mbc.romBankNumber)
+	d.U8(&mbc.ramBankNumber)
+	mbc.infraredPort.loadState(d)
+	d.BytesInto(mbc.RAM)
+
+	// RTC
+	d.U16(&mbc.minutes)
+	d.U16(&mbc.days)
+	d.Int(&mbc.seconds)
+	d.Int(&mbc.rtcClockCounter)
+	d.U8s(mbc.rtcMemory[:])
+	d.U8(&mbc.command)
+	d.U8(&mbc.response)
+	d.U8(&mbc.index)
+}
+
+func NewHuC3(rom []uint8, savData []uint8, header *Header, clock Clock) (*HuC3, error) {
+	if clock == nil {
+		clock = RealClock{}
+	}
+
+	mbc := &HuC3{
+		header:        header,
+		ROMBanks:      uint8(header.ROMBanks),
+		RAMBanks:      uint8(header.RAMBanks),
+		ROM:           rom,
+		romBankNumber: 1,
+		clock:         clock,
+	}
+	if header.RAMBanks == 0 {
+		log.Println("[WARN] Cartridge header specifies RAM present, but RAM banks is set to 0")
+		mbc.RAMBanks = 1
+	}
+
+	ramLen := int(mbc.RAMBanks) * 0x2000
+	switch {
+	case savData == nil:
+		savData = make([]uint8, ramLen)
+	case len(savData) == ramLen: // Save without RTC data
+	case len(savData) == ramLen+huc3RTCLen:
+		mbc.parseRTCData(savData[ramLen:])
+	default:
+		return nil, &SaveSizeError{Expected: ramLen + huc3RTCLen, Size: len(savData)}
+	}
+	mbc.RAM = savData[:ramLen]
+
+	return mbc, nil
+}
+
+func (mbc *HuC3) Tick(ticks int) {
+	// RTC clocking: Game Boy runs at 2^22 Hz
+	mbc.rtcClockCounter += ticks
+	if mbc.rtcClockCounter >= 1<<22 {
+		mbc.rtcClockCounter -= 1 << 22
+
+		mbc.seconds++
+		if mbc.seconds == 60 {
+			mbc.seconds = 0
+			mbc.advanceMinutes(1)
+		}
+	}
+}
+
+func (mbc *HuC3) advanceMinutes(minutes int) {
+	total := int(mbc.minutes) + minutes
+	mbc.minutes = uint16(total % minutesPerDay)
+	mbc.days = uint16(int(mbc.days)+total/minutesPerDay) & 0xFFF
+}
+
+func (mbc *HuC3) Write(addr uint16, value uint8) {
+	switch {
+	case addr < 0x2000:
+		mbc.mode = value & 0x0F
+
+	case addr < 0x4000:
+		mbc.romBankNumber = value & 0x7F
+
+	case addr < 0x6000:
+		mbc.ramBankNumber = value & 0x3
+
+	case 0xA000 <= addr && addr < 0xC000:
+		switch mbc.mode {
+		case 0xA: // RAM read/write
+			mbc.store(mbc.RAM, mbc.computeRamAddress(addr), value)
+		case 0xB: // RTC command
+			mbc.command = value & 0x7F
+		case 0xD: // Semaphore, writing bit 0 clear executes the command
+			if value&1 == 0 {
+				mbc.executeCommand()
+			}
+		case 0xE: // IR
+			mbc.infraredPort.write(value)
+		}
+	}
+}
+
+func (mbc *HuC3) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0x4000:
+		return mbc.ROM[addr]
+
+	case addr < 0x8000:
+		bank := mbc.romBankNumber % mbc.ROMBanks
+		return mbc.ROM[uint(bank)<<14|uint(addr&0x3FFF)]
+
+	case 0xA000 <= addr && addr < 0xC000:
+		switch mbc.mode {
+		case 0x0, 0xA: // RAM read only, RAM read/write
+			return mbc.RAM[mbc.computeRamAddress(addr)]
+		case 0xC: // Last command and response
+			return mbc.command&0x70 | mbc.response
+		case 0xD: // Semaphore, commands are executed immediately
+			return 0xFF
+		case 0xE:
+			return mbc.infraredPort.read()
+		}
+	}
+
+	return 0xFF
+}
+
+func (mbc *HuC3) computeRamAddress(cpuAddress uint16) uint {
+	bank := mbc.ramBankNumber % mbc.RAMBanks
+	return uint(bank)<<13 | uint(cpuAddress&0x1FFF)
+}
+
+func (mbc *HuC3) executeCommand() {
+	arg := mbc.command & 0x0F
+
+	switch mbc.command >> 4 {
+	case 0x1: // Read nibble and increment index
+		mbc.response = mbc.rtcMemory[mbc.index]
+		mbc.index++
+	case 0x3: // Write nibble and increment index
+		mbc.rtcMemory[mbc.index] = arg
+		mbc.index++
+	case 0x4: // Set index low nibble
+		mbc.index = mbc.index&0xF0 | arg
+	case 0x5: // Set index high nibble
+		mbc.index = mbc.index&0x0F | arg<<4
+	case 0x6:
+		switch arg {
+		case 0x0: // Copy current time to memory
+			mbc.writeMemory12(huc3TimeAddr, mbc.minutes)
+			mbc.writeMemory12(huc3TimeAddr+3, mbc.days)
+		case 0x1: // Set current time from memory
+			mbc.minutes = mbc.readMemory12(huc3TimeAddr) % minutesPerDay
+			mbc.days = mbc.readMemory12(huc3TimeAddr + 3)
+			mbc.seconds = 0
+			mbc.rtcClockCounter = 0
+		case 0x2: // Status
+			mbc.response = 1
+		case 0xE: // Tone generator
+			log.Println("[WARN] HuC3 tone generator is not supported")
+		}
+	default:
+		log.Printf("[WARN] unknown HuC3 command %02X", mbc.command)
+	}
+}
+
+// readMemory12 returns a 12 bit value stored in 3 nibbles (least significant first)
+func (mbc *HuC3) readMemory12(addr uint8) uint16 {
+	return uint16(mbc.rtcMemory[addr]) | uint16(mbc.rtcMemory[addr+1])<<4 | uint16(mbc.rtcMemory[addr+2])<<8
+}
+
+func (mbc *HuC3) writeMemory12(addr uint8, value uint16) {
+	mbc.rtcMemory[addr] = uint8(value) & 0xF
+	mbc.rtcMemory[addr+1] = uint8(value>>4) & 0xF
+	mbc.rtcMemory[addr+2] = uint8(value>>8) & 0xF
+}
+
+func (mbc *HuC3) parseRTCData(data []uint8) {
+	timestamp := int64(binary.LittleEndian.Uint64(data))
+	mbc.minutes = binary.LittleEndian.Uint16(data[8:]) % minutesPerDay
+	mbc.days = binary.LittleEndian.Uint16(data[10:]) & 0xFFF
+	mbc.writeMemory12(huc3AlarmAddr, binary.LittleEndian.Uint16(data[12:]))
+	mbc.writeMemory12(huc3AlarmAddr+3, binary.LittleEndian.Uint16(data[14:]))
+	mbc.rtcMemory[huc3AlarmEnabledAddr] = data[16] & 0xF
+
+	// Advance the clock for the time elapsed since saving
+	elapsed := mbc.clock.Now().Sub(time.Unix(timestamp, 0))
+	if elapsed > 0 {
+		mbc.advanceMinutes(int(elapsed.Minutes()))
+	}
+}
@@ -0,0 +1,116 @@
+package cartridge
+
+import "testing"
+
+// loopbackIR receives the light of its own LED
+type loopbackIR struct{ led bool }
+
+func (ir *loopbackIR) SetLED(on bool)       { ir.led = on }
+func (ir *loopbackIR) ReceivingLight() bool { return ir.led }
+
+func TestHuC1(t *testing.T) {
+	rom := testROM(0x20000, 0xFF, 0x02, 0x03)
+	for bank := 0; bank < 8; bank++ {
+		rom[bank*0x4000+0x100] = uint8(bank)
+	}
+	c, err := NewCartridge(rom, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbc := c.(*HuC1)
+
+	mbc.Write(0x2000, 5)
+	if bank := mbc.Read(0x4100); bank != 5 {
+		t.Errorf("got ROM bank %d, expected 5", bank)
+	}
+
+	// RAM banking
+	mbc.Write(0x0000, 0x0A)
+	mbc.Write(0x4000, 2)
+	mbc.Write(0xA000, 0x42)
+	if mbc.RAMDump()[2*0x2000] != 0x42 {
+		t.Errorf("RAM bank 2 not written")
+	}
+
+	// IR
+	ir := &loopbackIR{}
+	mbc.SetInfrared(ir)
+	mbc.Write(0x0000, 0x0E)
+	if v := mbc.Read(0xA000); v != 0xC0 {
+		t.Errorf("IR: read %02X, expected C0", v)
+	}
+	mbc.Write(0xA000, 0x01)
+	if v := mbc.Read(0xA000); !ir.led || v != 0xC1 {
+		t.Errorf("IR: read %02X, expected C1", v)
+	}
+	if mbc.RAMDump()[2*0x2000] != 0x42 {
+		t.Errorf("RAM written in IR mode")
+	}
+}
+
+func TestHuC3RTC(t *testing.T) {
+	c, err := NewCartridge(testROM(0x20000, 0xFE, 0x02, 0x03), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbc := c.(*HuC3)
+
+	command := func(cmd uint8) uint8 {
+		mbc.Write(0x0000, 0x0B)
+		mbc.Write(0xA000, cmd)
+		mbc.Write(0x0000, 0x0D)
+		mbc.Write(0xA000, 0xFE)
+		mbc.Write(0x0000, 0x0C)
+		return mbc.Read(0xA000) & 0x0F
+	}
+	// readTime latches the time and reads minutes and days
+	readTime := func() (minutes, days uint16) {
+		command(0x60)
+		command(0x40)
+		command(0x50)
+		var v [6]uint16
+		for i := range v {
+			v[i] = uint16(command(0x10))
+		}
+		return v[0] | v[1]<<4 | v[2]<<8, v[3] | v[4]<<4 | v[5]<<8
+	}
+
+	if status := command(0x62); status != 1 {
+		t.Errorf("status %d, expected 1", status)
+	}
+
+	// Set 23:59 of day 7
+	command(0x40)
+	command(0x50)
+	for _, nibble := range []uint8{0xF, 0x9, 0x5, 0x7, 0x0, 0x0} { // 1439 = $59F
+		command(0x30 | nibble)
+	}
+	command(0x61)
+	if minutes, days := readTime(); minutes != 1439 || days != 7 {
+		t.Errorf("got %d minutes, %d days, expected 1439, 7", minutes, days)
+	}
+
+	// One minute later the day changes
+	for range 60 {
+		mbc.Tick(1 << 22)
+	}
+	if minutes, days := readTime(); minutes != 0 || days != 8 {
+		t.Errorf("got %d minutes, %d days, expected 0, 8", minutes, days)
+	}
+
+	// RTC is stored in the save
+	mbc.Write(0x0000, 0x0A)
+	mbc.Write(0xA000, 0x99)
+	dump := mbc.RAMDump()
+	if len(dump) != 4*0x2000+huc3RTCLen {
+		t.Fatalf("got save of %d bytes", len(dump))
+	}
+	c, err = NewCartridge(testROM(0x20000, 0xFE, 0x02, 0x03), dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := c.(*HuC3)
+	if loaded.days != 8 || loaded.minutes > 1 || loaded.RAM[0] != 0x99 {
+		t.Errorf("loaded %d minutes, %d days, RAM %02X", loaded.minutes, loaded.days, loaded.RAM[0])
+	}
+}
@@ -0,0 +1,57 @@
+package cartridge
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+
+// Infrared connects the IR LED and receiver of a cartridge (HuC1, HuC3) to the outside world,
+// e.g. another emulator instance or a frontend
+type Infrared interface {
+	// SetLED is called when the cartridge switches its LED on or off
+	SetLED(on bool)
+	// ReceivingLight reports whether the receiver currently detects light
+	ReceivingLight() bool
+}
+
+// InfraredCartridge is implemented by cartridges with an IR port
+type InfraredCartridge interface {
+	SetInfrared(ir Infrared)
+}
+
+// infraredPort is the IR register shared by Hudson mappers:
+// reading bit 0 reports received light, writing bit 0 switches the LED
+type infraredPort struct {
+	ir  Infrared
+	led bool
+}
+
+func (p *infraredPort) SetInfrared(ir Infrared) {
+	p.ir = ir
+	if ir != nil {
+		ir.SetLED(p.led)
+	}
+}
+
+func (p *infraredPort) read() uint8 {
+	if p.ir != nil && p.ir.ReceivingLight() {
+		return 0xC1
+	}
+	return 0xC0
+}
+
+func (p *infraredPort) write(value uint8) {
+	led := value&1 == 1
+	if led != p.led && p.ir != nil {
+		p.ir.SetLED(led)
+	}
+	p.led = led
+}
+
+func (p *infraredPort) saveState(e *savestate.Encoder) {
+	e.Bool(p.led)
+}
+
+func (p *infraredPort) loadState(d *savestate.Decoder) {
+	d.Bool(&p.led)
+	if p.ir != nil {
+		p.ir.SetLED(p.led)
+	}
+}
@@ -1,5 +1,7 @@
 package cartridge
 
+import "github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+
 type MBC0 struct {
 	header *Header
 
@@ -14,6 +16,10 @@ func (mbc *MBC0) Header() *Header {
 	return mbc.header
 }
 
+// MBC0 has no internal state
+func (mbc *MBC0) SaveState(_ *savestate.Encoder) {}
+func (mbc *MBC0) LoadState(_ *savestate.Decoder) {}
+
 func NewMBC0(data []uint8, header *Header) *MBC0 {
 	return &MBC0{
 		header: header,
@@ -1,10 +1,17 @@
 package cartridge
 
-import "log"
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+)
 
 type MBC1 struct {
 	header  *Header
 	battery bool // If battery is present RAM should be stored
+	saveTracker
 
 	ROMBanks uint8
 	RAMBanks uint8
@@ -20,6 +27,10 @@ type MBC1 struct {
 	bankingMode uint8
 
 	useRamBankNumberAsHighRomBankNumber bool
+
+	// MBC1M (multicart) wiring: the upper bank bits are shifted by 4 instead of 5
+	// and bit 4 of the ROM bank register is ignored. Detected from the ROM, can be overridden.
+	Multicart bool
 }
 
 func (mbc *MBC1) RAMDump() []uint8 {
@@ -34,8 +45,23 @@ func (mbc *MBC1) Header() *Header {
 	return mbc.header
 }
 
-func NewMBC1(rom []uint8, ram bool, savData []uint8, header *Header, battery bool) *MBC1 {
-	// TODO - Detect and handle MBC1M (Multi-Game carts)
+func (mbc *MBC1) SaveState(e *savestate.Encoder) {
+	e.Bool(mbc.ramEnabled)
+	e.U8(mbc.romBankNumber)
+	e.U8(mbc.ramBankNumber)
+	e.U8(mbc.bankingMode)
+	e.Bytes(mbc.RAM)
+}
+
+func (mbc *MBC1) LoadState(d *savestate.Decoder) {
+	d.Bool(&mbc.ramEnabled)
+	d.U8(&mbc.romBankNumber)
+	d.U8(&mbc.ramBankNumber)
+	d.U8(&mbc.bankingMode)
+	d.BytesInto(mbc.RAM)
+}
+
+func NewMBC1(rom []uint8, ram bool, savData []uint8, header *Header, battery bool) (*MBC1, error) {
 	mbc := &MBC1{
 		header:                              header,
 		battery:                             battery,
@@ -44,6 +70,7 @@ func NewMBC1(rom []uint8, ram bool, savData []uint8, header *Header, battery boo
 		ROM:                                 rom,
 		romBankNumber:                       1,
 		useRamBankNumberAsHighRomBankNumber: header.ROMBanks > 32,
+		Multicart:                           header.ROMBanks == 64 && IsMulticart(rom),
 	}
 	if ram && header.RAMBanks == 0 {
 		log.Println("[WARN] Cartridge header specifies RAM present, but RAM banks is set to 0")
@@ -51,17 +78,58 @@ func NewMBC1(rom []uint8, ram bool, savData []uint8, header *Header, battery boo
 	}
 
 	if ram {
+		ramLen := int(mbc.RAMBanks) * 0x2000
 		switch {
-		case battery && len(savData) != int(mbc.RAMBanks)*0x2000:
-			log.Println("[WARN] sav file was of a different dimension than expected, resetting to zero")
-			fallthrough
 		case savData == nil:
-			savData = make([]uint8, int(mbc.RAMBanks)*0x2000)
+			savData = make([]uint8, ramLen)
+		case len(savData) != ramLen:
+			return nil, &SaveSizeError{Expected: ramLen, Size: len(savData)}
 		}
 		mbc.RAM = savData
 	}
 
-	return mbc
+	return mbc, nil
+}
+
+// IsMulticart reports whether the ROM is a MBC1M compilation: each game of the
+// collection is 256 KiB long and has its own header with the Nintendo logo
+func IsMulticart(rom []uint8) bool {
+	games := 0
+	for base := 0; base+headerEnd <= len(rom); base += 0x40000 {
+		if [48]uint8(rom[base+logo:base+logo+len(NintendoLogo)]) == NintendoLogo {
+			games++
+		}
+	}
+	return games > 1
+}
+
+// MulticartMode overrides the MBC1M detection
+type MulticartMode uint8
+
+const (
+	MulticartAuto MulticartMode = iota
+	MulticartOn
+	MulticartOff
+)
+
+func ParseMulticartMode(mode string) (MulticartMode, error) {
+	switch strings.ToLower(mode) {
+	case "auto", "":
+		return MulticartAuto, nil
+	case "on":
+		return MulticartOn, nil
+	case "off":
+		return MulticartOff, nil
+	default:
+		return MulticartAuto, fmt.Errorf("invalid MBC1M mode %q (auto, on, off)", mode)
+	}
+}
+
+// Apply forces the MBC1M wiring on MBC1 cartridges, other cartridges are left untouched
+func (m MulticartMode) Apply(c Cartridge) {
+	if mbc, ok := c.(*MBC1); ok && m != MulticartAuto {
+		mbc.Multicart = m == MulticartOn
+	}
 }
 
 func (mbc *MBC1) Write(addr uint16, value uint8) {
@@ -93,7 +161,7 @@ func (mbc *MBC1) Write(addr uint16, value uint8) {
 	case 0xA000 <= addr && addr < 0xC000:
 		if mbc.ramEnabled {
 			RAMAddress := mbc.computeRamAddress(addr)
-			mbc.RAM[RAMAddress] = value
+			mbc.store(mbc.RAM, RAMAddress, value)
 		}
 	}
 }
@@ -115,19 +183,24 @@ func (mbc *MBC1) Read(addr uint16) uint8 {
 }
 
 func (mbc *MBC1) computeRomAddress(cpuAddress uint16) uint {
-	// bank number: 2 bits - 5 bits, cpuAddress: 14 bits
+	// bank number: 2 bits - 5 bits (2 bits - 4 bits on MBC1M), cpuAddress: 14 bits
 	var bankNumber uint8 = 0
 
+	lowBankNumber, highBankShift := mbc.romBankNumber, 5
+	if mbc.Multicart {
+		lowBankNumber, highBankShift = mbc.romBankNumber&0x0F, 4
+	}
+
 	switch {
 	case cpuAddress < 0x4000:
 		if mbc.bankingMode == 1 && mbc.useRamBankNumberAsHighRomBankNumber {
-			bankNumber = mbc.ramBankNumber << 5
+			bankNumber = mbc.ramBankNumber << highBankShift
 		}
 
 	case cpuAddress < 0x8000:
-		bankNumber = mbc.romBankNumber
+		bankNumber = lowBankNumber
 		if mbc.useRamBankNumberAsHighRomBankNumber {
-			bankNumber |= mbc.ramBankNumber << 5
+			bankNumber |= mbc.ramBankNumber << highBankShift
 		}
 
 	default:
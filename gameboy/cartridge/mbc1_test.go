@@ -0,0 +1,92 @@
+package cartridge
+
+import "testing"
+
+// multicartROM returns a 1 MiB MBC1 ROM made of 4 games, each bank starts with its number
+func multicartROM() []uint8 {
+	rom := testROM(0x100000, 0x01, 0x05, 0)
+	for bank := 0; bank < 64; bank++ {
+		rom[bank*0x4000] = uint8(bank)
+	}
+	for game := 0; game < 4; game++ {
+		copy(rom[game*0x40000+logo:], NintendoLogo[:])
+	}
+	return rom
+}
+
+func TestMBC1MDetection(t *testing.T) {
+	rom := multicartROM()
+	if !IsMulticart(rom) {
+		t.Errorf("multicart not detected")
+	}
+
+	// Only the first game has the logo
+	for game := 1; game < 4; game++ {
+		copy(rom[game*0x40000+logo:], make([]uint8, len(NintendoLogo)))
+	}
+	if IsMulticart(rom) {
+		t.Errorf("normal ROM detected as multicart")
+	}
+}
+
+func TestMBC1BankMapping(t *testing.T) {
+	tests := []struct {
+		name        string
+		multicart   bool
+		bankingMode uint8
+		romBank     uint8
+		ramBank     uint8
+		bank0       uint8 // Bank mapped at $0000-$3FFF
+		bankX       uint8 // Bank mapped at $4000-$7FFF
+	}{
+		{"MBC1/mode 0", false, 0, 0x13, 2, 0x00, 0x13}, // 0x53 wraps to 64 banks
+		{"MBC1/mode 1", false, 1, 0x05, 1, 0x20, 0x25},
+		{"MBC1/bank 0", false, 0, 0x00, 1, 0x00, 0x21},
+		{"MBC1M/mode 0", true, 0, 0x13, 2, 0x00, 0x23},
+		{"MBC1M/mode 1", true, 1, 0x05, 1, 0x10, 0x15},
+		{"MBC1M/mode 1 game 3", true, 1, 0x02, 3, 0x30, 0x32},
+		{"MBC1M/bit 4 ignored", true, 0, 0x10, 2, 0x00, 0x20},
+		{"MBC1M/bank 0", true, 0, 0x00, 1, 0x00, 0x11},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rom := multicartROM()
+			c, err := NewCartridge(rom, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mbc := c.(*MBC1)
+			if !mbc.Multicart {
+				t.Fatalf("multicart not detected")
+			}
+			mode := MulticartOff
+			if test.multicart {
+				mode = MulticartOn
+			}
+			mode.Apply(mbc)
+
+			mbc.Write(0x6000, test.bankingMode)
+			mbc.Write(0x2000, test.romBank)
+			mbc.Write(0x4000, test.ramBank)
+
+			if bank := mbc.Read(0x0000); bank != test.bank0 {
+				t.Errorf("$0000-$3FFF: got bank %02X, expected %02X", bank, test.bank0)
+			}
+			if bank := mbc.Read(0x4000); bank != test.bankX {
+				t.Errorf("$4000-$7FFF: got bank %02X, expected %02X", bank, test.bankX)
+			}
+		})
+	}
+}
+
+func TestParseMulticartMode(t *testing.T) {
+	for mode, expected := range map[string]MulticartMode{"auto": MulticartAuto, "ON": MulticartOn, "off": MulticartOff} {
+		if m, err := ParseMulticartMode(mode); err != nil || m != expected {
+			t.Errorf("%s: got %v (%v), expected %v", mode, m, err, expected)
+		}
+	}
+	if _, err := ParseMulticartMode("yes"); err == nil {
+		t.Errorf("expected error for invalid mode")
+	}
+}
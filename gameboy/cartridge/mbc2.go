@@ -1,8 +1,8 @@
 package cartridge
 
 import (
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
 	"github.com/danielecanzoneri/lucky-boy/util"
-	"log"
 )
 
 const (
@@ -12,6 +12,7 @@ const (
 type MBC2 struct {
 	header  *Header
 	battery bool
+	saveTracker
 
 	ROMBanks uint8
 
@@ -35,7 +36,19 @@ func (mbc *MBC2) Header() *Header {
 	return mbc.header
 }
 
-func NewMBC2(rom []uint8, savData []uint8, header *Header, battery bool) *MBC2 {
+func (mbc *MBC2) SaveState(e *savestate.Encoder) {
+	e.Bool(mbc.ramEnabled)
+	e.U8(mbc.romBankNumber)
+	e.Bytes(mbc.RAM[:])
+}
+
+func (mbc *MBC2) LoadState(d *savestate.Decoder) {
+	d.Bool(&mbc.ramEnabled)
+	d.U8(&mbc.romBankNumber)
+	d.BytesInto(mbc.RAM[:])
+}
+
+func NewMBC2(rom []uint8, savData []uint8, header *Header, battery bool) (*MBC2, error) {
 	mbc := &MBC2{
 		header:        header,
 		battery:       battery,
@@ -45,13 +58,12 @@ func NewMBC2(rom []uint8, savData []uint8, header *Header, battery bool) *MBC2 {
 	}
 
 	if savData != nil {
-		if battery && len(savData) != mbc2RAMLen {
-			log.Println("[WARN] sav file was of a different dimension than expected, resetting to zero")
-		} else {
-			copy(mbc.RAM[:], savData)
+		if len(savData) != mbc2RAMLen {
+			return nil, &SaveSizeError{Expected: mbc2RAMLen, Size: len(savData)}
 		}
+		copy(mbc.RAM[:], savData)
 	}
-	return mbc
+	return mbc, nil
 }
 
 func (mbc *MBC2) Write(addr uint16, value uint8) {
@@ -84,7 +96,7 @@ func (mbc *MBC2) Write(addr uint16, value uint8) {
 	case 0xA000 <= addr && addr < 0xC000:
 		if mbc.ramEnabled {
 			RAMAddress := mbc.computeRamAddress(addr)
-			mbc.RAM[RAMAddress] = 0xF0 | (value & 0x0F)
+			mbc.store(mbc.RAM[:], RAMAddress, 0xF0|(value&0x0F))
 		}
 	}
 }
@@ -1,7 +1,7 @@
 package cartridge
 
 import (
-	"encoding/binary"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
 	"github.com/danielecanzoneri/lucky-boy/util"
 	"log"
 	"time"
@@ -15,22 +15,36 @@ const (
 	rtcHoursMask = 0x1F
 	// Bit 7: carry bit, bit 6: RTC active, bit 0: bit 9 of day counter
 	rtcControlMask = 0b11000001
+
+	// In wall time mode the clock is polled about 64 times per second
+	rtcWallPollTicks = 1 << 16
 )
 
+// RTCRegisters are the MBC3 clock counters
+type RTCRegisters struct {
+	Seconds, Minutes, Hours uint8
+	Days                    uint16 // 9 bit day counter
+	Halt                    bool
+	Carry                   bool // Day counter overflow
+}
+
 type MBC3 struct {
 	header  *Header
 	battery bool // If battery is present RAM should be stored
 	rtc     bool // If RTC is enabled store RTC registers in SAV file
+	saveTracker
+	// MBC30 has an 8 bit ROM bank register (up to 4 MiB) and 8 RAM banks (64 KiB)
+	mbc30 bool
 
-	ROMBanks uint8
-	RAMBanks uint8
+	ROMBanks uint
+	RAMBanks uint
 
 	ROM []uint8
 	RAM []uint8
 
 	// Registers
 	ramEnabled    bool  // Also enables and disables RTC
-	romBankNumber uint8 // 7 bit register (if 0 is read as 1)
+	romBankNumber uint8 // 7 bit register, 8 bit on MBC30 (if 0 is read as 1)
 	// This register is also the RTC register if ranges between 08 and 0C,
 	// otherwise it selects which RAM bank to use
 	ramBankNumber uint8
@@ -46,28 +60,26 @@ type MBC3 struct {
 	lastWriteWas00  bool
 
 	rtcClockCounter int
+
+	clock    Clock
+	rtcMode  RTCMode
+	lastSync time.Time // Wall time the RTC was last advanced to
 }
 
 func (mbc *MBC3) RAMDump() []uint8 {
 	if mbc.battery {
 		if mbc.rtc {
-			dump := make([]uint8, len(mbc.RAM), len(mbc.RAM)+48)
+			dump := make([]uint8, len(mbc.RAM), len(mbc.RAM)+int(RTCFooter48))
 			copy(dump, mbc.RAM)
 
-			// Save RTC registers
-			dump, _ = binary.Append(dump, binary.LittleEndian, int32(mbc.rtcS))
-			dump, _ = binary.Append(dump, binary.LittleEndian, int32(mbc.rtcM))
-			dump, _ = binary.Append(dump, binary.LittleEndian, int32(mbc.rtcH))
-			dump, _ = binary.Append(dump, binary.LittleEndian, int32(mbc.rtcDL))
-			dump, _ = binary.Append(dump, binary.LittleEndian, int32(mbc.rtcDH))
-			dump, _ = binary.Append(dump, binary.LittleEndian, int32(mbc.lthRtcS))
-			dump, _ = binary.Append(dump, binary.LittleEndian, int32(mbc.lthRtcM))
-			dump, _ = binary.Append(dump, binary.LittleEndian, int32(mbc.lthRtcH))
-			dump, _ = binary.Append(dump, binary.LittleEndian, int32(mbc.lthRtcDL))
-			dump, _ = binary.Append(dump, binary.LittleEndian, int32(mbc.lthRtcDH))
-
-			// Save current timestamp
-			dump, _ = binary.Append(dump, binary.LittleEndian, time.Now().Unix())
+			footer := rtcFooter{
+				registers: [10]uint8{
+					mbc.rtcS, mbc.rtcM, mbc.rtcH, mbc.rtcDL, mbc.rtcDH,
+					mbc.lthRtcS, mbc.lthRtcM, mbc.lthRtcH, mbc.lthRtcDL, mbc.lthRtcDH,
+				},
+				timestamp: mbc.clock.Now().Unix(),
+			}
+			dump = footer.encode(dump, RTCFooter48)
 			return dump
 		}
 
@@ -82,45 +94,100 @@ func (mbc *MBC3) Header() *Header {
 	return mbc.header
 }
 
-func NewMBC3(rom []uint8, ram bool, savData []uint8, header *Header, battery bool, rtc bool) *MBC3 {
+func (mbc *MBC3) SaveState(e *savestate.Encoder) {
+	e.Bool(mbc.ramEnabled)
+	e.U8(mbc.romBankNumber)
+	e.U8(mbc.ramBankNumber)
+	e.Bytes(mbc.RAM)
+
+	// RTC
+	e.U8(mbc.rtcS)
+	e.U8(mbc.rtcM)
+	e.U8(mbc.rtcH)
+	e.U8(mbc.rtcDL)
+	e.U8(mbc.rtcDH)
+	e.U8(mbc.lthRtcS)
+	e.U8(mbc.lthRtcM)
+	e.U8(mbc.lthRtcH)
+	e.U8(mbc.lthRtcDL)
+	e.U8(mbc.lthRtcDH)
+	e.Bool(mbc.lastWriteWas00)
+	e.Int(mbc.rtcClockCounter)
+}
+
+func (mbc *MBC3) LoadState(d *savestate.Decoder) {
+	d.Bool(&mbc.ramEnabled)
+	d.U8(&mbc.romBankNumber)
+	d.U8(&mbc.ramBankNumber)
+	d.BytesInto(mbc.RAM)
+
+	// RTC
+	d.U8(&mbc.rtcS)
+	d.U8(&mbc.rtcM)
+	d.U8(&mbc.rtcH)
+	d.U8(&mbc.rtcDL)
+	d.U8(&mbc.rtcDH)
+	d.U8(&mbc.lthRtcS)
+	d.U8(&mbc.lthRtcM)
+	d.U8(&mbc.lthRtcH)
+	d.U8(&mbc.lthRtcDL)
+	d.U8(&mbc.lthRtcDH)
+	d.Bool(&mbc.lastWriteWas00)
+	d.Int(&mbc.rtcClockCounter)
+	mbc.lastSync = mbc.clock.Now()
+}
+
+func NewMBC3(rom []uint8, ram bool, savData []uint8, header *Header, battery bool, rtc bool, clock Clock) (*MBC3, error) {
+	if clock == nil {
+		clock = RealClock{}
+	}
+
 	mbc := &MBC3{
 		header:        header,
 		battery:       battery,
 		rtc:           rtc,
-		ROMBanks:      uint8(header.ROMBanks),
-		RAMBanks:      uint8(header.RAMBanks),
+		ROMBanks:      header.ROMBanks,
+		RAMBanks:      header.RAMBanks,
 		ROM:           rom,
 		romBankNumber: 1,
+		mbc30:         header.ROMBanks > 128 || header.RAMBanks > 4,
+		clock:         clock,
+		lastSync:      clock.Now(),
 	}
 	if ram && header.RAMBanks == 0 {
 		log.Println("[WARN] Cartridge header specifies RAM present, but RAM banks is set to 0")
 		mbc.RAMBanks = 1
 	}
 
-	var rtcLen int
-	if rtc {
-		rtcLen = 48
-	}
-
+	var ramLen int
 	if ram {
-		ramLen := int(mbc.RAMBanks) * 0x2000
-		switch {
-		case battery && len(savData) != ramLen+rtcLen:
-			log.Println("[WARN] sav file was of a different dimension than expected, resetting to zero")
-			fallthrough
+		ramLen = int(mbc.RAMBanks) * 0x2000
+	}
 
-		case savData == nil:
+	switch rtcLen := len(savData) - ramLen; {
+	case savData == nil:
+		if ram {
 			mbc.RAM = make([]uint8, ramLen)
+		}
 
-		default: // SAV data is of correct format
+	case rtcLen == 0 && (rtc || ram), // Save without RTC data
+		rtc && (rtcLen == int(RTCFooter44) || rtcLen == int(RTCFooter48)):
+		if ram {
 			mbc.RAM = savData[:ramLen]
-			if rtc {
-				mbc.parseRTCData(savData[ramLen:])
-			}
 		}
+		if rtc && rtcLen > 0 {
+			mbc.parseRTCData(savData[ramLen:])
+		}
+
+	default:
+		expected := ramLen
+		if rtc {
+			expected += int(RTCFooter48)
+		}
+		return nil, &SaveSizeError{Expected: expected, Size: len(savData)}
 	}
 
-	return mbc
+	return mbc, nil
 }
 
 func (mbc *MBC3) Tick(ticks int) {
@@ -129,28 +196,55 @@ func (mbc *MBC3) Tick(ticks int) {
 		return
 	}
 
-	// RTC clocking: Game Boy runs at 2^22 Hz
 	mbc.rtcClockCounter += ticks
-	if mbc.rtcClockCounter >= 1<<22 {
-		mbc.rtcClockCounter -= 1 << 22
-
-		mbc.rtcS = (mbc.rtcS + 1) & rtcSecondsMask
-		if mbc.rtcS == 60 {
-			mbc.rtcS = 0
-			mbc.rtcM = (mbc.rtcM + 1) & rtcMinutesMask
-			if mbc.rtcM == 60 {
-				mbc.rtcM = 0
-				mbc.rtcH = (mbc.rtcH + 1) & rtcHoursMask
-				if mbc.rtcH == 24 {
-					mbc.rtcH = 0
-					mbc.rtcDL++
-					if mbc.rtcDL == 0 { // rtcDH bit 0 is bit 9 of day counter
-						if util.ReadBit(mbc.rtcDH, 0) == 0 {
-							util.SetBit(&mbc.rtcDH, 0, 1)
-						} else { // Set carry bit
-							util.SetBit(&mbc.rtcDH, 0, 0)
-							util.SetBit(&mbc.rtcDH, 7, 1)
-						}
+	switch mbc.rtcMode {
+	case RTCEmulated:
+		// RTC clocking: Game Boy runs at 2^22 Hz
+		if mbc.rtcClockCounter >= 1<<22 {
+			mbc.rtcClockCounter -= 1 << 22
+			mbc.advanceRTC(1)
+		}
+
+	case RTCWallTime:
+		if mbc.rtcClockCounter >= rtcWallPollTicks {
+			mbc.rtcClockCounter = 0
+			mbc.syncWallTime()
+		}
+	}
+}
+
+// syncWallTime advances the RTC by the whole seconds elapsed on the clock since the last sync
+func (mbc *MBC3) syncWallTime() {
+	now := mbc.clock.Now()
+	elapsed := now.Sub(mbc.lastSync) / time.Second
+	switch {
+	case elapsed > 0:
+		mbc.advanceRTC(int64(elapsed))
+		mbc.lastSync = mbc.lastSync.Add(elapsed * time.Second)
+	case elapsed < 0: // Clock moved backwards
+		mbc.lastSync = now
+	}
+}
+
+// incrementSecond advances the RTC by a second. Counters holding invalid values
+// (e.g. 60-63 seconds) overflow at the register size without carrying to the next one.
+func (mbc *MBC3) incrementSecond() {
+	mbc.rtcS = (mbc.rtcS + 1) & rtcSecondsMask
+	if mbc.rtcS == 60 {
+		mbc.rtcS = 0
+		mbc.rtcM = (mbc.rtcM + 1) & rtcMinutesMask
+		if mbc.rtcM == 60 {
+			mbc.rtcM = 0
+			mbc.rtcH = (mbc.rtcH + 1) & rtcHoursMask
+			if mbc.rtcH == 24 {
+				mbc.rtcH = 0
+				mbc.rtcDL++
+				if mbc.rtcDL == 0 { // rtcDH bit 0 is bit 9 of day counter
+					if util.ReadBit(mbc.rtcDH, 0) == 0 {
+						util.SetBit(&mbc.rtcDH, 0, 1)
+					} else { // Set carry bit
+						util.SetBit(&mbc.rtcDH, 0, 0)
+						util.SetBit(&mbc.rtcDH, 7, 1)
 					}
 				}
 			}
@@ -158,6 +252,94 @@ func (mbc *MBC3) Tick(ticks int) {
 	}
 }
 
+// advanceRTC advances the RTC counters by the given number of seconds
+func (mbc *MBC3) advanceRTC(seconds int64) {
+	// Invalid values don't carry, step second by second until all counters are valid
+	for seconds > 0 && (mbc.rtcS >= 60 || mbc.rtcM >= 60 || mbc.rtcH >= 24) {
+		mbc.incrementSecond()
+		seconds--
+	}
+	if seconds <= 0 {
+		return
+	}
+
+	total := int64(mbc.rtcS) + seconds
+	mbc.rtcS = uint8(total % 60)
+
+	total = int64(mbc.rtcM) + total/60
+	mbc.rtcM = uint8(total % 60)
+
+	total = int64(mbc.rtcH) + total/60
+	mbc.rtcH = uint8(total % 24)
+
+	days := (int64(mbc.rtcDL) | int64(mbc.rtcDH&1)<<8) + total/24
+	mbc.rtcDL = uint8(days)                      // Set Day low
+	util.SetBit(&mbc.rtcDH, 0, uint8(days>>8)&1) // Set Day high
+	if days > 0x1FF {
+		util.SetBit(&mbc.rtcDH, 7, 1) // Set Overflow
+	}
+}
+
+// HasRTC reports whether the cartridge has a real time clock
+func (mbc *MBC3) HasRTC() bool {
+	return mbc.rtc
+}
+
+// RTC returns the current (not latched) value of the clock counters
+func (mbc *MBC3) RTC() RTCRegisters {
+	return RTCRegisters{
+		Seconds: mbc.rtcS,
+		Minutes: mbc.rtcM,
+		Hours:   mbc.rtcH,
+		Days:    uint16(mbc.rtcDL) | uint16(mbc.rtcDH&1)<<8,
+		Halt:    util.ReadBit(mbc.rtcDH, 6) == 1,
+		Carry:   util.ReadBit(mbc.rtcDH, 7) == 1,
+	}
+}
+
+// SetRTC sets the clock counters, as if written by the game
+func (mbc *MBC3) SetRTC(r RTCRegisters) {
+	mbc.rtcS = r.Seconds & rtcSecondsMask
+	mbc.rtcM = r.Minutes & rtcMinutesMask
+	mbc.rtcH = r.Hours & rtcHoursMask
+	mbc.rtcDL = uint8(r.Days)
+
+	dh := uint8(r.Days>>8) & 1
+	if r.Halt {
+		dh |= 1 << 6
+	}
+	if r.Carry {
+		dh |= 1 << 7
+	}
+	mbc.writeRTCControl(dh)
+	mbc.rtcClockCounter = 0
+}
+
+// AdvanceRTC moves the clock forward as if the time elapsed (nothing happens if it's halted)
+func (mbc *MBC3) AdvanceRTC(d time.Duration) {
+	if util.ReadBit(mbc.rtcDH, 6) == 0 {
+		mbc.advanceRTC(int64(d / time.Second))
+	}
+}
+
+func (mbc *MBC3) RTCMode() RTCMode {
+	return mbc.rtcMode
+}
+
+func (mbc *MBC3) SetRTCMode(mode RTCMode) {
+	mbc.rtcMode = mode
+	mbc.rtcClockCounter = 0
+	mbc.lastSync = mbc.clock.Now()
+}
+
+func (mbc *MBC3) writeRTCControl(value uint8) {
+	// Time doesn't elapse while the clock is halted
+	if util.ReadBit(mbc.rtcDH, 6) == 1 {
+		mbc.lastSync = mbc.clock.Now()
+	}
+	mbc.rtcDH = value & rtcControlMask
+}
+
 func (mbc *MBC3) Write(addr uint16, value uint8) {
 	// Set MBC3 registers
 	switch {
@@ -166,8 +348,10 @@ func (mbc *MBC3) Write(addr uint16, value uint8) {
 		mbc.ramEnabled = value&0x0F == 0xA
 
 	case addr < 0x4000:
-		// Only lower 7 bits are used
-		value = value & 0x7F
+		// Only lower 7 bits are used (8 on MBC30)
+		if !mbc.mbc30 {
+			value = value & 0x7F
+		}
 
 		// Register 0 behaves as 1
 		if value == 0 {
@@ -202,6 +386,7 @@ func (mbc *MBC3) Write(addr uint16, value uint8) {
 			case 0x8:
 				mbc.rtcS = value & rtcSecondsMask
 				mbc.rtcClockCounter = 0
+				mbc.lastSync = mbc.clock.Now()
 			case 0x9:
 				mbc.rtcM = value & rtcMinutesMask
 			case 0xA:
@@ -209,11 +394,11 @@ func (mbc *MBC3) Write(addr uint16, value uint8) {
 			case 0xB:
 				mbc.rtcDL = value
 			case 0xC:
-				mbc.rtcDH = value & rtcControlMask
+				mbc.writeRTCControl(value)
 			default:
 				// Access RAM
 				RAMAddress := mbc.computeRamAddress(addr)
-				mbc.RAM[RAMAddress] = value
+				mbc.store(mbc.RAM, RAMAddress, value)
 			}
 		}
 	}
@@ -249,13 +434,13 @@ func (mbc *MBC3) Read(addr uint16) uint8 {
 }
 
 func (mbc *MBC3) computeRomAddress(cpuAddress uint16) uint {
-	// bank number: 7 bits, cpuAddress: 14 bits
-	var bankNumber uint8 = 0
+	// bank number: 7 bits (8 bits on MBC30), cpuAddress: 14 bits
+	var bankNumber uint = 0
 
 	switch {
 	case cpuAddress < 0x4000: // bank number = 0
 	case cpuAddress < 0x8000:
-		bankNumber = mbc.romBankNumber
+		bankNumber = uint(mbc.romBankNumber)
 
 	default:
 		panic("should never happen")
@@ -264,18 +449,18 @@ func (mbc *MBC3) computeRomAddress(cpuAddress uint16) uint {
 	// Bank number is masked to the required number of bits
 	bankNumber %= mbc.ROMBanks
 
-	return uint(bankNumber)<<14 | uint(cpuAddress&0x3FFF)
+	return bankNumber<<14 | uint(cpuAddress&0x3FFF)
 }
 
 func (mbc *MBC3) computeRamAddress(cpuAddress uint16) uint {
-	// bank number: 2 bits, cpuAddress: 13 bits
+	// bank number: 2 bits (3 bits on MBC30), cpuAddress: 13 bits
 	switch {
 	case 0xA000 <= cpuAddress && cpuAddress < 0xC000:
 		cpuAddress = cpuAddress & 0x1FFF
 
 		// Bank number is masked to the required number of bits
-		bank := mbc.ramBankNumber % mbc.RAMBanks
-		return uint(bank)<<13 | uint(cpuAddress)
+		bank := uint(mbc.ramBankNumber) % mbc.RAMBanks
+		return bank<<13 | uint(cpuAddress)
 
 	default:
 		panic("should never happen")
@@ -283,11 +468,6 @@ func (mbc *MBC3) computeRamAddress(cpuAddress uint16) uint {
 }
 
 func (mbc *MBC3) parseRTCData(data []uint8) {
-	if len(data) != 48 {
-		log.Println("[WARN] invalid RTC data length")
-		return
-	}
-
 	// If RTC is present, additional data at end of SAV file should contain value of registers:
 	// offset  size    desc
 	// 0       4       time seconds
@@ -300,53 +480,22 @@ func (mbc *MBC3) parseRTCData(data []uint8) {
 	// 28      4       latched time hours
 	// 32      4       latched time days
 	// 36      4       latched time days high
-	// 40      8       unix timestamp when saving (64 bits little endian)
-	// AFAIK a byte is saved as an int so extra bytes are 0
-	mbc.rtcS = data[0]
-	mbc.rtcM = data[4]
-	mbc.rtcH = data[8]
-	mbc.rtcDL = data[12]
-	mbc.rtcDH = data[16]
-	mbc.lthRtcS = data[20]
-	mbc.lthRtcM = data[24]
-	mbc.lthRtcH = data[28]
-	mbc.lthRtcDL = data[32]
-	mbc.lthRtcDH = data[36]
+	// 40      4/8     unix timestamp when saving (32 or 64 bits little endian)
+	footer, ok := decodeRTCFooter(data)
+	if !ok {
+		log.Println("[WARN] invalid RTC data length")
+		return
+	}
+
+	r := footer.registers
+	mbc.rtcS, mbc.rtcM, mbc.rtcH, mbc.rtcDL, mbc.rtcDH = r[0], r[1], r[2], r[3], r[4]
+	mbc.lthRtcS, mbc.lthRtcM, mbc.lthRtcH, mbc.lthRtcDL, mbc.lthRtcDH = r[5], r[6], r[7], r[8], r[9]
 
 	// If RTC was enabled, advance registers for the time elapsed
 	if util.ReadBit(mbc.rtcDH, 6) == 0 {
-		timestamp := binary.LittleEndian.Uint64(data[40:])
-
-		// Compute how much time has passed and update the registers accordingly
-		saveTime := time.Unix(int64(timestamp), 0)
-		elapsed := time.Since(saveTime)
-
-		// Update seconds
-		seconds := int(elapsed.Seconds()) % 60
-		mbc.rtcS += uint8(seconds)
-		extraMin := mbc.rtcS / 60
-		mbc.rtcS %= 60
-
-		// Update minutes
-		minutes := int(elapsed.Minutes()) % 60
-		mbc.rtcM += uint8(minutes) + extraMin
-		extraHour := mbc.rtcM / 60
-		mbc.rtcM %= 60
-
-		// Update hours
-		hours := int(elapsed.Hours()) % 24
-		mbc.rtcH += uint8(hours) + extraHour
-		extraDay := mbc.rtcH / 24
-		mbc.rtcH %= 24
-
-		// Update days
-		days := int(elapsed.Hours()) / 24
-		rtcDays := int(mbc.rtcDL) | (int(mbc.rtcDH&1) << 8)
-		totalDays := days + rtcDays + int(extraDay)
-		mbc.rtcDL = uint8(totalDays)                      // Set Day low
-		util.SetBit(&mbc.rtcDH, 0, uint8(totalDays>>8)&1) // Set Day high
-		if totalDays > 0x1FF {
-			util.SetBit(&mbc.rtcDH, 7, 1) // Set Overflow
+		saveTime := time.Unix(footer.timestamp, 0)
+		if elapsed := mbc.clock.Now().Sub(saveTime); elapsed > 0 {
+			mbc.advanceRTC(int64(elapsed / time.Second))
 		}
 	}
 }
@@ -0,0 +1,183 @@
+package cartridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMBC3BankSelection(t *testing.T) {
+	tests := []struct {
+		name        string
+		romSizeCode uint8
+		ramSizeCode uint8
+		mbc30       bool
+		romBanks    int
+		ramBanks    int
+	}{
+		{"MBC3", 0x06, 0x03, false, 128, 4},
+		{"MBC30", 0x07, 0x05, true, 256, 8},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rom := testROM(test.romBanks*0x4000, 0x10, test.romSizeCode, test.ramSizeCode)
+			for bank := 0; bank < test.romBanks; bank++ {
+				rom[bank*0x4000+0x100] = uint8(bank)
+			}
+			c, err := NewCartridge(rom, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mbc := c.(*MBC3)
+			if mbc.mbc30 != test.mbc30 {
+				t.Errorf("MBC30 detected: %v", mbc.mbc30)
+			}
+
+			// ROM banks
+			for bank := 0; bank < 256; bank++ {
+				mbc.Write(0x2000, uint8(bank))
+				expected := bank % test.romBanks
+				if !test.mbc30 {
+					expected = bank & 0x7F
+				}
+				if expected == 0 {
+					expected = 1
+				}
+				if got := mbc.Read(0x4100); int(got) != expected {
+					t.Errorf("bank %02X: got ROM bank %02X, expected %02X", bank, got, expected)
+				}
+			}
+
+			// RAM banks
+			mbc.Write(0x0000, 0x0A)
+			for bank := 0; bank < test.ramBanks; bank++ {
+				mbc.Write(0x4000, uint8(bank))
+				mbc.Write(0xA000, uint8(0x10+bank))
+			}
+			for bank := 0; bank < test.ramBanks; bank++ {
+				if got := mbc.RAM[bank*0x2000]; got != uint8(0x10+bank) {
+					t.Errorf("RAM bank %d: got %02X", bank, got)
+				}
+			}
+
+			// RTC registers are still selected with $08-$0C
+			mbc.Write(0x4000, 0x0A)
+			mbc.Write(0xA000, 0x05)
+			mbc.Write(0x6000, 0x00)
+			mbc.Write(0x6000, 0x01)
+			if h := mbc.Read(0xA000); h != 0x05 {
+				t.Errorf("RTC hours: got %02X", h)
+			}
+
+			// RAM followed by the 48 bytes of RTC data
+			dump := mbc.RAMDump()
+			if len(dump) != test.ramBanks*0x2000+48 {
+				t.Fatalf("got save of %d bytes", len(dump))
+			}
+			c, err = NewCartridge(rom, dump)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if loaded := c.(*MBC3); loaded.rtcH != 0x05 || loaded.RAM[(test.ramBanks-1)*0x2000] != uint8(0x10+test.ramBanks-1) {
+				t.Errorf("save not restored")
+			}
+		})
+	}
+}
+
+// manualClock is a clock moved by the test
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+func newRTCCartridge(t *testing.T, savData []uint8, clock Clock) *MBC3 {
+	t.Helper()
+	c, err := NewCartridgeWithClock(testROM(0x8000, 0x10, 0x00, 0x02), savData, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*MBC3)
+}
+
+func TestMBC3RTCClock(t *testing.T) {
+	saveTime := time.Date(2001, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	mbc := newRTCCartridge(t, nil, FrozenClock{Time: saveTime})
+	mbc.SetRTC(RTCRegisters{Seconds: 50, Minutes: 59, Hours: 23, Days: 0x1FF})
+	dump := mbc.RAMDump()
+
+	// Same save and clock always give the same RTC
+	loaded := newRTCCartridge(t, dump, FrozenClock{Time: saveTime})
+	if rtc := loaded.RTC(); rtc != mbc.RTC() {
+		t.Errorf("frozen clock: got %+v", rtc)
+	}
+
+	// 10 seconds later the day counter overflows
+	loaded = newRTCCartridge(t, dump, FrozenClock{Time: saveTime.Add(10 * time.Second)})
+	expected := RTCRegisters{Carry: true}
+	if rtc := loaded.RTC(); rtc != expected {
+		t.Errorf("after 10 seconds: got %+v, expected %+v", rtc, expected)
+	}
+
+	// A halted clock doesn't advance
+	mbc.SetRTC(RTCRegisters{Hours: 1, Halt: true})
+	loaded = newRTCCartridge(t, mbc.RAMDump(), FrozenClock{Time: saveTime.Add(time.Hour)})
+	if rtc := loaded.RTC(); rtc.Hours != 1 {
+		t.Errorf("halted clock: got %+v", rtc)
+	}
+}
+
+func TestMBC3RTCAdvance(t *testing.T) {
+	mbc := newRTCCartridge(t, nil, FrozenClock{})
+
+	mbc.AdvanceRTC(3*24*time.Hour + 2*time.Hour + 3*time.Minute + 4*time.Second)
+	expected := RTCRegisters{Seconds: 4, Minutes: 3, Hours: 2, Days: 3}
+	if rtc := mbc.RTC(); rtc != expected {
+		t.Errorf("got %+v, expected %+v", rtc, expected)
+	}
+
+	// Invalid seconds overflow at 64 without incrementing minutes
+	mbc.SetRTC(RTCRegisters{Seconds: 62})
+	mbc.AdvanceRTC(3 * time.Second)
+	expected = RTCRegisters{Seconds: 1}
+	if rtc := mbc.RTC(); rtc != expected {
+		t.Errorf("invalid seconds: got %+v, expected %+v", rtc, expected)
+	}
+}
+
+func TestMBC3RTCModes(t *testing.T) {
+	clock := &manualClock{now: time.Unix(1000, 0)}
+	mbc := newRTCCartridge(t, nil, clock)
+
+	// Emulated time: a second every 2^22 ticks, the wall clock is ignored
+	clock.now = clock.now.Add(time.Hour)
+	mbc.Tick(1 << 22)
+	if rtc := mbc.RTC(); rtc.Seconds != 1 || rtc.Hours != 0 {
+		t.Errorf("emulated time: got %+v", rtc)
+	}
+
+	// Wall time: the RTC follows the clock
+	mbc.SetRTCMode(RTCWallTime)
+	clock.now = clock.now.Add(90 * time.Second)
+	mbc.Tick(rtcWallPollTicks)
+	if rtc := mbc.RTC(); rtc.Minutes != 1 || rtc.Seconds != 31 {
+		t.Errorf("wall time: got %+v", rtc)
+	}
+
+	// Time elapsed while halted is lost
+	mbc.Write(0x0000, 0x0A)
+	mbc.Write(0x4000, 0x0C)
+	mbc.Write(0xA000, 0x40)
+	clock.now = clock.now.Add(time.Hour)
+	mbc.Tick(rtcWallPollTicks)
+	mbc.Write(0xA000, 0x00)
+	clock.now = clock.now.Add(time.Second)
+	mbc.Tick(rtcWallPollTicks)
+	if rtc := mbc.RTC(); rtc.Minutes != 1 || rtc.Seconds != 32 || rtc.Hours != 0 {
+		t.Errorf("halted wall time: got %+v", rtc)
+	}
+}
@@ -1,6 +1,7 @@
 package cartridge
 
 import (
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
 	"github.com/danielecanzoneri/lucky-boy/util"
 	"log"
 )
@@ -9,6 +10,8 @@ type MBC5 struct {
 	header  *Header
 	battery bool // If battery is present RAM should be stored
 	rumble  bool // If rumble motor is present on cartridge
+	rumbleMotor
+	saveTracker
 
 	ROMBanks uint // Up to 512
 	RAMBanks uint8
@@ -34,7 +37,23 @@ func (mbc *MBC5) Header() *Header {
 	return mbc.header
 }
 
-func NewMBC5(rom []uint8, ram bool, savData []uint8, header *Header, battery bool, rumble bool) *MBC5 {
+func (mbc *MBC5) SaveState(e *savestate.Encoder) {
+	e.Bool(mbc.ramEnabled)
+	e.Uint(mbc.romBankNumber)
+	e.U8(mbc.ramBankNumber)
+	e.Bytes(mbc.RAM)
+	mbc.rumbleMotor.saveState(e)
+}
+
+func (mbc *MBC5) LoadState(d *savestate.Decoder) {
+	d.Bool(&mbc.ramEnabled)
+	d.Uint(&mbc.romBankNumber)
+	d.U8(&mbc.ramBankNumber)
+	d.BytesInto(mbc.RAM)
+	mbc.rumbleMotor.loadState(d)
+}
+
+func NewMBC5(rom []uint8, ram bool, savData []uint8, header *Header, battery bool, rumble bool) (*MBC5, error) {
 	mbc := &MBC5{
 		header:        header,
 		battery:       battery,
@@ -50,17 +69,24 @@ func NewMBC5(rom []uint8, ram bool, savData []uint8, header *Header, battery boo
 	}
 
 	if ram {
+		ramLen := int(mbc.RAMBanks) * 0x2000
 		switch {
-		case battery && len(savData) != int(mbc.RAMBanks)*0x2000:
-			log.Println("[WARN] sav file was of a different dimension than expected, resetting to zero")
-			fallthrough
 		case savData == nil:
-			savData = make([]uint8, int(mbc.RAMBanks)*0x2000)
+			savData = make([]uint8, ramLen)
+		case len(savData) != ramLen:
+			return nil, &SaveSizeError{Expected: ramLen, Size: len(savData)}
 		}
 		mbc.RAM = savData
 	}
 
-	return mbc
+	return mbc, nil
+}
+
+// Tick measures the rumble motor duty cycle
+func (mbc *MBC5) Tick(ticks int) {
+	if mbc.rumble {
+		mbc.rumbleMotor.tick(ticks)
+	}
 }
 
 func (mbc *MBC5) Write(addr uint16, value uint8) {
@@ -81,15 +107,12 @@ func (mbc *MBC5) Write(addr uint16, value uint8) {
 		mbc.romBankNumber |= uint(value&1) << 8
 
 	case addr < 0x6000:
-		// Only lower 4 bits are used
-		mbc.ramBankNumber = value & 0xF
-
+		// Only lower 4 bits are used, on rumble carts bit 3 drives the motor
 		if mbc.rumble {
-			if util.ReadBit(value, 3) == 1 {
-				// Rumble
-			} else {
-				// Stop rumble
-			}
+			mbc.ramBankNumber = value & 0x7
+			mbc.rumbleMotor.set(util.ReadBit(value, 3) == 1)
+		} else {
+			mbc.ramBankNumber = value & 0xF
 		}
 
 	case addr < 0x8000:
@@ -98,7 +121,7 @@ func (mbc *MBC5) Write(addr uint16, value uint8) {
 	case 0xA000 <= addr && addr < 0xC000:
 		if mbc.ramEnabled {
 			RAMAddress := mbc.computeRamAddress(addr)
-			mbc.RAM[RAMAddress] = value
+			mbc.store(mbc.RAM, RAMAddress, value)
 		}
 	}
 }
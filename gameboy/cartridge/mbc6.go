@@ -0,0 +1,264 @@
+package cartridge
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+
+const (
+	mbc6RAMLen   = 0x8000   // 8 banks of 4 KiB
+	mbc6FlashLen = 0x100000 // 128 banks of 8 KiB
+
+	// Sector erased by the erase command
+	mbc6FlashSectorLen = 0x20000
+)
+
+// Flash command sequence states
+const (
+	flashIdle uint8 = iota
+	flashUnlock1
+	flashUnlock2
+)
+
+// MBC6 has two switchable 8 KiB windows ($4000-$5FFF, $6000-$7FFF) mapping either ROM or
+// flash memory, and two switchable 4 KiB RAM windows ($A000-$AFFF, $B000-$BFFF).
+// Flash memory is stored in the SAV file after RAM.
+type MBC6 struct {
+	header *Header
+	saveTracker
+
+	ROMBanks uint // 8 KiB banks
+
+	ROM   []uint8
+	RAM   []uint8
+	Flash []uint8
+
+	// Registers
+	ramEnabled        bool
+	ramBank           [2]uint8
+	flashEnabled      bool
+	flashWriteEnabled bool
+	romBank           [2]uint8
+	flashSelected     [2]bool
+
+	// Flash command state
+	flashState   uint8
+	flashErase   bool // Erase command prefix received
+	flashProgram bool // Next write programs a byte
+	flashIDMode  bool
+}
+
+func (mbc *MBC6) RAMDump() []uint8 {
+	dump := make([]uint8, 0, mbc6RAMLen+mbc6FlashLen)
+	dump = append(dump, mbc.RAM...)
+	return append(dump, mbc.Flash...)
+}
+
+func (mbc *MBC6) Header() *Header {
+	return mbc.header
+}
+
+func (mbc *MBC6) SaveState(e *savestate.Encoder) {
+	e.Bool(mbc.ramEnabled)
+	e.U8s(mbc.ramBank[:])
+	e.Bool(mbc.flashEnabled)
+	e.Bool(mbc.flashWriteEnabled)
+	e.U8s(mbc.romBank[:])
+	e.Bool(mbc.flashSelected[0])
+	e.Bool(mbc.flashSelected[1])
+	e.U8(mbc.flashState)
+	e.Bool(mbc.flashErase)
+	e.Bool(mbc.flashProgram)
+	e.Bool(mbc.flashIDMode)
+	e.Bytes(mbc.RAM)
+	e.Bytes(mbc.Flash)
+}
+
+func (mbc *MBC6) LoadState(d *savestate.Decoder) {
+	d.Bool(&mbc.ramEnabled)
+	d.U8s(mbc.ramBank[:])
+	d.Bool(&mbc.flashEnabled)
+	d.Bool(&mbc.flashWriteEnabled)
+	d.U8s(mbc.romBank[:])
+	d.Bool(&mbc.flashSelected[0])
+	d.Bool(&mbc.flashSelected[1])
+	d.U8(&mbc.flashState)
+	d.Bool(&mbc.flashErase)
+	d.Bool(&mbc.flashProgram)
+	d.Bool(&mbc.flashIDMode)
+	d.BytesInto(mbc.RAM)
+	d.BytesInto(mbc.Flash)
+}
+
+func NewMBC6(rom []uint8, savData []uint8, header *Header) (*MBC6, error) {
+	mbc := &MBC6{
+		header:   header,
+		ROMBanks: header.ROMBanks * 2,
+		ROM:      rom,
+		RAM:      make([]uint8, mbc6RAMLen),
+		Flash:    make([]uint8, mbc6FlashLen),
+	}
+
+	// Erased flash
+	for i := range mbc.Flash {
+		mbc.Flash[i] = 0xFF
+	}
+
+	switch len(savData) {
+	case 0:
+	case mbc6RAMLen: // Save without flash
+		copy(mbc.RAM, savData)
+	case mbc6RAMLen + mbc6FlashLen:
+		copy(mbc.RAM, savData)
+		copy(mbc.Flash, savData[mbc6RAMLen:])
+	default:
+		return nil, &SaveSizeError{Expected: mbc6RAMLen + mbc6FlashLen, Size: len(savData)}
+	}
+
+	return mbc, nil
+}
+
+func (mbc *MBC6) Write(addr uint16, value uint8) {
+	switch {
+	case addr < 0x0400:
+		mbc.ramEnabled = value&0x0F == 0xA
+	case addr < 0x0800:
+		mbc.ramBank[0] = value & 0x7
+	case addr < 0x0C00:
+		mbc.ramBank[1] = value & 0x7
+	case addr < 0x1000:
+		mbc.flashEnabled = value&1 == 1
+	case addr < 0x2000:
+		mbc.flashWriteEnabled = value&1 == 1
+
+	case addr < 0x2800:
+		mbc.romBank[0] = value & 0x7F
+	case addr < 0x3000:
+		mbc.flashSelected[0] = value&0x08 != 0
+	case addr < 0x3800:
+		mbc.romBank[1] = value & 0x7F
+	case addr < 0x4000:
+		mbc.flashSelected[1] = value&0x08 != 0
+
+	case addr < 0x8000:
+		window := (addr - 0x4000) >> 13
+		if mbc.flashSelected[window] && mbc.flashEnabled {
+			mbc.writeFlash(mbc.windowAddress(window, addr), value)
+		}
+
+	case 0xA000 <= addr && addr < 0xC000:
+		if mbc.ramEnabled {
+			mbc.store(mbc.RAM, mbc.computeRamAddress(addr), value)
+		}
+	}
+}
+
+func (mbc *MBC6) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0x4000:
+		return mbc.ROM[addr]
+
+	case addr < 0x8000:
+		window := (addr - 0x4000) >> 13
+		address := mbc.windowAddress(window, addr)
+		if !mbc.flashSelected[window] {
+			return mbc.ROM[address%(mbc.ROMBanks*0x2000)]
+		}
+
+		if mbc.flashIDMode {
+			// Manufacturer and device ID of the Macronix MX29F008
+			switch address & 0xFF {
+			case 0:
+				return 0xC2
+			case 1:
+				return 0x81
+			}
+			return 0x00
+		}
+		return mbc.Flash[address%mbc6FlashLen]
+
+	case 0xA000 <= addr && addr < 0xC000:
+		if mbc.ramEnabled {
+			return mbc.RAM[mbc.computeRamAddress(addr)]
+		}
+	}
+
+	return 0xFF
+}
+
+func (mbc *MBC6) windowAddress(window uint16, cpuAddress uint16) uint {
+	return uint(mbc.romBank[window])<<13 | uint(cpuAddress&0x1FFF)
+}
+
+func (mbc *MBC6) computeRamAddress(cpuAddress uint16) uint {
+	window := (cpuAddress - 0xA000) >> 12
+	return uint(mbc.ramBank[window])<<12 | uint(cpuAddress&0x0FFF)
+}
+
+// writeFlash handles the JEDEC command sequences of the flash chip:
+// commands are preceded by writing $AA to $5555 and $55 to $2AAA
+func (mbc *MBC6) writeFlash(address uint, value uint8) {
+	address %= mbc6FlashLen
+
+	if mbc.flashProgram {
+		mbc.flashProgram = false
+		// Programming can only clear bits
+		if mbc.flashWriteEnabled {
+			mbc.store(mbc.Flash, address, mbc.Flash[address]&value)
+		}
+		return
+	}
+
+	commandAddress := address & 0x7FFF
+	switch mbc.flashState {
+	case flashIdle:
+		switch {
+		case commandAddress == 0x5555 && value == 0xAA:
+			mbc.flashState = flashUnlock1
+		case value == 0xF0: // Reset
+			mbc.flashIDMode = false
+			mbc.flashErase = false
+		}
+
+	case flashUnlock1:
+		if commandAddress == 0x2AAA && value == 0x55 {
+			mbc.flashState = flashUnlock2
+		} else {
+			mbc.flashState = flashIdle
+		}
+
+	case flashUnlock2:
+		mbc.flashState = flashIdle
+
+		if mbc.flashErase {
+			mbc.flashErase = false
+			switch {
+			case value == 0x30: // Sector erase
+				mbc.eraseFlash(address&^(mbc6FlashSectorLen-1), mbc6FlashSectorLen)
+			case value == 0x10 && commandAddress == 0x5555: // Chip erase
+				mbc.eraseFlash(0, mbc6FlashLen)
+			}
+			return
+		}
+
+		if commandAddress != 0x5555 {
+			return
+		}
+		switch value {
+		case 0x80:
+			mbc.flashErase = true
+		case 0x90:
+			mbc.flashIDMode = true
+		case 0xA0:
+			mbc.flashProgram = true
+		case 0xF0:
+			mbc.flashIDMode = false
+		}
+	}
+}
+
+func (mbc *MBC6) eraseFlash(start, length uint) {
+	if !mbc.flashWriteEnabled {
+		return
+	}
+	for i := start; i < start+length; i++ {
+		mbc.store(mbc.Flash, i, 0xFF)
+	}
+}
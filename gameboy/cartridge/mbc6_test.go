@@ -0,0 +1,81 @@
+package cartridge
+
+import "testing"
+
+func TestMBC6Flash(t *testing.T) {
+	c, err := NewCartridge(testROM(0x100000, 0x20, 0x05, 0x03), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbc := c.(*MBC6)
+
+	// Map flash banks 2 and 5 (flash addresses $4000 and $A000)
+	mbc.Write(0x0C00, 0x01)
+	mbc.Write(0x2000, 0x02)
+	mbc.Write(0x2800, 0x08)
+	mbc.Write(0x3000, 0x05)
+	mbc.Write(0x3800, 0x08)
+
+	// $5555 and $2AAA of the flash are in bank 2 and 1, bank 2 is mapped at $4000
+	command := func(cmd uint8) {
+		mbc.Write(0x5555, 0xAA)
+		mbc.Write(0x2000, 0x01)
+		mbc.Write(0x4AAA, 0x55)
+		mbc.Write(0x2000, 0x02)
+		mbc.Write(0x5555, cmd)
+	}
+	program := func(addr uint16, value uint8) {
+		command(0xA0)
+		mbc.Write(addr, value)
+	}
+
+	// Writes are ignored until enabled
+	program(0x6010, 0x12)
+	if v := mbc.Read(0x6010); v != 0xFF {
+		t.Errorf("write protected flash programmed: %02X", v)
+	}
+
+	mbc.Write(0x1000, 0x01)
+	program(0x6010, 0x12)
+	if v := mbc.Read(0x6010); v != 0x12 {
+		t.Errorf("read %02X, expected 12", v)
+	}
+	// Programming can only clear bits
+	program(0x6010, 0xF0)
+	if v := mbc.Read(0x6010); v != 0x10 {
+		t.Errorf("read %02X, expected 10", v)
+	}
+
+	command(0x90)
+	if id := mbc.Read(0x4000); id != 0xC2 {
+		t.Errorf("manufacturer ID %02X", id)
+	}
+	mbc.Write(0x4000, 0xF0)
+
+	// Flash persists in the save
+	loaded, err := NewCartridge(testROM(0x100000, 0x20, 0x05, 0x03), mbc.RAMDump())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := loaded.(*MBC6).Flash[0xA010]; v != 0x10 {
+		t.Errorf("flash not restored from save: %02X", v)
+	}
+
+	// Sector erase
+	command(0x80)
+	mbc.Write(0x5555, 0xAA)
+	mbc.Write(0x2000, 0x01)
+	mbc.Write(0x4AAA, 0x55)
+	mbc.Write(0x6000, 0x30)
+	if v := mbc.Read(0x6010); v != 0xFF {
+		t.Errorf("sector not erased: %02X", v)
+	}
+
+	// ROM mapping
+	mbc.Write(0x2800, 0x00)
+	mbc.Write(0x2000, 0x03)
+	mbc.ROM[3*0x2000+5] = 0x77
+	if v := mbc.Read(0x4005); v != 0x77 {
+		t.Errorf("ROM bank: read %02X", v)
+	}
+}
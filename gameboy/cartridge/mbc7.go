@@ -0,0 +1,179 @@
+package cartridge
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+
+const (
+	// Accelerometer values
+	accelerometerCenter = 0x81D0
+	accelerometerG      = 0x70 // ~1g
+	accelerometerErased = 0x8000
+
+	eeprom93LC56Size = 256
+	eeprom93LC66Size = 512
+)
+
+// TiltProvider is an interface for reading the cartridge accelerometer (MBC7).
+// Values are in g, x > 0 when the right side is lower, y > 0 when the bottom side is lower.
+type TiltProvider interface {
+	Tilt() (x, y float64)
+}
+
+type MBC7 struct {
+	header *Header
+
+	ROMBanks uint
+
+	ROM []uint8
+
+	// Registers
+	ramEnabled1   bool // Written at $0000-$1FFF
+	ramEnabled2   bool // Written at $4000-$5FFF
+	romBankNumber uint8
+
+	// Accelerometer latched values
+	accelerometerX uint16
+	accelerometerY uint16
+	tiltProvider   TiltProvider
+
+	eeprom *eeprom
+}
+
+func (mbc *MBC7) RAMDump() []uint8 {
+	return mbc.eeprom.data
+}
+
+func (mbc *MBC7) SaveChanged() bool {
+	return mbc.eeprom.SaveChanged()
+}
+
+func (mbc *MBC7) Header() *Header {
+	return mbc.header
+}
+
+func (mbc *MBC7) SaveState(e *savestate.Encoder) {
+	e.Bool(mbc.ramEnabled1)
+	e.Bool(mbc.ramEnabled2)
+	e.U8(mbc.romBankNumber)
+	e.U16(mbc.accelerometerX)
+	e.U16(mbc.accelerometerY)
+	mbc.eeprom.saveState(e)
+}
+
+func (mbc *MBC7) LoadState(d *savestate.Decoder) {
+	d.Bool(&mbc.ramEnabled1)
+	d.Bool(&mbc.ramEnabled2)
+	d.U8(&mbc.romBankNumber)
+	d.U16(&mbc.accelerometerX)
+	d.U16(&mbc.accelerometerY)
+	mbc.eeprom.loadState(d)
+}
+
+// NewMBC7 creates the cartridge with a 93LC66 EEPROM, unless the save has the size of a 93LC56
+func NewMBC7(rom []uint8, savData []uint8, header *Header) (*MBC7, error) {
+	mbc := &MBC7{
+		header:         header,
+		ROMBanks:       header.ROMBanks,
+		ROM:            rom,
+		romBankNumber:  1,
+		accelerometerX: accelerometerErased,
+		accelerometerY: accelerometerErased,
+	}
+
+	switch len(savData) {
+	case 0:
+		// Erased EEPROM
+		savData = make([]uint8, eeprom93LC66Size)
+		for i := range savData {
+			savData[i] = 0xFF
+		}
+	case eeprom93LC56Size, eeprom93LC66Size:
+	default:
+		return nil, &SaveSizeError{Expected: eeprom93LC66Size, Size: len(savData)}
+	}
+	mbc.eeprom = newEEPROM(savData)
+
+	return mbc, nil
+}
+
+// SetTiltProvider sets the provider of the accelerometer readings
+func (mbc *MBC7) SetTiltProvider(provider TiltProvider) {
+	mbc.tiltProvider = provider
+}
+
+func (mbc *MBC7) registersEnabled() bool {
+	return mbc.ramEnabled1 && mbc.ramEnabled2
+}
+
+func (mbc *MBC7) Write(addr uint16, value uint8) {
+	switch {
+	case addr < 0x2000:
+		mbc.ramEnabled1 = value&0x0F == 0xA
+
+	case addr < 0x4000:
+		mbc.romBankNumber = value & 0x7F
+
+	case addr < 0x6000:
+		mbc.ramEnabled2 = value == 0x40
+
+	case 0xA000 <= addr && addr < 0xB000:
+		if !mbc.registersEnabled() {
+			return
+		}
+
+		switch (addr >> 4) & 0xF {
+		case 0x0: // Erase latched values
+			if value == 0x55 {
+				mbc.accelerometerX = accelerometerErased
+				mbc.accelerometerY = accelerometerErased
+			}
+		case 0x1: // Latch accelerometer, only after erasing
+			if value == 0xAA && mbc.accelerometerX == accelerometerErased && mbc.accelerometerY == accelerometerErased {
+				mbc.latchAccelerometer()
+			}
+		case 0x8:
+			mbc.eeprom.Write(value)
+		}
+	}
+}
+
+func (mbc *MBC7) latchAccelerometer() {
+	var x, y float64
+	if mbc.tiltProvider != nil {
+		x, y = mbc.tiltProvider.Tilt()
+	}
+	mbc.accelerometerX = uint16(accelerometerCenter - int(x*accelerometerG))
+	mbc.accelerometerY = uint16(accelerometerCenter + int(y*accelerometerG))
+}
+
+func (mbc *MBC7) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0x4000:
+		return mbc.ROM[addr]
+
+	case addr < 0x8000:
+		bank := uint(mbc.romBankNumber) % mbc.ROMBanks
+		return mbc.ROM[bank<<14|uint(addr&0x3FFF)]
+
+	case 0xA000 <= addr && addr < 0xB000:
+		if !mbc.registersEnabled() {
+			return 0xFF
+		}
+
+		switch (addr >> 4) & 0xF {
+		case 0x2:
+			return uint8(mbc.accelerometerX)
+		case 0x3:
+			return uint8(mbc.accelerometerX >> 8)
+		case 0x4:
+			return uint8(mbc.accelerometerY)
+		case 0x5:
+			return uint8(mbc.accelerometerY >> 8)
+		case 0x6:
+			return 0x00
+		case 0x8:
+			return mbc.eeprom.Read()
+		}
+	}
+
+	return 0xFF
+}
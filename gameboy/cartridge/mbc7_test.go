@@ -0,0 +1,125 @@
+package cartridge
+
+import "testing"
+
+type fixedTilt struct{ x, y float64 }
+
+func (t fixedTilt) Tilt() (float64, float64) { return t.x, t.y }
+
+func newTestMBC7(t *testing.T, sav []uint8) *MBC7 {
+	c, err := NewCartridge(testROM(0x20000, 0x22, 0x02, 0), sav)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbc := c.(*MBC7)
+	mbc.Write(0x0000, 0x0A)
+	mbc.Write(0x4000, 0x40)
+	return mbc
+}
+
+// sendEEPROM bit-bangs a command into the EEPROM and returns the bits read on DO
+func sendEEPROM(mbc *MBC7, bits string, readBits int) (out uint32) {
+	pin := func(di bool) {
+		var v uint8 = 0x80
+		if di {
+			v |= 0x02
+		}
+		mbc.Write(0xA080, v)
+		mbc.Write(0xA080, v|0x40)
+	}
+
+	mbc.Write(0xA080, 0x00)
+	for _, b := range bits {
+		pin(b == '1')
+	}
+	for range readBits {
+		pin(false)
+		out = out<<1 | uint32(mbc.Read(0xA080)&1)
+	}
+	mbc.Write(0xA080, 0x00)
+	return out
+}
+
+func TestMBC7EEPROM(t *testing.T) {
+	mbc := newTestMBC7(t, nil)
+	if len(mbc.RAMDump()) != eeprom93LC66Size {
+		t.Fatalf("got EEPROM of %d bytes, expected %d", len(mbc.RAMDump()), eeprom93LC66Size)
+	}
+
+	// Writes are ignored until enabled
+	sendEEPROM(mbc, "1"+"01"+"00000101"+"1010101111001101", 0)
+	if v := sendEEPROM(mbc, "1"+"10"+"00000101", 16); v != 0xFFFF {
+		t.Errorf("write disabled: read %04X, expected FFFF", v)
+	}
+
+	sendEEPROM(mbc, "1"+"00"+"11000000", 0) // EWEN
+	sendEEPROM(mbc, "1"+"01"+"00000101"+"1010101111001101", 0)
+	if v := sendEEPROM(mbc, "1"+"10"+"00000101", 16); v != 0xABCD {
+		t.Errorf("read %04X, expected ABCD", v)
+	}
+	if dump := mbc.RAMDump(); dump[10] != 0xCD || dump[11] != 0xAB {
+		t.Errorf("dump %02X %02X, expected CD AB", dump[10], dump[11])
+	}
+
+	// Sequential read continues with the next word
+	if v := sendEEPROM(mbc, "1"+"10"+"00000100", 32); v != 0xFFFFABCD {
+		t.Errorf("sequential read %08X, expected FFFFABCD", v)
+	}
+
+	sendEEPROM(mbc, "1"+"11"+"00000101", 0) // ERASE
+	if v := sendEEPROM(mbc, "1"+"10"+"00000101", 16); v != 0xFFFF {
+		t.Errorf("erase: read %04X, expected FFFF", v)
+	}
+
+	sendEEPROM(mbc, "1"+"00"+"01000000"+"0001001000110100", 0) // WRAL
+	sendEEPROM(mbc, "1"+"00"+"00000000", 0)                    // EWDS
+	sendEEPROM(mbc, "1"+"00"+"10000000", 0)                    // ERAL (disabled)
+	if v := sendEEPROM(mbc, "1"+"10"+"11111111", 16); v != 0x1234 {
+		t.Errorf("write all: read %04X, expected 1234", v)
+	}
+
+	// Saves of a 93LC56 wrap at 128 words
+	sav := make([]uint8, eeprom93LC56Size)
+	sav[0], sav[1] = 0x34, 0x12
+	mbc = newTestMBC7(t, sav)
+	if v := sendEEPROM(mbc, "1"+"10"+"10000000", 16); v != 0x1234 {
+		t.Errorf("93LC56: read %04X, expected 1234", v)
+	}
+}
+
+func TestMBC7Accelerometer(t *testing.T) {
+	mbc := newTestMBC7(t, nil)
+	mbc.SetTiltProvider(fixedTilt{x: 1, y: -0.5})
+
+	read := func() (x, y uint16) {
+		x = uint16(mbc.Read(0xA020)) | uint16(mbc.Read(0xA030))<<8
+		y = uint16(mbc.Read(0xA040)) | uint16(mbc.Read(0xA050))<<8
+		return x, y
+	}
+
+	// Latching requires erasing first
+	mbc.Write(0xA010, 0xAA)
+	if x, y := read(); x != 0x81D0-0x70 || y != 0x81D0-0x38 {
+		t.Errorf("got (%04X, %04X)", x, y)
+	}
+	mbc.SetTiltProvider(fixedTilt{})
+	mbc.Write(0xA010, 0xAA)
+	if x, _ := read(); x != 0x81D0-0x70 {
+		t.Errorf("latched without erasing: x = %04X", x)
+	}
+
+	mbc.Write(0xA000, 0x55)
+	if x, y := read(); x != 0x8000 || y != 0x8000 {
+		t.Errorf("erase: got (%04X, %04X)", x, y)
+	}
+	mbc.Write(0xA010, 0xAA)
+	if x, y := read(); x != 0x81D0 || y != 0x81D0 {
+		t.Errorf("got (%04X, %04X)", x, y)
+	}
+
+	// Registers are not accessible when RAM is disabled
+	mbc.Write(0x4000, 0x00)
+	if v := mbc.Read(0xA020); v != 0xFF {
+		t.Errorf("disabled registers read %02X", v)
+	}
+}
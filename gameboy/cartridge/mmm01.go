@@ -0,0 +1,214 @@
+package cartridge
+
+import (
+	"log"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+)
+
+// MMM01 is the mapper of multi-game compilations. At power up the last 32 KiB of the ROM
+// (the menu) are mapped at $0000-$7FFF. The menu selects the game setting the bank registers
+// and the masks of the bits the game can change, then maps the game writing bit 6 of $0000-$1FFF.
+//
+// Registers:
+//   - $0000-$1FFF: bit 6 map game, bits 5-4 RAM bank mask, bits 3-0 RAM enable ($A)
+//   - $2000-$3FFF: bits 6-5 ROM bank mid bits, bits 4-0 ROM bank low bits
+//   - $4000-$5FFF: bits 5-4 ROM bank high bits, bits 3-2 RAM bank high bits, bits 1-0 RAM bank low bits
+//   - $6000-$7FFF: bits 5-2 ROM bank mask, bit 0 MBC1 banking mode
+//
+// Only the register bits not covered by the masks can be written after mapping the game.
+type MMM01 struct {
+	header  *Header
+	battery bool
+	saveTracker
+
+	ROMBanks uint
+	RAMBanks uint8
+
+	ROM []uint8
+	RAM []uint8
+
+	// Registers
+	mapped      bool
+	ramEnabled  bool
+	romBank     uint  // 9 bit ROM bank number (high 2 - mid 2 - low 5)
+	ramBank     uint8 // 4 bit RAM bank number (high 2 - low 2)
+	romBankMask uint8 // Bits 4-1 of the low ROM bank fixed by the menu
+	ramBankMask uint8 // Bits 1-0 of the RAM bank fixed by the menu
+	bankingMode uint8
+}
+
+func (mbc *MMM01) RAMDump() []uint8 {
+	if mbc.battery {
+		return mbc.RAM
+	}
+
+	return nil
+}
+
+func (mbc *MMM01) Header() *Header {
+	return mbc.header
+}
+
+func (mbc *MMM01) SaveState(e *savestate.Encoder) {
+	e.Bool(mbc.mapped)
+	e.Bool(mbc.ramEnabled)
+	e.Uint(mbc.romBank)
+	e.U8(mbc.ramBank)
+	e.U8(mbc.romBankMask)
+	e.U8(mbc.ramBankMask)
+	e.U8(mbc.bankingMode)
+	e.Bytes(mbc.RAM)
+}
+
+func (mbc *MMM01) LoadState(d *savestate.Decoder) {
+	d.Bool(&mbc.mapped)
+	d.Bool(&mbc.ramEnabled)
+	d.Uint(&mbc.romBank)
+	d.U8(&mbc.ramBank)
+	d.U8(&mbc.romBankMask)
+	d.U8(&mbc.ramBankMask)
+	d.U8(&mbc.bankingMode)
+	d.BytesInto(mbc.RAM)
+}
+
+// isMMM01 reports whether the ROM has the MMM01 menu header in its last 32 KiB.
+// The header at the start of the ROM usually belongs to the first game of the compilation.
+func isMMM01(rom []uint8) bool {
+	if len(rom) < 0x10000 {
+		return false
+	}
+
+	t := rom[len(rom)-0x8000+cartridgeType]
+	return 0x0B <= t && t <= 0x0D
+}
+
+func NewMMM01(rom []uint8, ram bool, savData []uint8, header *Header, battery bool) (*MMM01, error) {
+	mbc := &MMM01{
+		header:   header,
+		battery:  battery,
+		ROMBanks: header.ROMBanks,
+		RAMBanks: uint8(header.RAMBanks),
+		ROM:      rom,
+	}
+	if ram && header.RAMBanks == 0 {
+		log.Println("[WARN] Cartridge header specifies RAM present, but RAM banks is set to 0")
+		mbc.RAMBanks = 1
+	}
+
+	if ram {
+		ramLen := int(mbc.RAMBanks) * 0x2000
+		switch {
+		case savData == nil:
+			savData = make([]uint8, ramLen)
+		case len(savData) != ramLen:
+			return nil, &SaveSizeError{Expected: ramLen, Size: len(savData)}
+		}
+		mbc.RAM = savData
+	}
+
+	return mbc, nil
+}
+
+// writableROMBits returns the bits of the ROM bank number that can be written
+func (mbc *MMM01) writableROMBits() uint {
+	if !mbc.mapped {
+		return 0x1FF
+	}
+	return 0x1F &^ (uint(mbc.romBankMask) << 1)
+}
+
+// writableRAMBits returns the bits of the RAM bank number that can be written
+func (mbc *MMM01) writableRAMBits() uint8 {
+	if !mbc.mapped {
+		return 0xF
+	}
+	return 0x3 &^ mbc.ramBankMask
+}
+
+func (mbc *MMM01) Write(addr uint16, value uint8) {
+	switch {
+	case addr < 0x2000:
+		mbc.ramEnabled = value&0x0F == 0xA
+		if !mbc.mapped {
+			mbc.ramBankMask = (value >> 4) & 0x3
+			mbc.mapped = value&0x40 != 0
+		}
+
+	case addr < 0x4000:
+		bank := uint(value & 0x7F)
+		writable := mbc.writableROMBits() & 0x7F
+		mbc.romBank = mbc.romBank&^writable | bank&writable
+
+	case addr < 0x6000:
+		bank := uint(value&0x30) << 3
+		writable := mbc.writableROMBits() & 0x180
+		mbc.romBank = mbc.romBank&^writable | bank&writable
+
+		ramWritable := mbc.writableRAMBits()
+		mbc.ramBank = mbc.ramBank&^ramWritable | value&0xF&ramWritable
+
+	case addr < 0x8000:
+		mbc.bankingMode = value & 1
+		if !mbc.mapped {
+			mbc.romBankMask = (value >> 2) & 0xF
+		}
+
+	case 0xA000 <= addr && addr < 0xC000:
+		if mbc.ramEnabled && mbc.RAM != nil {
+			mbc.store(mbc.RAM, mbc.computeRamAddress(addr), value)
+		}
+	}
+}
+
+func (mbc *MMM01) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0x8000:
+		return mbc.ROM[mbc.computeRomAddress(addr)]
+
+	case 0xA000 <= addr && addr < 0xC000:
+		if mbc.ramEnabled && mbc.RAM != nil {
+			return mbc.RAM[mbc.computeRamAddress(addr)]
+		}
+	}
+
+	return 0xFF
+}
+
+func (mbc *MMM01) computeRomAddress(cpuAddress uint16) uint {
+	var bank uint
+
+	switch {
+	case !mbc.mapped:
+		// Menu in the last 32 KiB
+		bank = mbc.ROMBanks - 2
+		if cpuAddress >= 0x4000 {
+			bank++
+		}
+
+	case cpuAddress < 0x4000:
+		// Game bank 0: the bits selectable by the game are cleared
+		bank = mbc.romBank &^ mbc.writableROMBits()
+
+	default:
+		bank = mbc.romBank
+		// Game bank 0 behaves as 1 like on MBC1
+		if bank&mbc.writableROMBits() == 0 {
+			bank |= 1
+		}
+	}
+
+	bank %= mbc.ROMBanks
+	return bank<<14 | uint(cpuAddress&0x3FFF)
+}
+
+func (mbc *MMM01) computeRamAddress(cpuAddress uint16) uint {
+	bank := mbc.ramBank
+	// In banking mode 0 only the bits fixed by the menu are used
+	if mbc.bankingMode == 0 {
+		bank &^= mbc.writableRAMBits()
+	}
+
+	bank %= mbc.RAMBanks
+	return uint(bank)<<13 | uint(cpuAddress&0x1FFF)
+}
@@ -0,0 +1,65 @@
+package cartridge
+
+import "testing"
+
+// mmm01ROM returns a 512 KiB compilation with the menu header in the last 32 KiB,
+// each bank starts with its number
+func mmm01ROM() []uint8 {
+	rom := testROM(0x80000, 0x01, 0x04, 0)
+	for bank := 0; bank < 32; bank++ {
+		rom[bank*0x4000] = uint8(bank)
+	}
+	menu := rom[len(rom)-0x8000:]
+	menu[cartridgeType] = 0x0D
+	menu[romSize] = 0x00 // The menu header only describes itself
+	menu[ramSize] = 0x03
+	return rom
+}
+
+func TestMMM01(t *testing.T) {
+	c, err := NewCartridge(mmm01ROM(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbc, ok := c.(*MMM01)
+	if !ok {
+		t.Fatalf("got %T, expected MMM01", c)
+	}
+	if mbc.ROMBanks != 32 {
+		t.Errorf("got %d ROM banks, expected 32", mbc.ROMBanks)
+	}
+
+	// Menu is mapped at power up
+	if b0, b1 := mbc.Read(0x0000), mbc.Read(0x4000); b0 != 30 || b1 != 31 {
+		t.Errorf("menu: got banks %d, %d, expected 30, 31", b0, b1)
+	}
+
+	// Select the game starting at bank 8 (128 KiB, the game can change the low 3 bits)
+	mbc.Write(0x2000, 0x08)
+	mbc.Write(0x6000, 0b0011_0000) // Mask bits 4-3
+	mbc.Write(0x4000, 0x01)        // RAM bank 1
+	mbc.Write(0x0000, 0x40|0x10)   // Map game, fix RAM bank bit 0
+
+	if b0, b1 := mbc.Read(0x0000), mbc.Read(0x4000); b0 != 8 || b1 != 9 {
+		t.Errorf("game: got banks %d, %d, expected 8, 9", b0, b1)
+	}
+
+	// The game switches banks inside its own ROM
+	mbc.Write(0x2000, 0x1D)
+	if b := mbc.Read(0x4000); b != 13 {
+		t.Errorf("got bank %d, expected 13", b)
+	}
+	mbc.Write(0x4000, 0x30)
+	if b := mbc.Read(0x4000); b != 13 {
+		t.Errorf("ROM high bits changed after mapping: bank %d", b)
+	}
+
+	// RAM bank bit 0 is fixed by the menu
+	mbc.Write(0x6000, 0x01)
+	mbc.Write(0x4000, 0x02)
+	mbc.Write(0x0000, 0x0A)
+	mbc.Write(0xA000, 0x42)
+	if mbc.RAM[3*0x2000] != 0x42 {
+		t.Errorf("RAM not written to bank 3")
+	}
+}
@@ -2,6 +2,8 @@ package cartridge
 
 import (
 	"log"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
 )
 
 type Cartridge interface {
@@ -10,14 +12,37 @@ type Cartridge interface {
 
 	RAMDump() []uint8
 	Header() *Header
+
+	// SaveState and LoadState serialize banking registers, RAM and clocks
+	SaveState(*savestate.Encoder)
+	LoadState(*savestate.Decoder)
+}
+
+// NewCartridge parses the ROM header and creates the cartridge with its memory bank controller.
+// savData is the content of the battery backed RAM (nil if there is no save).
+func NewCartridge(romData []uint8, savData []uint8) (Cartridge, error) {
+	return NewCartridgeWithClock(romData, savData, RealClock{})
 }
 
-func NewCartridge(romData []uint8, savData []uint8) Cartridge {
-	header := parseHeader(romData)
+// NewCartridgeWithClock creates the cartridge using the clock for its real time clock, if any
+func NewCartridgeWithClock(romData []uint8, savData []uint8, clock Clock) (Cartridge, error) {
+	header, err := readHeader(romData)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reading past the end of the ROM would crash the emulator
+	headerSize := int(header.ROMBanks) * 0x4000
+	switch {
+	case len(romData) < headerSize:
+		return nil, &ROMSizeMismatchError{HeaderSize: headerSize, Size: len(romData)}
+	case len(romData) > headerSize:
+		log.Printf("[WARN] ROM is larger than specified in the header (%d bytes instead of %d)", len(romData), headerSize)
+	}
 
-	switch romData[cartridgeType] {
+	switch header.CartridgeType {
 	case 0: // ROM ONLY
-		return NewMBC0(romData, header)
+		return NewMBC0(romData, header), nil
 	case 1: // MBC1
 		return NewMBC1(romData, false, nil, header, false)
 	case 2: // MBC1 + RAM
@@ -28,16 +53,22 @@ func NewCartridge(romData []uint8, savData []uint8) Cartridge {
 		return NewMBC2(romData, nil, header, false)
 	case 6: // MBC2 + BATTERY
 		return NewMBC2(romData, savData, header, true)
+	case 0x0B: // MMM01
+		return NewMMM01(romData, false, nil, header, false)
+	case 0x0C: // MMM01 + RAM
+		return NewMMM01(romData, true, nil, header, false)
+	case 0x0D: // MMM01 + RAM + BATTERY
+		return NewMMM01(romData, true, savData, header, true)
 	case 0x0F: // MBC3 + TIMER + BATTERY
-		return NewMBC3(romData, false, savData, header, true, true)
+		return NewMBC3(romData, false, savData, header, true, true, clock)
 	case 0x10: // MBC3 + TIMER + RAM + BATTERY
-		return NewMBC3(romData, true, savData, header, true, true)
+		return NewMBC3(romData, true, savData, header, true, true, clock)
 	case 0x11: // MBC3
-		return NewMBC3(romData, false, nil, header, false, false)
+		return NewMBC3(romData, false, nil, header, false, false, clock)
 	case 0x12: // MBC3 + RAM
-		return NewMBC3(romData, true, nil, header, false, false)
+		return NewMBC3(romData, true, nil, header, false, false, clock)
 	case 0x13: // MBC3 + RAM + BATTERY
-		return NewMBC3(romData, true, savData, header, true, false)
+		return NewMBC3(romData, true, savData, header, true, false, clock)
 	case 0x19: // MBC5
 		return NewMBC5(romData, false, nil, header, false, false)
 	case 0x1A: // MBC5 + RAM
@@ -50,8 +81,38 @@ func NewCartridge(romData []uint8, savData []uint8) Cartridge {
 		return NewMBC5(romData, true, nil, header, false, true)
 	case 0x1E: // MBC5 + RUMBLE + RAM + BATTERY
 		return NewMBC5(romData, true, savData, header, true, true)
+	case 0x20: // MBC6
+		return NewMBC6(romData, savData, header)
+	case 0x22: // MBC7 + SENSOR + RUMBLE + RAM + BATTERY
+		return NewMBC7(romData, savData, header)
+	case 0xFC: // POCKET CAMERA
+		return NewCamera(romData, savData, header)
+	case 0xFD: // BANDAI TAMA5
+		return NewTAMA5(romData, savData, header, clock)
+	case 0xFE: // HuC3
+		return NewHuC3(romData, savData, header, clock)
+	case 0xFF: // HuC1 + RAM + BATTERY
+		return NewHuC1(romData, savData, header)
 	default:
-		log.Panicf("cartridge type %02X not supported", romData[cartridgeType])
-		return nil
+		return nil, &UnsupportedMapperError{Type: header.CartridgeType}
+	}
+}
+
+// readHeader parses the header of the ROM
+func readHeader(romData []uint8) (*Header, error) {
+	// MMM01 compilations boot from the menu in the last 32 KiB
+	mmm01 := isMMM01(romData)
+	offset := 0
+	if mmm01 {
+		offset = len(romData) - 0x8000
+	}
+
+	header, err := parseHeaderAt(romData, offset)
+	if err != nil {
+		return nil, err
+	}
+	if mmm01 {
+		header.ROMBanks = uint(len(romData) / 0x4000)
 	}
+	return header, nil
 }
@@ -0,0 +1,85 @@
+package cartridge
+
+import (
+	"errors"
+	"testing"
+)
+
+// testROM returns a ROM of the given size with the cartridge type and size bytes set
+func testROM(size int, cartType, romSizeCode, ramSizeCode uint8) []uint8 {
+	rom := make([]uint8, size)
+	rom[cartridgeType] = cartType
+	rom[romSize] = romSizeCode
+	rom[ramSize] = ramSizeCode
+	return rom
+}
+
+func TestNewCartridgeErrors(t *testing.T) {
+	t.Run("unsupported mapper", func(t *testing.T) {
+		_, err := NewCartridge(testROM(0x8000, 0xEE, 0, 0), nil)
+		var target *UnsupportedMapperError
+		if !errors.As(err, &target) || target.Type != 0xEE {
+			t.Errorf("expected UnsupportedMapperError, got %v", err)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		_, err := NewCartridge(make([]uint8, 0x100), nil)
+		var target *TruncatedROMError
+		if !errors.As(err, &target) {
+			t.Errorf("expected TruncatedROMError, got %v", err)
+		}
+	})
+
+	t.Run("size mismatch", func(t *testing.T) {
+		_, err := NewCartridge(testROM(0x8000, 0x01, 0x02, 0), nil) // Header says 128 KiB
+		var target *ROMSizeMismatchError
+		if !errors.As(err, &target) || target.HeaderSize != 0x20000 || target.Size != 0x8000 {
+			t.Errorf("expected ROMSizeMismatchError, got %v", err)
+		}
+	})
+
+	t.Run("invalid RAM size", func(t *testing.T) {
+		_, err := NewCartridge(testROM(0x8000, 0x03, 0, 0x07), nil)
+		var target *InvalidHeaderError
+		if !errors.As(err, &target) || target.Value != 0x07 {
+			t.Errorf("expected InvalidHeaderError, got %v", err)
+		}
+	})
+
+	t.Run("save size", func(t *testing.T) {
+		saves := []struct {
+			cartType uint8
+			sav      []uint8
+			expected int
+		}{
+			{0x03, make([]uint8, 100), 0x2000},         // MBC1 + RAM + BATTERY
+			{0x06, make([]uint8, 0x2000), 512},         // MBC2 + BATTERY
+			{0x10, make([]uint8, 0x2010), 0x2000 + 48}, // MBC3 + TIMER + RAM + BATTERY
+			{0x1B, make([]uint8, 0x4000), 0x2000},      // MBC5 + RAM + BATTERY
+		}
+		for _, save := range saves {
+			_, err := NewCartridge(testROM(0x8000, save.cartType, 0, 0x02), save.sav)
+			var target *SaveSizeError
+			if !errors.As(err, &target) || target.Expected != save.expected || target.Size != len(save.sav) {
+				t.Errorf("type %02X: expected SaveSizeError, got %v", save.cartType, err)
+			}
+		}
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		if _, err := NewCartridge(testROM(0x8000, 0x13, 0, 0x02), make([]uint8, 0x2000)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, err := NewCartridge(testROM(0x8000, 0x0F, 0, 0), make([]uint8, 48)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		// MBC3 saves without RTC data or with the 44 bytes footer
+		if _, err := NewCartridge(testROM(0x8000, 0x10, 0, 0x02), make([]uint8, 0x2000)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, err := NewCartridge(testROM(0x8000, 0x10, 0, 0x02), make([]uint8, 0x2000+44)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
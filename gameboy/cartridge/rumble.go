@@ -0,0 +1,70 @@
+package cartridge
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+
+// Duty cycle of the motor is measured over a frame
+const rumbleWindowTicks = 154 * 456
+
+// Rumbler is implemented by cartridges with a rumble motor.
+// Games drive the motor with PWM, so the intensity is the fraction of the last frame
+// the motor was on.
+type Rumbler interface {
+	// RumbleIntensity returns the motor duty cycle over the last frame (0-1)
+	RumbleIntensity() float64
+	// SetRumbleCallback sets a function called when the motor is switched on or off
+	SetRumbleCallback(callback func(on bool))
+}
+
+type rumbleMotor struct {
+	on        bool
+	onTicks   int
+	ticks     int
+	intensity float64
+
+	callback func(on bool)
+}
+
+func (m *rumbleMotor) RumbleIntensity() float64 {
+	return m.intensity
+}
+
+func (m *rumbleMotor) SetRumbleCallback(callback func(on bool)) {
+	m.callback = callback
+}
+
+func (m *rumbleMotor) set(on bool) {
+	if on == m.on {
+		return
+	}
+
+	m.on = on
+	if m.callback != nil {
+		m.callback(on)
+	}
+}
+
+func (m *rumbleMotor) tick(ticks int) {
+	m.ticks += ticks
+	if m.on {
+		m.onTicks += ticks
+	}
+
+	if m.ticks >= rumbleWindowTicks {
+		m.intensity = min(float64(m.onTicks)/float64(m.ticks), 1)
+		m.ticks, m.onTicks = 0, 0
+	}
+}
+
+func (m *rumbleMotor) saveState(e *savestate.Encoder) {
+	e.Bool(m.on)
+	e.Int(m.onTicks)
+	e.Int(m.ticks)
+	e.Float64(m.intensity)
+}
+
+func (m *rumbleMotor) loadState(d *savestate.Decoder) {
+	d.Bool(&m.on)
+	d.Int(&m.onTicks)
+	d.Int(&m.ticks)
+	d.Float64(&m.intensity)
+}
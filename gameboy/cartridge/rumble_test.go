@@ -0,0 +1,49 @@
+package cartridge
+
+import "testing"
+
+func TestMBC5Rumble(t *testing.T) {
+	c, err := NewCartridge(testROM(0x10000, 0x1E, 0x01, 0x02), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbc, ok := c.(Rumbler)
+	if !ok {
+		t.Fatalf("%T does not implement Rumbler", c)
+	}
+
+	var switches []bool
+	mbc.SetRumbleCallback(func(on bool) {
+		switches = append(switches, on)
+	})
+
+	mbc5 := c.(*MBC5)
+	// Motor on for a quarter of the frame
+	mbc5.Write(0x4000, 0x08)
+	mbc5.Tick(rumbleWindowTicks / 4)
+	mbc5.Write(0x4000, 0x08) // No callback if unchanged
+	mbc5.Write(0x4000, 0x00)
+	mbc5.Tick(rumbleWindowTicks - rumbleWindowTicks/4)
+
+	if got := mbc.RumbleIntensity(); got < 0.24 || got > 0.26 {
+		t.Errorf("intensity: got %v, expected 0.25", got)
+	}
+	if len(switches) != 2 || !switches[0] || switches[1] {
+		t.Errorf("callback calls: %v", switches)
+	}
+
+	// Bit 3 does not select the RAM bank
+	mbc5.Write(0x0000, 0x0A)
+	mbc5.Write(0x4000, 0x08)
+	mbc5.Write(0xA000, 0x42)
+	mbc5.Write(0x4000, 0x00)
+	if v := mbc5.Read(0xA000); v != 0x42 {
+		t.Errorf("RAM bank 0: got %02X", v)
+	}
+
+	// A full frame off
+	mbc5.Tick(rumbleWindowTicks)
+	if got := mbc.RumbleIntensity(); got != 0 {
+		t.Errorf("intensity: got %v, expected 0", got)
+	}
+}
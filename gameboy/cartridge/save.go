@@ -0,0 +1,173 @@
+package cartridge
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// RTCFooter is the layout of the MBC3 RTC data appended to the RAM in SAV files.
+// Both layouts store the 5 clock registers and the 5 latched registers as 32 bit
+// little endian values, followed by the unix timestamp of the save.
+type RTCFooter int
+
+const (
+	RTCFooterNone RTCFooter = 0
+	RTCFooter44   RTCFooter = 44 // 32 bit timestamp (older VBA and flash cart tools)
+	RTCFooter48   RTCFooter = 48 // 64 bit timestamp (BGB, VBA-M, SameBoy, mGBA and this emulator)
+)
+
+func ParseRTCFooter(footer string) (RTCFooter, error) {
+	switch strings.ToLower(footer) {
+	case "none":
+		return RTCFooterNone, nil
+	case "44":
+		return RTCFooter44, nil
+	case "48", "":
+		return RTCFooter48, nil
+	default:
+		return RTCFooterNone, fmt.Errorf("invalid RTC footer %q (none, 44, 48)", footer)
+	}
+}
+
+// rtcFooter holds the data of an RTC footer
+type rtcFooter struct {
+	registers [10]uint8 // Clock and latched registers: S, M, H, DL, DH
+	timestamp int64
+}
+
+func decodeRTCFooter(data []uint8) (f rtcFooter, ok bool) {
+	switch RTCFooter(len(data)) {
+	case RTCFooter44:
+		f.timestamp = int64(int32(binary.LittleEndian.Uint32(data[40:])))
+	case RTCFooter48:
+		f.timestamp = int64(binary.LittleEndian.Uint64(data[40:]))
+	default:
+		return f, false
+	}
+
+	// A byte is saved as an int so extra bytes are 0
+	for i := range f.registers {
+		f.registers[i] = data[4*i]
+	}
+	return f, true
+}
+
+func (f rtcFooter) encode(dst []uint8, layout RTCFooter) []uint8 {
+	for _, r := range f.registers {
+		dst, _ = binary.Append(dst, binary.LittleEndian, int32(r))
+	}
+
+	switch layout {
+	case RTCFooter44:
+		dst, _ = binary.Append(dst, binary.LittleEndian, int32(f.timestamp))
+	case RTCFooter48:
+		dst, _ = binary.Append(dst, binary.LittleEndian, f.timestamp)
+	}
+	return dst
+}
+
+// saveLayout is the layout of the SAV file written by RAMDump
+type saveLayout struct {
+	ramLen int  // RAM, EEPROM or flash
+	rtcLen int  // Clock data following the RAM
+	mbc3   bool // The clock data is the MBC3 RTC footer of 48 bytes
+	// Value of erased memory, used to pad truncated saves
+	fill uint8
+	// Sizes of shorter saves loaded as they are (other than saves without clock data)
+	shorter []int
+}
+
+// native reports whether a save of the given size is loaded as it is
+func (l saveLayout) native(size int) bool {
+	return size == l.ramLen+l.rtcLen || size == l.ramLen || slices.Contains(l.shorter, size)
+}
+
+// nativeSaveLayout returns the layout of the saves of cartridges with a battery
+func nativeSaveLayout(header *Header) (layout saveLayout, ok bool) {
+	ramLen := int(max(header.RAMBanks, 1)) * 0x2000
+
+	switch header.CartridgeType {
+	case 0x03, 0x0D, 0x13, 0x1B, 0x1E, 0xFF: // RAM + BATTERY
+		return saveLayout{ramLen: ramLen}, true
+	case 0x06: // MBC2 + BATTERY
+		return saveLayout{ramLen: mbc2RAMLen}, true
+	case 0x0F: // MBC3 + TIMER + BATTERY
+		return saveLayout{rtcLen: int(RTCFooter48), mbc3: true}, true
+	case 0x10: // MBC3 + TIMER + RAM + BATTERY
+		return saveLayout{ramLen: ramLen, rtcLen: int(RTCFooter48), mbc3: true}, true
+	case 0x20: // MBC6 (the flash can be missing)
+		return saveLayout{ramLen: mbc6RAMLen + mbc6FlashLen, fill: 0xFF, shorter: []int{mbc6RAMLen}}, true
+	case 0x22: // MBC7 (93LC66 or 93LC56 EEPROM)
+		return saveLayout{ramLen: eeprom93LC66Size, fill: 0xFF, shorter: []int{eeprom93LC56Size}}, true
+	case 0xFC: // POCKET CAMERA
+		return saveLayout{ramLen: cameraRAMLen}, true
+	case 0xFD: // BANDAI TAMA5
+		return saveLayout{ramLen: tama5RAMLen, rtcLen: tama5RTCLen}, true
+	case 0xFE: // HuC3
+		return saveLayout{ramLen: ramLen, rtcLen: huc3RTCLen}, true
+	}
+	return saveLayout{}, false
+}
+
+// ImportSave adapts a save written by another emulator or a flash cart to the layout
+// written by this emulator. It handles MBC3 RTC footers of 44 and 48 bytes, saves without
+// clock data, and memory padded or truncated to another size.
+// converted is true if the save cannot be loaded as it is: saving the game would
+// overwrite it in a different format.
+func ImportSave(romData []uint8, savData []uint8) (data []uint8, converted bool, err error) {
+	header, err := readHeader(romData)
+	if err != nil {
+		return nil, false, err
+	}
+	layout, ok := nativeSaveLayout(header)
+	if !ok || savData == nil || layout.native(len(savData)) {
+		return savData, false, nil
+	}
+
+	ram := savData
+	var footer rtcFooter
+	hasFooter := false
+	switch len(savData) - layout.ramLen {
+	case int(RTCFooter48), int(RTCFooter44):
+		ram = savData[:layout.ramLen]
+		footer, hasFooter = decodeRTCFooter(savData[layout.ramLen:])
+	default:
+		log.Printf("[WARN] save file is %d bytes, expected %d bytes of RAM: RAM is resized and RTC data is lost", len(savData), layout.ramLen)
+	}
+
+	data = make([]uint8, layout.ramLen, layout.ramLen+layout.rtcLen)
+	for i := copy(data, ram); i < len(data); i++ {
+		data[i] = layout.fill
+	}
+	if layout.mbc3 && hasFooter {
+		data = footer.encode(data, RTCFooter48)
+	}
+	return data, true, nil
+}
+
+// ExportSave converts a save written by this emulator to the layout with the given RTC footer.
+// Saves of cartridges without RTC are returned unchanged.
+func ExportSave(romData []uint8, savData []uint8, footer RTCFooter) ([]uint8, error) {
+	header, err := readHeader(romData)
+	if err != nil {
+		return nil, err
+	}
+	layout, ok := nativeSaveLayout(header)
+	if !ok || !layout.mbc3 {
+		return savData, nil
+	}
+
+	expected := layout.ramLen + int(RTCFooter48)
+	if len(savData) != expected && len(savData) != layout.ramLen {
+		return nil, &SaveSizeError{Expected: expected, Size: len(savData)}
+	}
+
+	data := append([]uint8(nil), savData[:layout.ramLen]...)
+	if f, ok := decodeRTCFooter(savData[layout.ramLen:]); ok && footer != RTCFooterNone {
+		data = f.encode(data, footer)
+	}
+	return data, nil
+}
@@ -0,0 +1,124 @@
+package cartridge
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// rtcSave returns an MBC3 save with the RAM filled with the value and the RTC footer
+func rtcSave(ramLen int, value uint8, footer RTCFooter, hours uint8, timestamp int64) []uint8 {
+	save := make([]uint8, ramLen, ramLen+int(footer))
+	for i := range save {
+		save[i] = value
+	}
+	if footer != RTCFooterNone {
+		f := rtcFooter{timestamp: timestamp}
+		f.registers[2] = hours
+		save = f.encode(save, footer)
+	}
+	return save
+}
+
+// padded fills the save up to the size
+func padded(save []uint8, size int, fill uint8) []uint8 {
+	for len(save) < size {
+		save = append(save, fill)
+	}
+	return save
+}
+
+func TestImportSave(t *testing.T) {
+	rtcROM := testROM(0x8000, 0x10, 0, 0x02)       // MBC3 + TIMER + RAM + BATTERY, 8 KiB
+	timerROM := testROM(0x8000, 0x0F, 0, 0)        // MBC3 + TIMER + BATTERY
+	ramROM := testROM(0x8000, 0x1B, 0, 0x02)       // MBC5 + RAM + BATTERY, 8 KiB
+	mbc2ROM := testROM(0x8000, 0x06, 0, 0)         // MBC2 + BATTERY
+	mbc6ROM := testROM(0x100000, 0x20, 0x05, 0x03) // MBC6
+	mbc7ROM := testROM(0x20000, 0x22, 0x02, 0)     // MBC7
+	cameraROM := testROM(0x80000, 0xFC, 0x04, 0x04)
+	tama5ROM := testROM(0x80000, 0xFD, 0x04, 0)
+	huc3ROM := testROM(0x8000, 0xFE, 0, 0x02)
+	huc1ROM := testROM(0x8000, 0xFF, 0, 0x02)
+
+	tests := []struct {
+		name      string
+		rom       []uint8
+		save      []uint8
+		expected  []uint8
+		converted bool
+	}{
+		{"native", rtcROM, rtcSave(0x2000, 0x11, RTCFooter48, 5, 1000), rtcSave(0x2000, 0x11, RTCFooter48, 5, 1000), false},
+		{"44 bytes footer", rtcROM, rtcSave(0x2000, 0x11, RTCFooter44, 5, 1000), rtcSave(0x2000, 0x11, RTCFooter48, 5, 1000), true},
+		{"no RTC", rtcROM, rtcSave(0x2000, 0x11, RTCFooterNone, 0, 0), rtcSave(0x2000, 0x11, RTCFooterNone, 0, 0), false},
+		{"timer without RAM", timerROM, rtcSave(0, 0, RTCFooter44, 5, 1000), rtcSave(0, 0, RTCFooter48, 5, 1000), true},
+		{"padded", ramROM, rtcSave(0x8000, 0x22, RTCFooterNone, 0, 0), rtcSave(0x2000, 0x22, RTCFooterNone, 0, 0), true},
+		{"truncated", ramROM, rtcSave(0x1000, 0x22, RTCFooterNone, 0, 0), padded(rtcSave(0x1000, 0x22, RTCFooterNone, 0, 0), 0x2000, 0), true},
+		{"footer without RTC", ramROM, rtcSave(0x2000, 0x22, RTCFooter48, 5, 1000), rtcSave(0x2000, 0x22, RTCFooterNone, 0, 0), true},
+		{"MBC2 padded", mbc2ROM, rtcSave(0x2000, 0xF3, RTCFooterNone, 0, 0), rtcSave(mbc2RAMLen, 0xF3, RTCFooterNone, 0, 0), true},
+		{"MBC6 without flash", mbc6ROM, rtcSave(mbc6RAMLen, 0x33, RTCFooterNone, 0, 0), rtcSave(mbc6RAMLen, 0x33, RTCFooterNone, 0, 0), false},
+		{"MBC6 truncated", mbc6ROM, rtcSave(mbc6RAMLen+0x1000, 0x33, RTCFooterNone, 0, 0), padded(rtcSave(mbc6RAMLen+0x1000, 0x33, RTCFooterNone, 0, 0), mbc6RAMLen+mbc6FlashLen, 0xFF), true},
+		{"MBC7 93LC56", mbc7ROM, rtcSave(eeprom93LC56Size, 0x44, RTCFooterNone, 0, 0), rtcSave(eeprom93LC56Size, 0x44, RTCFooterNone, 0, 0), false},
+		{"MBC7 padded", mbc7ROM, rtcSave(0x2000, 0x44, RTCFooterNone, 0, 0), rtcSave(eeprom93LC66Size, 0x44, RTCFooterNone, 0, 0), true},
+		{"MBC7 truncated", mbc7ROM, rtcSave(300, 0x44, RTCFooterNone, 0, 0), padded(rtcSave(300, 0x44, RTCFooterNone, 0, 0), eeprom93LC66Size, 0xFF), true},
+		{"Camera", cameraROM, rtcSave(cameraRAMLen, 0x55, RTCFooterNone, 0, 0), rtcSave(cameraRAMLen, 0x55, RTCFooterNone, 0, 0), false},
+		{"TAMA5 without RTC", tama5ROM, rtcSave(tama5RAMLen, 0x66, RTCFooterNone, 0, 0), rtcSave(tama5RAMLen, 0x66, RTCFooterNone, 0, 0), false},
+		{"TAMA5 padded", tama5ROM, rtcSave(0x2000, 0x66, RTCFooterNone, 0, 0), rtcSave(tama5RAMLen, 0x66, RTCFooterNone, 0, 0), true},
+		{"HuC3 padded", huc3ROM, rtcSave(0x8000, 0x77, RTCFooterNone, 0, 0), rtcSave(0x2000, 0x77, RTCFooterNone, 0, 0), true},
+		{"HuC1 truncated", huc1ROM, rtcSave(0x1000, 0x88, RTCFooterNone, 0, 0), padded(rtcSave(0x1000, 0x88, RTCFooterNone, 0, 0), 0x2000, 0), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, converted, err := ImportSave(test.rom, test.save)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if converted != test.converted {
+				t.Errorf("converted: got %v", converted)
+			}
+			if string(data) != string(test.expected) {
+				t.Errorf("got %d bytes, expected %d", len(data), len(test.expected))
+			}
+
+			// The imported save can always be loaded
+			if _, err := NewCartridge(test.rom, data); err != nil {
+				t.Errorf("loading imported save: %v", err)
+			}
+		})
+	}
+}
+
+func TestExportSave(t *testing.T) {
+	rom := testROM(0x8000, 0x10, 0, 0x02)
+	timestamp := time.Date(2001, 9, 1, 12, 0, 0, 0, time.UTC).Unix()
+	native := rtcSave(0x2000, 0x11, RTCFooter48, 5, timestamp)
+
+	save44, err := ExportSave(rom, native, RTCFooter44)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(save44) != 0x2000+44 || int64(binary.LittleEndian.Uint32(save44[0x2000+40:])) != timestamp {
+		t.Errorf("44 bytes footer: got %d bytes", len(save44))
+	}
+
+	// Importing the exported save gives back the original
+	imported, _, err := ImportSave(rom, save44)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(imported) != string(native) {
+		t.Errorf("round trip changed the save")
+	}
+
+	saveNone, err := ExportSave(rom, native, RTCFooterNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(saveNone) != string(native[:0x2000]) {
+		t.Errorf("no footer: got %d bytes", len(saveNone))
+	}
+
+	if _, err := ExportSave(rom, native[:100], RTCFooter48); err == nil {
+		t.Errorf("expected error for invalid save")
+	}
+}
@@ -0,0 +1,376 @@
+package cartridge
+
+import (
+	"encoding/binary"
+	"log"
+	"time"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+)
+
+const (
+	tama5RAMLen = 32
+	// RTC data stored after RAM in SAV file
+	tama5RTCLen = 18
+
+	// Registers selected writing $A001
+	tama5BankLow  = 0x0
+	tama5BankHigh = 0x1
+	tama5DataLow  = 0x4
+	tama5DataHigh = 0x5
+	tama5AddrHigh = 0x6 // Bit 0: address bit 4, bits 3-1: command
+	tama5AddrLow  = 0x7 // Writing it executes the command
+	tama5Active   = 0xA
+	tama5ReadLow  = 0xC
+	tama5ReadHigh = 0xD
+
+	// Commands
+	tama5RAMWrite = 0
+	tama5RAMRead  = 1
+	tama5RTCWrite = 2
+	tama5RTCRead  = 3
+
+	// RTC control register (both pages)
+	tama5RTCControl     = 0xD
+	tama5AlarmEnableBit = 2
+	tama5ClockEnableBit = 3
+	tama5AlarmFlagReg   = 0x0 // Page 1
+	tama5DefaultControl = 1 << tama5ClockEnableBit
+)
+
+// TAMA5 is the mapper of Tamagotchi 3. Registers are selected writing $A001 and accessed
+// through $A000, 4 bits at a time. It has 32 bytes of RAM and a TAMA6 RTC with alarm.
+//
+// RTC registers (address bit 4 selects the page, bits 3-0 the register) hold BCD digits:
+//   - page 0: 0-1 seconds, 2-3 minutes, 4-5 hours, 6 day of week, 7-8 day, 9-A month, B-C year
+//   - page 1: 0 alarm flag (write 0 to clear), 2-3 alarm minutes, 4-5 alarm hours
+//   - D (both pages): bit 2 alarm enable, bit 3 clock enable
+type TAMA5 struct {
+	header *Header
+	saveTracker
+
+	ROMBanks uint8
+
+	ROM []uint8
+	RAM []uint8
+
+	// Registers
+	registers [16]uint8
+	selected  uint8
+	result    uint8
+
+	// RTC
+	seconds, minutes, hours  uint8
+	dayOfWeek, day, month    uint8
+	year                     uint8
+	alarmMinutes, alarmHours uint8
+	alarmFlag                bool
+	control                  uint8
+	rtcClockCounter          int
+	clock                    Clock
+}
+
+func (mbc *TAMA5) RAMDump() []uint8 {
+	dump := make([]uint8, len(mbc.RAM), len(mbc.RAM)+tama5RTCLen)
+	copy(dump, mbc.RAM)
+
+	// RTC data:
+	// offset  size    desc
+	// 0       8       unix timestamp when saving
+	// 8       7       seconds, minutes, hours, day of week, day, month, year
+	// 15      2       alarm minutes, alarm hours
+	// 17      1       control
+	dump, _ = binary.Append(dump, binary.LittleEndian, mbc.clock.Now().Unix())
+	return append(dump,
+		mbc.seconds, mbc.minutes, mbc.hours, mbc.dayOfWeek, mbc.day, mbc.month, mbc.year,
+		mbc.alarmMinutes, mbc.alarmHours, mbc.control)
+}
+
+func (mbc *TAMA5) Header() *Header {
+	return mbc.header
+}
+
+func (mbc *TAMA5) SaveState(e *savestate.Encoder) {
+	e.U8s(mbc.registers[:])
+	e.U8(mbc.selected)
+	e.U8(mbc.result)
+	e.Bytes(mbc.RAM)
+
+	// RTC
+	e.U8(mbc.seconds)
+	e.U8(mbc.minutes)
+	e.U8(mbc.hours)
+	e.U8(mbc.dayOfWeek)
+	e.U8(mbc.day)
+	e.U8(mbc.month)
+	e.U8(mbc.year)
+	e.U8(mbc.alarmMinutes)
+	e.U8(mbc.alarmHours)
+	e.Bool(mbc.alarmFlag)
+	e.U8(mbc.control)
+	e.Int(mbc.rtcClockCounter)
+}
+
+func (mbc *TAMA5) LoadState(d *savestate.Decoder) {
+	d.U8s(mbc.registers[:])
+	d.U8(&mbc.selected)
+	d.U8(&mbc.result)
+	d.BytesInto(mbc.RAM)
+
+	// RTC
+	d.U8(&mbc.seconds)
+	d.U8(&mbc.minutes)
+	d.U8(&mbc.hours)
+	d.U8(&mbc.dayOfWeek)
+	d.U8(&mbc.day)
+	d.U8(&mbc.month)
+	d.U8(&mbc.year)
+	d.U8(&mbc.alarmMinutes)
+	d.U8(&mbc.alarmHours)
+	d.Bool(&mbc.alarmFlag)
+	d.U8(&mbc.control)
+	d.Int(&mbc.rtcClockCounter)
+}
+
+func NewTAMA5(rom []uint8, savData []uint8, header *Header, clock Clock) (*TAMA5, error) {
+	if clock == nil {
+		clock = RealClock{}
+	}
+
+	mbc := &TAMA5{
+		header:   header,
+		ROMBanks: uint8(header.ROMBanks),
+		ROM:      rom,
+		RAM:      make([]uint8, tama5RAMLen),
+		day:      1,
+		month:    1,
+		control:  tama5DefaultControl,
+		clock:    clock,
+	}
+
+	switch len(savData) {
+	case 0:
+	case tama5RAMLen: // Save without RTC data
+		copy(mbc.RAM, savData)
+	case tama5RAMLen + tama5RTCLen:
+		copy(mbc.RAM, savData)
+		mbc.parseRTCData(savData[tama5RAMLen:])
+	default:
+		return nil, &SaveSizeError{Expected: tama5RAMLen + tama5RTCLen, Size: len(savData)}
+	}
+
+	return mbc, nil
+}
+
+func (mbc *TAMA5) Tick(ticks int) {
+	if mbc.control&(1<<tama5ClockEnableBit) == 0 {
+		return
+	}
+
+	// RTC clocking: Game Boy runs at 2^22 Hz
+	mbc.rtcClockCounter += ticks
+	if mbc.rtcClockCounter >= 1<<22 {
+		mbc.rtcClockCounter -= 1 << 22
+		mbc.advanceSeconds(1)
+	}
+}
+
+func (mbc *TAMA5) advanceSeconds(seconds int) {
+	total := int(mbc.seconds) + seconds
+	mbc.seconds = uint8(total % 60)
+
+	for range total / 60 {
+		mbc.minutes++
+		if mbc.minutes == 60 {
+			mbc.minutes = 0
+			mbc.hours++
+			if mbc.hours == 24 {
+				mbc.hours = 0
+				mbc.advanceDay()
+			}
+		}
+
+		if mbc.control&(1<<tama5AlarmEnableBit) != 0 && mbc.minutes == mbc.alarmMinutes && mbc.hours == mbc.alarmHours {
+			mbc.alarmFlag = true
+		}
+	}
+}
+
+func (mbc *TAMA5) advanceDay() {
+	mbc.dayOfWeek = (mbc.dayOfWeek + 1) % 7
+	mbc.day++
+	if mbc.day > daysInMonth(mbc.month, mbc.year) {
+		mbc.day = 1
+		mbc.month++
+		if mbc.month > 12 {
+			mbc.month = 1
+			mbc.year = (mbc.year + 1) % 100
+		}
+	}
+}
+
+func daysInMonth(month, year uint8) uint8 {
+	switch month {
+	case 2:
+		if year%4 == 0 {
+			return 29
+		}
+		return 28
+	case 4, 6, 9, 11:
+		return 30
+	default:
+		return 31
+	}
+}
+
+func (mbc *TAMA5) Write(addr uint16, value uint8) {
+	switch addr {
+	case 0xA001:
+		mbc.selected = value & 0xF
+
+	case 0xA000:
+		value &= 0xF
+		mbc.registers[mbc.selected] = value
+
+		if mbc.selected == tama5AddrLow {
+			mbc.execute()
+		}
+	}
+}
+
+func (mbc *TAMA5) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0x4000:
+		return mbc.ROM[addr]
+
+	case addr < 0x8000:
+		return mbc.ROM[mbc.computeRomAddress(addr)]
+
+	case addr == 0xA000:
+		switch mbc.selected {
+		case tama5Active:
+			return 0xF1
+		case tama5ReadLow:
+			return 0xF0 | mbc.result&0xF
+		case tama5ReadHigh:
+			return 0xF0 | mbc.result>>4
+		}
+		return 0xF0
+	}
+
+	return 0xFF
+}
+
+func (mbc *TAMA5) computeRomAddress(cpuAddress uint16) uint {
+	bank := (mbc.registers[tama5BankHigh]&1)<<4 | mbc.registers[tama5BankLow]
+	bank %= mbc.ROMBanks
+	return uint(bank)<<14 | uint(cpuAddress&0x3FFF)
+}
+
+func (mbc *TAMA5) execute() {
+	address := (mbc.registers[tama5AddrHigh]&1)<<4 | mbc.registers[tama5AddrLow]
+	data := mbc.registers[tama5DataHigh]<<4 | mbc.registers[tama5DataLow]
+
+	switch mbc.registers[tama5AddrHigh] >> 1 {
+	case tama5RAMWrite:
+		mbc.store(mbc.RAM, uint(address), data)
+	case tama5RAMRead:
+		mbc.result = mbc.RAM[address]
+	case tama5RTCWrite:
+		mbc.writeRTC(address>>4, address&0xF, data&0xF)
+	case tama5RTCRead:
+		mbc.result = mbc.readRTC(address>>4, address&0xF)
+	default:
+		log.Printf("[WARN] unknown TAMA5 command %d", mbc.registers[tama5AddrHigh]>>1)
+	}
+}
+
+// rtcDigit returns the field holding the BCD digit of the RTC register and whether it is the tens digit
+func (mbc *TAMA5) rtcDigit(page, reg uint8) (field *uint8, tens bool) {
+	if page == 0 {
+		fields := [...]*uint8{&mbc.seconds, &mbc.minutes, &mbc.hours}
+		switch {
+		case reg < 6:
+			return fields[reg/2], reg%2 == 1
+		case reg == 6:
+			return &mbc.dayOfWeek, false
+		case reg < 0xD:
+			dateFields := [...]*uint8{&mbc.day, &mbc.month, &mbc.year}
+			return dateFields[(reg-7)/2], (reg-7)%2 == 1
+		}
+		return nil, false
+	}
+
+	switch reg {
+	case 2, 3:
+		return &mbc.alarmMinutes, reg == 3
+	case 4, 5:
+		return &mbc.alarmHours, reg == 5
+	}
+	return nil, false
+}
+
+func (mbc *TAMA5) readRTC(page, reg uint8) uint8 {
+	switch {
+	case reg == tama5RTCControl:
+		return mbc.control
+	case page == 1 && reg == tama5AlarmFlagReg:
+		if mbc.alarmFlag {
+			return 1
+		}
+		return 0
+	}
+
+	field, tens := mbc.rtcDigit(page, reg)
+	if field == nil {
+		return 0
+	}
+	if tens {
+		return *field / 10
+	}
+	return *field % 10
+}
+
+func (mbc *TAMA5) writeRTC(page, reg, value uint8) {
+	switch {
+	case reg == tama5RTCControl:
+		mbc.control = value
+		return
+	case page == 1 && reg == tama5AlarmFlagReg:
+		mbc.alarmFlag = value != 0
+		return
+	}
+
+	field, tens := mbc.rtcDigit(page, reg)
+	if field == nil {
+		return
+	}
+	if tens {
+		*field = value*10 + *field%10
+	} else {
+		*field = *field/10*10 + value
+	}
+	if page == 0 && reg < 2 {
+		mbc.rtcClockCounter = 0
+	}
+}
+
+func (mbc *TAMA5) parseRTCData(data []uint8) {
+	timestamp := int64(binary.LittleEndian.Uint64(data))
+	mbc.seconds = data[8]
+	mbc.minutes = data[9]
+	mbc.hours = data[10]
+	mbc.dayOfWeek = data[11]
+	mbc.day = data[12]
+	mbc.month = data[13]
+	mbc.year = data[14]
+	mbc.alarmMinutes = data[15]
+	mbc.alarmHours = data[16]
+	mbc.control = data[17]
+
+	// Advance the clock for the time elapsed since saving
+	elapsed := mbc.clock.Now().Sub(time.Unix(timestamp, 0))
+	if elapsed > 0 && mbc.control&(1<<tama5ClockEnableBit) != 0 {
+		mbc.advanceSeconds(int(elapsed.Seconds()))
+	}
+}
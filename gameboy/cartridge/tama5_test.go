@@ -0,0 +1,84 @@
+package cartridge
+
+import "testing"
+
+func newTestTAMA5(t *testing.T, sav []uint8) *TAMA5 {
+	c, err := NewCartridge(testROM(0x80000, 0xFD, 0x04, 0), sav)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*TAMA5)
+}
+
+// tama5Command writes data and executes the command on the address, returning the result
+func tama5Command(mbc *TAMA5, command, address, data uint8) uint8 {
+	write := func(reg, value uint8) {
+		mbc.Write(0xA001, reg)
+		mbc.Write(0xA000, value)
+	}
+	write(tama5DataLow, data&0xF)
+	write(tama5DataHigh, data>>4)
+	write(tama5AddrHigh, command<<1|address>>4)
+	write(tama5AddrLow, address&0xF)
+
+	mbc.Write(0xA001, tama5ReadLow)
+	low := mbc.Read(0xA000) & 0xF
+	mbc.Write(0xA001, tama5ReadHigh)
+	high := mbc.Read(0xA000) & 0xF
+	return high<<4 | low
+}
+
+func TestTAMA5(t *testing.T) {
+	mbc := newTestTAMA5(t, nil)
+
+	mbc.Write(0xA001, tama5Active)
+	if v := mbc.Read(0xA000); v != 0xF1 {
+		t.Errorf("active register %02X", v)
+	}
+
+	// ROM banking
+	mbc.ROM[0x13*0x4000] = 0x99
+	mbc.Write(0xA001, tama5BankLow)
+	mbc.Write(0xA000, 0x3)
+	mbc.Write(0xA001, tama5BankHigh)
+	mbc.Write(0xA000, 0x1)
+	if v := mbc.Read(0x4000); v != 0x99 {
+		t.Errorf("ROM bank $13: read %02X", v)
+	}
+
+	// RAM
+	tama5Command(mbc, tama5RAMWrite, 0x1F, 0xA5)
+	if v := tama5Command(mbc, tama5RAMRead, 0x1F, 0); v != 0xA5 {
+		t.Errorf("RAM read %02X", v)
+	}
+
+	// RTC: set 23:59:59 on 28/02/01 and the alarm at 00:00
+	for reg, digit := range []uint8{9, 5, 9, 5, 3, 2, 0, 8, 2, 2, 0, 1, 0} {
+		tama5Command(mbc, tama5RTCWrite, uint8(reg), digit)
+	}
+	tama5Command(mbc, tama5RTCWrite, 0x1D, tama5DefaultControl|1<<tama5AlarmEnableBit)
+	mbc.Tick(1 << 22)
+
+	readTime := func() (digits [13]uint8) {
+		for reg := range digits {
+			digits[reg] = tama5Command(mbc, tama5RTCRead, uint8(reg), 0)
+		}
+		return digits
+	}
+	if digits := readTime(); digits != [13]uint8{0, 0, 0, 0, 0, 0, 1, 1, 0, 3, 0, 1, 0} {
+		t.Errorf("got time digits %v", digits)
+	}
+	if flag := tama5Command(mbc, tama5RTCRead, 0x10, 0); flag != 1 {
+		t.Errorf("alarm not triggered")
+	}
+
+	// RTC is stored in the save
+	dump := mbc.RAMDump()
+	if len(dump) != tama5RAMLen+tama5RTCLen {
+		t.Fatalf("got save of %d bytes", len(dump))
+	}
+	loaded := newTestTAMA5(t, dump)
+	if loaded.RAM[0x1F] != 0xA5 || loaded.month != 3 || loaded.day != 1 {
+		t.Errorf("loaded RAM %02X, date %d/%d", loaded.RAM[0x1F], loaded.day, loaded.month)
+	}
+}
@@ -1,7 +1,12 @@
 package cpu
 
-func (cpu *CPU) SkipDMGBoot() {
-	cpu.writeAF(0x01B0)
+func (cpu *CPU) SkipDMGBoot(headerChecksum uint8) {
+	// H and C flags are set if the header checksum is not zero
+	if headerChecksum == 0 {
+		cpu.writeAF(0x0180)
+	} else {
+		cpu.writeAF(0x01B0)
+	}
 	cpu.writeBC(0x0013)
 	cpu.writeDE(0x00D8)
 	cpu.writeHL(0x014D)
@@ -9,6 +14,15 @@ func (cpu *CPU) SkipDMGBoot() {
 	cpu.PC = 0x100
 }
 
+func (cpu *CPU) SkipSGBBoot() {
+	cpu.writeAF(0x0100)
+	cpu.writeBC(0x0014)
+	cpu.writeDE(0x0000)
+	cpu.writeHL(0xC060)
+	cpu.SP = 0xFFFE
+	cpu.PC = 0x100
+}
+
 func (cpu *CPU) SkipCGBBoot(bRegister uint8) {
 	cpu.writeAF(0x1180)
 	cpu.writeB(bRegister)
@@ -39,6 +39,13 @@ type CPU struct {
 	halted  bool
 	haltBug bool
 
+	// When locked the CPU does not execute instructions anymore (e.g. boot ROM header check failed),
+	// while the other components keep running
+	locked bool
+
+	// Called when an illegal opcode locks the CPU
+	IllegalOpcodeCallback func(addr uint16, opcode uint8)
+
 	// CGB flag
 	isCGB bool
 	// After speed switching, CPU will be halted for some time
@@ -96,6 +103,11 @@ func (cpu *CPU) Tick(ticks int) {
 }
 
 func (cpu *CPU) ExecuteInstruction() {
+	if cpu.locked {
+		cpu.Tick(4)
+		return
+	}
+
 	if !cpu.halted && !cpu.mmu.VDMAActive() {
 		opcode := cpu.ReadNextByte()
 
@@ -119,6 +131,15 @@ func (cpu *CPU) ExecuteInstruction() {
 	cpu.handleInterrupts()
 }
 
+// Lock stops the CPU forever (until reset)
+func (cpu *CPU) Lock() {
+	cpu.locked = true
+}
+
+func (cpu *CPU) Locked() bool {
+	return cpu.locked
+}
+
 func (cpu *CPU) SwitchSpeed(doubleSpeed bool) {
 	cpu.speedSwitchHaltedTicks = 0x20000
 	cpu.halted = true
@@ -1325,7 +1325,16 @@ func (cpu *CPU) PREFIX() {
 	cpu.prefixedOpcodesTable[opcode>>3](opcode)
 }
 
+// INVALID hard locks the CPU like the real hardware does on illegal opcodes
+// ($D3, $DB, $DD, $E3, $E4, $EB, $EC, $ED, $F4, $FC and $FD)
 func (cpu *CPU) INVALID() {
-	opcode := cpu.mmu.Read(cpu.PC - 1)
-	log.Fatalf("OPCODE 0x%02X NOT RECOGNIZED\n", opcode)
+	// Leave PC on the offending instruction
+	cpu.PC--
+	opcode := cpu.mmu.Read(cpu.PC)
+	log.Printf("[WARN] illegal opcode 0x%02X at $%04X, CPU locked", opcode, cpu.PC)
+
+	cpu.Lock()
+	if cpu.IllegalOpcodeCallback != nil {
+		cpu.IllegalOpcodeCallback(cpu.PC, opcode)
+	}
 }
@@ -3117,3 +3117,42 @@ func Test_SET_B3_R8(t *testing.T) {
 		})
 	}
 }
+
+type tickCounter int
+
+func (c *tickCounter) Tick(ticks int) { *c += tickCounter(ticks) }
+
+func Test_INVALID(t *testing.T) {
+	for _, opcode := range []uint8{0xD3, 0xDB, 0xDD, 0xE3, 0xE4, 0xEB, 0xEC, 0xED, 0xF4, 0xFC, 0xFD} {
+		t.Run(fmt.Sprintf("%02X", opcode), func(t *testing.T) {
+			cpu := mockCPU()
+			ticks := new(tickCounter)
+			cpu.AddTicker(ticks)
+
+			var gotAddr uint16
+			var gotOpcode uint8
+			calls := 0
+			cpu.IllegalOpcodeCallback = func(addr uint16, opcode uint8) {
+				gotAddr, gotOpcode = addr, opcode
+				calls++
+			}
+
+			cpu.PC = 0x0200
+			writeTestProgram(cpu, opcode, NOP_OPCODE)
+			for range 10 {
+				cpu.ExecuteInstruction()
+			}
+
+			if !cpu.Locked() || cpu.PC != 0x0200 {
+				t.Errorf("expected CPU locked at $0200, PC = %04X", cpu.PC)
+			}
+			if calls != 1 || gotAddr != 0x0200 || gotOpcode != opcode {
+				t.Errorf("callback called %d times with ($%04X, %02X)", calls, gotAddr, gotOpcode)
+			}
+			// Other components keep being clocked
+			if *ticks != 40 {
+				t.Errorf("got %d ticks, expected 40", *ticks)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package cpu
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+
+func (cpu *CPU) SaveState(e *savestate.Encoder) {
+	e.U8(cpu.A)
+	e.U8(cpu.F)
+	e.U8(cpu.B)
+	e.U8(cpu.C)
+	e.U8(cpu.D)
+	e.U8(cpu.E)
+	e.U8(cpu.H)
+	e.U8(cpu.L)
+	e.U16(cpu.SP)
+	e.U16(cpu.PC)
+
+	e.Bool(cpu.IME)
+	e.Bool(cpu._EIDelayed)
+
+	e.U8(cpu.interruptMaskRequested)
+	e.Bool(cpu.writeIEHasCancelledInterrupt)
+	e.Bool(cpu.interruptCancelled)
+
+	e.Bool(cpu.halted)
+	e.Bool(cpu.haltBug)
+	e.Bool(cpu.locked)
+	e.Int(cpu.speedSwitchHaltedTicks)
+}
+
+func (cpu *CPU) LoadState(d *savestate.Decoder) {
+	d.U8(&cpu.A)
+	d.U8(&cpu.F)
+	d.U8(&cpu.B)
+	d.U8(&cpu.C)
+	d.U8(&cpu.D)
+	d.U8(&cpu.E)
+	d.U8(&cpu.H)
+	d.U8(&cpu.L)
+	d.U16(&cpu.SP)
+	d.U16(&cpu.PC)
+
+	d.Bool(&cpu.IME)
+	d.Bool(&cpu._EIDelayed)
+
+	d.U8(&cpu.interruptMaskRequested)
+	d.Bool(&cpu.writeIEHasCancelledInterrupt)
+	d.Bool(&cpu.interruptCancelled)
+
+	d.Bool(&cpu.halted)
+	d.Bool(&cpu.haltBug)
+	d.Bool(&cpu.locked)
+	d.Int(&cpu.speedSwitchHaltedTicks)
+}
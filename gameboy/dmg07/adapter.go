@@ -0,0 +1,123 @@
+package dmg07
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/serial"
+
+// shifter clocks a byte into a Game Boy one bit at a time
+type shifter struct {
+	out, in uint8
+	// Bit clocked, waiting to be exchanged by the Game Boy
+	pending bool
+}
+
+// clock clocks the next bit. If the Game Boy did not exchange the previous one (it is not waiting
+// for the external clock), the line was high.
+func (s *shifter) clock() {
+	s.settle()
+	s.pending = true
+}
+
+// settle completes the last bit clocked
+func (s *shifter) settle() {
+	if s.pending {
+		s.exchange(1)
+	}
+}
+
+func (s *shifter) exchange(bitIn uint8) uint8 {
+	s.pending = false
+	bit := s.out >> 7
+	s.out <<= 1
+	s.in = s.in<<1 | bitIn&1
+	return bit
+}
+
+// Adapter is a DMG-07 for Game Boys emulated in the same thread and run in lockstep: each one is
+// plugged into a port and its ticks move the adapter clock forward, so the four Game Boys receive
+// every bit at the same emulated time.
+type Adapter struct {
+	hub   *Hub
+	ports [Players]*port
+
+	// Emulated time of the adapter (the time of the Game Boy ahead)
+	time uint64
+	// Start of the current byte and bits clocked in it
+	byteStart uint64
+	bits      int
+}
+
+// port is the plug of a player, clocked by its Game Boy
+type port struct {
+	adapter *Adapter
+	time    uint64
+	shifter
+}
+
+// NewAdapter creates a DMG-07 with nothing plugged in
+func NewAdapter() *Adapter {
+	a := &Adapter{hub: NewHub()}
+	for p := range a.ports {
+		a.ports[p] = &port{adapter: a}
+	}
+	return a
+}
+
+// Port returns the serial device plugged into the port of a player (0-3)
+func (a *Adapter) Port(player int) serial.Device {
+	return a.ports[player]
+}
+
+// Hub returns the state of the adapter
+func (a *Adapter) Hub() *Hub {
+	return a.hub
+}
+
+// advance clocks the bits due until time
+func (a *Adapter) advance(time uint64) {
+	a.time = time
+
+	for a.byteStart+uint64(a.bits)*BitPeriod <= a.time {
+		if a.bits == 0 {
+			out := a.hub.Next()
+			for p, port := range a.ports {
+				port.out = out[p]
+			}
+		}
+
+		if a.bits < 8 {
+			for _, port := range a.ports {
+				port.clock()
+			}
+			a.bits++
+			continue
+		}
+
+		// End of the byte
+		var in [Players]uint8
+		for p, port := range a.ports {
+			port.settle()
+			in[p] = port.in
+		}
+		a.hub.Receive(in)
+		a.byteStart += uint64(a.hub.BytePeriod())
+		a.bits = 0
+	}
+}
+
+func (p *port) Tick(ticks int) {
+	p.time += uint64(ticks)
+	if p.time > p.adapter.time {
+		p.adapter.advance(p.time)
+	}
+}
+
+func (p *port) DrivesClock() bool {
+	return p.pending
+}
+
+func (p *port) ExchangeBit(out uint8) uint8 {
+	// The adapter does not answer the Game Boy driving the clock
+	if !p.pending {
+		return 1
+	}
+	return p.exchange(out)
+}
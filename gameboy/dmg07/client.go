@@ -0,0 +1,155 @@
+package dmg07
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/internal/netlink"
+)
+
+// Client plugs the serial port into a DMG-07 server. Each byte sent by the adapter is clocked into
+// the Game Boy at 8192 Hz of emulated time and the byte shifted out is sent back.
+//
+// The connection is established again when it is lost; in the meantime the cable is unplugged.
+type Client struct {
+	endpoint *netlink.Endpoint[hubPort]
+
+	// Byte being clocked into the Game Boy (used only by the emulation)
+	shifter
+	bits int
+	// Ticks before the next bit
+	wait int
+	// Session the byte was received from
+	from *clientSession
+}
+
+// hubPort is the port of the adapter the emulator is plugged into
+type hubPort struct {
+	player uint8
+	// Bytes sent by the adapter
+	bytes chan uint8
+}
+
+type clientSession = netlink.Session[hubPort]
+
+// DialHub connects to the DMG-07 server on addr (host:port), retrying until it is reachable
+// and whenever the connection is lost
+func DialHub(addr string) *Client {
+	c := &Client{
+		endpoint: netlink.NewEndpoint(netlink.Protocol[hubPort]{
+			Name:      "[DMG-07]",
+			Handshake: handshake,
+			Serve:     serve,
+		}),
+	}
+	c.endpoint.Dial(addr)
+	return c
+}
+
+// Connected reports whether the serial port is plugged into the adapter
+func (c *Client) Connected() bool {
+	return c.endpoint.Connected()
+}
+
+// Player returns the number of the player (1-4, 0 if unplugged)
+func (c *Client) Player() int {
+	if s := c.endpoint.Session(); s != nil {
+		return int(s.State.player)
+	}
+	return 0
+}
+
+// Close unplugs the cable and stops reconnecting
+func (c *Client) Close() {
+	c.endpoint.Close()
+}
+
+// handshake checks the protocol version and receives the player assigned
+func handshake(conn net.Conn) (hubPort, error) {
+	if _, err := conn.Write(append([]uint8(hubMagic), HubVersion)); err != nil {
+		return hubPort{}, err
+	}
+
+	header := make([]uint8, len(hubMagic)+2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return hubPort{}, err
+	}
+	if string(header[:len(hubMagic)]) != hubMagic {
+		return hubPort{}, errors.New("the other end is not a DMG-07")
+	}
+	if version := header[len(hubMagic)]; version != HubVersion {
+		return hubPort{}, fmt.Errorf("DMG-07 protocol version %d, want %d", version, HubVersion)
+	}
+	player := header[len(hubMagic)+1]
+	if player == 0 || player > Players {
+		return hubPort{}, errors.New("the adapter is full")
+	}
+
+	return hubPort{
+		player: player,
+		bytes:  make(chan uint8, 16),
+	}, nil
+}
+
+// serve plugs the cable until the connection is lost. The adapter sends a byte at least every
+// BytePeriod, so no heartbeats are needed.
+func serve(s *clientSession) {
+	log.Printf("[DMG-07] connected as player %d", s.State.player)
+
+	buf := make([]uint8, 1)
+	for s.Receive(buf) == nil {
+		select {
+		case s.State.bytes <- buf[0]:
+		case <-s.Done():
+		}
+	}
+
+	log.Println("[DMG-07] cable unplugged")
+}
+
+// Tick clocks the bits of the byte received from the adapter
+func (c *Client) Tick(ticks int) {
+	if c.from == nil {
+		s := c.endpoint.Session()
+		if s == nil {
+			return
+		}
+
+		// Non blocking
+		select {
+		case b := <-s.State.bytes:
+			c.from, c.out, c.in, c.bits, c.wait = s, b, 0, 0, 0
+		default:
+			return
+		}
+	}
+
+	for c.wait -= ticks; c.wait <= 0; c.wait += BitPeriod {
+		if c.bits < 8 {
+			c.clock()
+			c.bits++
+			continue
+		}
+
+		// End of the byte
+		c.settle()
+		c.from.Send([]uint8{c.in})
+		c.from = nil
+		return
+	}
+}
+
+func (c *Client) DrivesClock() bool {
+	return c.pending
+}
+
+func (c *Client) ExchangeBit(out uint8) uint8 {
+	// The adapter does not answer the Game Boy driving the clock
+	if !c.pending {
+		return 1
+	}
+	return c.exchange(out)
+}
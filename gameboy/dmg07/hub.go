@@ -0,0 +1,227 @@
+// Package dmg07 emulates the DMG-07 4 Player Adapter, which links up to four Game Boys
+package dmg07
+
+const (
+	Players = 4
+
+	// The adapter drives the clock of all the Game Boys, which wait for the external clock.
+	// Bits are clocked at 8192 Hz (ticks of the 4 MiHz clock).
+	BitPeriod = 512
+
+	pingHeader = 0xFE
+	ack        = 0x88
+	startByte  = 0xAA // Sent by player 1 to start the transmission
+	startReply = 0xCC // Sent to everyone before the transmission starts
+	resetByte  = 0xFF // Sent by player 1 to go back to the ping phase
+
+	// Repetitions of the start and reset bytes
+	startCount = 4
+	resetCount = 4
+
+	maxSize = 16
+)
+
+type phase int
+
+const (
+	phasePing phase = iota
+	phaseStart
+	phaseTransmission
+)
+
+// Hub is the protocol of the DMG-07. Every byte is sent to the four Game Boys at the same time,
+// while the byte in their SB is received. The adapter starts in the ping phase, sending packets of
+// 4 bytes to each Game Boy:
+//
+//	sent    answer
+//	$FE     $88 (ACK1)
+//	STAT    $88 (ACK2)
+//	STAT    RATE
+//	STAT    SIZE
+//
+// STAT holds the number of the player in bits 0-2 and a bit for each player connected in bits 4-7
+// (bit 4 is player 1). A player is connected when it acknowledges the packet. RATE and SIZE are
+// only read from player 1: the low nibble of RATE sets the pause between bytes and SIZE the bytes
+// sent by each player in a packet.
+//
+// When player 1 answers $AA four times in a row, the adapter sends $CC four times and enters the
+// transmission phase. Then every cycle lasts 4*SIZE bytes: each Game Boy sends its packet in the
+// first SIZE bytes, while all of them receive the packets sent by the four players in the previous
+// cycle, in order (zeros for players not connected). Four $FF sent by player 1 in its packet
+// go back to the ping phase.
+type Hub struct {
+	phase phase
+	// Byte of the packet or of the cycle
+	slot int
+
+	connected [Players]bool
+	// Acknowledgements received in the current ping packet
+	acks [Players]int
+
+	rate, size uint8
+	// Consecutive start or reset bytes sent by player 1
+	count int
+
+	// Packets received in the current cycle and the ones relayed in it
+	packets [Players][]uint8
+	relay   []uint8
+}
+
+func NewHub() *Hub {
+	return &Hub{size: 1}
+}
+
+// Next returns the bytes sent to each player in the next slot
+func (h *Hub) Next() [Players]uint8 {
+	var out [Players]uint8
+
+	for p := range out {
+		switch h.phase {
+		case phasePing:
+			if h.slot == 0 {
+				out[p] = pingHeader
+			} else {
+				out[p] = h.stat(p)
+			}
+		case phaseStart:
+			out[p] = startReply
+		case phaseTransmission:
+			out[p] = h.relay[h.slot]
+		}
+	}
+	return out
+}
+
+// Receive is called with the bytes received from each player at the end of the slot
+// ($FF for players not connected, like an unplugged cable)
+func (h *Hub) Receive(in [Players]uint8) {
+	switch h.phase {
+	case phasePing:
+		h.receivePing(in)
+	case phaseStart:
+		if h.slot++; h.slot == startCount {
+			h.startTransmission()
+		}
+	case phaseTransmission:
+		h.receiveTransmission(in)
+	}
+}
+
+func (h *Hub) stat(player int) uint8 {
+	stat := uint8(player + 1)
+	for p, connected := range h.connected {
+		if connected {
+			stat |= 1 << (4 + p)
+		}
+	}
+	return stat
+}
+
+func (h *Hub) receivePing(in [Players]uint8) {
+	// Player 1 asks to start the transmission
+	starting := in[0] == startByte
+	if starting {
+		h.count++
+	} else {
+		h.count = 0
+	}
+	if h.count == startCount {
+		h.phase, h.slot, h.count = phaseStart, 0, 0
+		h.acks = [Players]int{}
+		return
+	}
+
+	switch h.slot {
+	case 0, 1:
+		for p, b := range in {
+			if b == ack {
+				h.acks[p]++
+			}
+		}
+	case 2:
+		if !starting {
+			h.rate = in[0]
+		}
+	case 3:
+		if !starting {
+			h.size = min(max(in[0], 1), maxSize)
+		}
+	}
+
+	if h.slot++; h.slot == 4 {
+		h.slot = 0
+		for p := range h.acks {
+			// Player 1 is still there while asking to start the transmission
+			if p != 0 || h.count == 0 {
+				h.connected[p] = h.acks[p] == 2
+			}
+			h.acks[p] = 0
+		}
+	}
+}
+
+func (h *Hub) startTransmission() {
+	h.phase, h.slot = phaseTransmission, 0
+	h.relay = make([]uint8, Players*int(h.size))
+	for p := range h.packets {
+		h.packets[p] = make([]uint8, 0, h.size)
+	}
+}
+
+func (h *Hub) receiveTransmission(in [Players]uint8) {
+	size := int(h.size)
+
+	if h.slot < size {
+		for p, b := range in {
+			h.packets[p] = append(h.packets[p], b)
+		}
+
+		// Player 1 asks to go back to the ping phase
+		if in[0] == resetByte {
+			h.count++
+		} else {
+			h.count = 0
+		}
+		if h.count == resetCount {
+			h.reset()
+			return
+		}
+	}
+
+	if h.slot++; h.slot < len(h.relay) {
+		return
+	}
+
+	// The packets received are relayed in the next cycle
+	h.slot = 0
+	for p, packet := range h.packets {
+		relay := h.relay[p*size : (p+1)*size]
+		if h.connected[p] {
+			copy(relay, packet)
+		} else {
+			clear(relay)
+		}
+		h.packets[p] = packet[:0]
+	}
+}
+
+func (h *Hub) reset() {
+	h.phase, h.slot, h.count = phasePing, 0, 0
+	h.acks = [Players]int{}
+}
+
+// Transmitting reports whether the adapter is in the transmission phase
+func (h *Hub) Transmitting() bool {
+	return h.phase == phaseTransmission
+}
+
+// Connected returns the players that acknowledged the last ping packet
+func (h *Hub) Connected() [Players]bool {
+	return h.connected
+}
+
+// BytePeriod returns the ticks between the start of two bytes: the 8 bits followed by a pause of
+// about 1 to 16 ms depending on the RATE sent by player 1 (an approximation of the timing of the adapter)
+func (h *Hub) BytePeriod() int {
+	return 8*BitPeriod + (1+int(h.rate&0x0F))*4096
+}
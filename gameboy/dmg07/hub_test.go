@@ -0,0 +1,116 @@
+package dmg07
+
+import (
+	"slices"
+	"testing"
+)
+
+// exchange transfers a byte with each player and returns the bytes sent by the adapter
+func exchange(h *Hub, in ...uint8) [Players]uint8 {
+	var bytes [Players]uint8
+	for p := range bytes {
+		bytes[p] = 0xFF
+		if p < len(in) {
+			bytes[p] = in[p]
+		}
+	}
+
+	out := h.Next()
+	h.Receive(bytes)
+	return out
+}
+
+// ping answers a ping packet with the same bytes for players 1 and 2 and returns the bytes received by player 2
+func ping(h *Hub, answer ...uint8) []uint8 {
+	var received []uint8
+	for _, b := range answer {
+		out := exchange(h, b, b)
+		received = append(received, out[1])
+	}
+	return received
+}
+
+func TestPing(t *testing.T) {
+	h := NewHub()
+
+	if got := ping(h, ack, ack, 0x00, 0x02); !slices.Equal(got, []uint8{0xFE, 0x02, 0x02, 0x02}) {
+		t.Errorf("first ping = % X", got)
+	}
+	// Players 1 and 2 are connected
+	if got := ping(h, ack, ack, 0x00, 0x02); !slices.Equal(got, []uint8{0xFE, 0x32, 0x32, 0x32}) {
+		t.Errorf("second ping = % X", got)
+	}
+	if connected := h.Connected(); connected != [Players]bool{true, true, false, false} {
+		t.Errorf("connected = %v", connected)
+	}
+
+	// Player 2 leaves
+	exchange(h, ack, 0xFF)
+	exchange(h, ack, 0xFF)
+	exchange(h, 0x00)
+	exchange(h, 0x02)
+	if out := exchange(h); out[0] != 0xFE {
+		t.Errorf("header = %02X", out[0])
+	}
+	if out := exchange(h); out[0] != 0x11 {
+		t.Errorf("STAT of player 1 = %02X, want 11", out[0])
+	}
+}
+
+func TestTransmission(t *testing.T) {
+	h := NewHub()
+	ping(h, ack, ack, 0x03, 0x02)
+	ping(h, ack, ack, 0x03, 0x02)
+
+	// Player 1 starts the transmission
+	ping(h, startByte, startByte, startByte, startByte)
+	for range startCount {
+		if out := exchange(h); out[0] != startReply || out[3] != startReply {
+			t.Fatalf("start = % X", out)
+		}
+	}
+	if !h.Transmitting() {
+		t.Fatal("transmission not started")
+	}
+	if period := h.BytePeriod(); period != 8*BitPeriod+4*4096 {
+		t.Errorf("byte period = %d", period)
+	}
+
+	// 2 bytes per player, players 3 and 4 are not connected
+	var received []uint8
+	for _, in := range [][Players]uint8{
+		{0x11, 0x21, 0x31, 0x41}, {0x12, 0x22, 0x32, 0x42}, {}, {}, {}, {}, {}, {},
+		{0x13, 0x23}, {0x14, 0x24}, {}, {}, {}, {}, {}, {},
+	} {
+		out := exchange(h, in[:]...)
+		received = append(received, out[2])
+	}
+
+	want := []uint8{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0x11, 0x12, 0x21, 0x22, 0, 0, 0, 0,
+	}
+	if !slices.Equal(received, want) {
+		t.Errorf("received % X, want % X", received, want)
+	}
+}
+
+func TestReset(t *testing.T) {
+	h := NewHub()
+	ping(h, ack, ack, 0x00, 0x04)
+	ping(h, startByte, startByte, startByte, startByte)
+	for range startCount {
+		exchange(h)
+	}
+
+	// The first 4 bytes of the cycle are the packet of player 1
+	for range 4 {
+		exchange(h, resetByte)
+	}
+	if h.Transmitting() {
+		t.Fatal("still transmitting")
+	}
+	if out := exchange(h); out[0] != 0xFE {
+		t.Errorf("ping header = %02X", out[0])
+	}
+}
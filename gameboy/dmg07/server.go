@@ -0,0 +1,250 @@
+package dmg07
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/internal/netlink"
+)
+
+const (
+	// Sent by the emulators when they connect, followed by the protocol version. The server answers
+	// with the same header followed by the player assigned (1-4, 0 if the adapter is full). Then the
+	// server sends each byte of the adapter, the emulator answers with the byte received from SB.
+	hubMagic   = "LBHUB"
+	HubVersion = 1 // Increased when the protocol changes
+
+	// Emulators not answering in time are disconnected, so that the others can go on
+	replyTimeout = time.Second
+
+	clockRate = 4194304
+)
+
+// Server is a DMG-07 for emulators connecting over TCP, for example a hub process running on the
+// same machine. Since the emulators are not synchronized, the adapter runs in real time: each byte
+// is sent to every emulator, which clocks it into its Game Boy and sends back the byte received.
+type Server struct {
+	hub      *Hub
+	listener net.Listener
+
+	mu      sync.Mutex
+	players [Players]*player
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// player is an emulator connected to a port, its state holds the bytes answered
+type player = netlink.Session[chan uint8]
+
+// ListenHub runs a DMG-07 accepting up to four emulators on addr (host:port)
+func ListenHub(addr string) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		hub:      NewHub(),
+		listener: ln,
+		closed:   make(chan struct{}),
+	}
+	go s.accept()
+	go s.run()
+	return s, nil
+}
+
+// Addr returns the address the adapter is listening on
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Players returns the players connected
+func (s *Server) Players() [Players]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var connected [Players]bool
+	for p, pl := range s.players {
+		connected[p] = pl != nil
+	}
+	return connected
+}
+
+// Close disconnects all the players and stops the adapter
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.listener.Close()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, pl := range s.players {
+			if pl != nil {
+				pl.Close()
+			}
+		}
+	})
+}
+
+// accept plugs the emulators connecting into the first free port
+func (s *Server) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Println("[DMG-07]", err)
+			}
+			return
+		}
+
+		go func() {
+			if err := s.plug(conn); err != nil {
+				log.Printf("[DMG-07] %s: %v", conn.RemoteAddr(), err)
+				conn.Close()
+			}
+		}()
+	}
+}
+
+func (s *Server) plug(conn net.Conn) error {
+	conn.SetDeadline(time.Now().Add(netlink.HandshakeTimeout))
+	header := make([]uint8, len(hubMagic)+1)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if string(header[:len(hubMagic)]) != hubMagic {
+		return errors.New("not an emulator")
+	}
+	if version := header[len(hubMagic)]; version != HubVersion {
+		return fmt.Errorf("protocol version %d, want %d", version, HubVersion)
+	}
+
+	pl := netlink.NewSession("[DMG-07]", conn, make(chan uint8, 1))
+
+	s.mu.Lock()
+	number := 0
+	for p := range s.players {
+		if s.players[p] == nil {
+			s.players[p] = pl
+			number = p + 1
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	_, err := conn.Write(append([]uint8(hubMagic), HubVersion, uint8(number)))
+	if number == 0 {
+		return errors.New("the adapter is full")
+	}
+	if err != nil {
+		s.unplug(number-1, pl)
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	log.Printf("[DMG-07] player %d connected from %s", number, conn.RemoteAddr())
+	go s.listen(number-1, pl)
+	return nil
+}
+
+func (s *Server) unplug(p int, pl *player) {
+	pl.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.players[p] == pl {
+		s.players[p] = nil
+		log.Printf("[DMG-07] player %d disconnected", p+1)
+	}
+}
+
+// listen receives the bytes answered by a player until the connection is lost
+func (s *Server) listen(p int, pl *player) {
+	defer s.unplug(p, pl)
+	buf := make([]uint8, 1)
+
+	for pl.Receive(buf) == nil {
+		select {
+		case pl.State <- buf[0]:
+		case <-pl.Done():
+			return
+		}
+	}
+}
+
+// run sends the bytes of the adapter in real time
+func (s *Server) run() {
+	next := time.Now()
+
+	for {
+		s.mu.Lock()
+		players := s.players
+		s.mu.Unlock()
+
+		out := s.hub.Next()
+		for p, pl := range players {
+			if pl != nil && pl.Send([]uint8{out[p]}) != nil {
+				s.unplug(p, pl)
+				players[p] = nil
+			}
+		}
+
+		// Players not connected answer $FF like an unplugged cable
+		in := [Players]uint8{0xFF, 0xFF, 0xFF, 0xFF}
+		deadline := time.Now().Add(replyTimeout)
+		for p, pl := range players {
+			if pl == nil {
+				continue
+			}
+			b, err := reply(pl, deadline)
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Printf("[DMG-07] player %d: %v", p+1, err)
+				}
+				s.unplug(p, pl)
+				continue
+			}
+			in[p] = b
+		}
+		s.hub.Receive(in)
+
+		next = next.Add(time.Duration(s.hub.BytePeriod()) * time.Second / clockRate)
+		wait := time.Until(next)
+		if wait < 0 {
+			// Late because of a slow player, do not try to catch up
+			next, wait = time.Now(), 0
+		}
+		select {
+		case <-s.closed:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// reply waits for the byte answered by the player until the deadline
+func reply(pl *player, deadline time.Time) (uint8, error) {
+	select {
+	case b := <-pl.State:
+		return b, nil
+	default:
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case b := <-pl.State:
+		return b, nil
+	case <-pl.Done():
+		return 0, net.ErrClosed
+	case <-timer.C:
+		return 0, errors.New("not answering")
+	}
+}
@@ -0,0 +1,90 @@
+package dmg07
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/internal/netlink/netlinktest"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/serial"
+)
+
+// runPlayer plugs a serial port into the adapter and answers $88 to every byte like a game in
+// the ping phase, sending the bytes received to the channel until the test ends
+func runPlayer(t *testing.T, addr string, received chan<- uint8) *Client {
+	t.Helper()
+
+	client := DialHub(addr)
+	port := serial.NewPort(false)
+	port.SetDevice(client)
+	port.RequestInterrupt = func() {
+		select {
+		case received <- port.SB:
+		default:
+		}
+		port.Write(serial.SBAddr, ack)
+		port.Write(serial.SCAddr, 0x80)
+	}
+	port.Write(serial.SBAddr, ack)
+	port.Write(serial.SCAddr, 0x80)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				port.Tick(64)
+				time.Sleep(time.Microsecond)
+			}
+		}
+	}()
+
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+		client.Close()
+	})
+	return client
+}
+
+func waitPlayers(t *testing.T, s *Server, want [Players]bool) {
+	t.Helper()
+	netlinktest.WaitUntil(t, func() bool { return s.Players() == want })
+}
+
+func TestServer(t *testing.T) {
+	server, err := ListenHub("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	received := make(chan uint8, 64)
+	first := runPlayer(t, server.Addr().String(), make(chan uint8))
+	waitPlayers(t, server, [Players]bool{true, false, false, false})
+	second := runPlayer(t, server.Addr().String(), received)
+	waitPlayers(t, server, [Players]bool{true, true, false, false})
+
+	// Wait for a ping packet telling player 2 that both players are connected
+	want := []uint8{0xFE, 0x32, 0x32, 0x32}
+	var got []uint8
+	for deadline := time.After(5 * time.Second); !bytes.Contains(got, want); {
+		select {
+		case b := <-received:
+			got = append(got, b)
+		case <-deadline:
+			t.Fatalf("player 2 received % X", got)
+		}
+	}
+
+	if first.Player() != 1 || second.Player() != 2 {
+		t.Errorf("players %d and %d, want 1 and 2", first.Player(), second.Player())
+	}
+
+	first.Close()
+	waitPlayers(t, server, [Players]bool{false, true, false, false})
+}
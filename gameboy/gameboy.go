@@ -1,15 +1,19 @@
 package gameboy
 
 import (
+	"fmt"
+	"io"
 	"log"
 
 	"github.com/danielecanzoneri/lucky-boy/gameboy/audio"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/cartridge"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/cpu"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/infrared"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/joypad"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/mmu"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/ppu"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/serial"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/sgb"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/timer"
 )
 
@@ -19,28 +23,79 @@ const (
 	Auto SystemModel = iota
 	DMG
 	CGB
+	SGB
 )
 
+// String returns the name of the model accepted by ParseModel
+func (m SystemModel) String() string {
+	switch m {
+	case DMG:
+		return "dmg"
+	case CGB:
+		return "cgb"
+	case SGB:
+		return "sgb"
+	default:
+		return "auto"
+	}
+}
+
+// ParseModel converts a model name (auto, dmg, cgb, sgb) to a SystemModel
+func ParseModel(model string) (SystemModel, error) {
+	switch model {
+	case "auto":
+		return Auto, nil
+	case "dmg":
+		return DMG, nil
+	case "cgb":
+		return CGB, nil
+	case "sgb":
+		return SGB, nil
+	default:
+		return Auto, fmt.Errorf("invalid model type: %s", model)
+	}
+}
+
 type GameBoy struct {
 	CPU        *cpu.CPU
 	SerialPort *serial.Port
+	Infrared   *infrared.Port // Also drives the IR port of HuC1 and HuC3 cartridges
 	Timer      *timer.Timer
 	Memory     *mmu.MMU
 	PPU        *ppu.PPU
 	Joypad     *joypad.Joypad
 	APU        *audio.APU
+	SGB        *sgb.SGB // Only when emulating the Super Game Boy
 
-	// DMG or CGB (Auto to automatically detect it based on cartridge)
+	// DMG, CGB or SGB (Auto to automatically detect it based on cartridge)
 	Model          SystemModel
 	EmulationModel SystemModel // Actual model used to emulate
 
 	// Input provider for detecting key presses
 	inputProvider joypad.InputProvider
+	// Tilt provider for cartridges with accelerometer
+	tiltProvider cartridge.TiltProvider
+	// Image source for the Game Boy Camera
+	imageSource cartridge.ImageSource
+	// Device plugged into the serial port (survives resets)
+	serialDevice serial.Device
+	// Device facing the IR port (survives resets)
+	infraredDevice infrared.Device
+
+	// Called when the CPU locks up on an illegal opcode (survives resets)
+	IllegalOpcodeCallback func(addr uint16, opcode uint8)
+	// Called when the cartridge rumble motor is switched on or off (survives resets)
+	RumbleCallback func(on bool)
 
 	sampleRate float64
 	sampleBuff chan float32
+
+	// Ticks elapsed, used by the frontend-free runner
+	ticks tickCounter
 }
 
+// New creates a GameBoy that sends audio samples to audioSampleBuffer. Samples are dropped
+// when the buffer is full, so the emulation never blocks (the buffer can also be nil).
 func New(audioSampleBuffer chan float32, sampleRate float64) *GameBoy {
 	gb := &GameBoy{
 		sampleRate: sampleRate,
@@ -55,20 +110,88 @@ func (gb *GameBoy) SetInputProvider(provider joypad.InputProvider) {
 	gb.inputProvider = provider
 }
 
+// SetTiltProvider sets the provider of the accelerometer readings (MBC7 cartridges)
+func (gb *GameBoy) SetTiltProvider(provider cartridge.TiltProvider) {
+	gb.tiltProvider = provider
+}
+
+// SetImageSource sets the images seen by the Game Boy Camera
+func (gb *GameBoy) SetImageSource(source cartridge.ImageSource) {
+	gb.imageSource = source
+}
+
+// SetSerialDevice plugs a device into the serial port (nil unplugs the cable). The device
+// plugged before is closed if it holds resources (io.Closer), like the file of a logger.
+func (gb *GameBoy) SetSerialDevice(device serial.Device) {
+	if c, ok := gb.serialDevice.(io.Closer); ok && gb.serialDevice != device {
+		if err := c.Close(); err != nil {
+			log.Println("[WARN] closing serial device:", err)
+		}
+	}
+
+	gb.serialDevice = device
+	if gb.SerialPort != nil {
+		gb.SerialPort.SetDevice(device)
+	}
+}
+
+// SetInfraredDevice points the IR port at a device (nil for complete darkness). The port of
+// the CGB and the one of HuC1 and HuC3 cartridges see the same light.
+func (gb *GameBoy) SetInfraredDevice(device infrared.Device) {
+	gb.infraredDevice = device
+	if gb.Infrared != nil {
+		gb.Infrared.SetDevice(device)
+	}
+}
+
+// SaveChanged reports whether the battery backed memory of the cartridge has changed since
+// the last call, so that the battery save should be stored again
+func (gb *GameBoy) SaveChanged() bool {
+	if gb.Memory == nil {
+		return false
+	}
+
+	if c, ok := gb.Memory.Cartridge.(cartridge.Saver); ok {
+		return c.SaveChanged()
+	}
+	return false
+}
+
+// RumbleIntensity returns the duty cycle of the cartridge rumble motor over the last frame
+// (0 if the cartridge has no motor)
+func (gb *GameBoy) RumbleIntensity() float64 {
+	if gb.Memory == nil {
+		return 0
+	}
+	if c, ok := gb.Memory.Cartridge.(cartridge.Rumbler); ok {
+		return c.RumbleIntensity()
+	}
+	return 0
+}
+
 func (gb *GameBoy) initComponents(rom cartridge.Cartridge) {
+	gb.newComponents(rom)
+	gb.connectCartridge(rom)
+}
+
+// newComponents creates the components of the emulated model with the cartridge inserted
+func (gb *GameBoy) newComponents(rom cartridge.Cartridge) {
 	isCGB := gb.EmulationModel == CGB
 
 	gb.PPU = ppu.New(isCGB)
 	gb.Joypad = joypad.New()
 	gb.APU = audio.New(gb.sampleRate, gb.sampleBuff, isCGB)
-	gb.SerialPort = serial.NewPort()
+	gb.SerialPort = serial.NewPort(isCGB)
+	gb.Infrared = infrared.NewPort()
 	gb.Timer = timer.New(gb.APU)
 
-	gb.Memory = mmu.New(gb.PPU, gb.APU, gb.Timer, gb.Joypad, gb.SerialPort, isCGB)
+	gb.Memory = mmu.New(gb.PPU, gb.APU, gb.Timer, gb.Joypad, gb.SerialPort, gb.Infrared, isCGB)
 	gb.CPU = cpu.New(gb.Memory, gb.PPU, isCGB)
 	gb.Memory.IsCPUHalted = gb.CPU.Halted
 	gb.Timer.DIVGlitched = gb.CPU.SpeedSwitchHalted
-	gb.CPU.AddTicker(gb.SerialPort, gb.Timer, gb.PPU, gb.Memory, gb.APU)
+	gb.CPU.AddTicker(gb.SerialPort, gb.Infrared, gb.Timer, gb.PPU, gb.Memory, gb.APU)
+	gb.ticks = 0
+	gb.CPU.AddTicker(&gb.ticks)
 
 	// Load ROM into memory
 	gb.Memory.Cartridge = rom
@@ -82,6 +205,57 @@ func (gb *GameBoy) initComponents(rom cartridge.Cartridge) {
 	gb.Timer.RequestInterrupt = func() { gb.CPU.RequestInterrupt(cpu.TimerInterruptMask) }
 	gb.SerialPort.RequestInterrupt = func() { gb.CPU.RequestInterrupt(cpu.SerialInterruptMask) }
 	gb.Joypad.RequestInterrupt = func() { gb.CPU.RequestInterrupt(cpu.JoypadInterruptMask) }
+
+	// Super Game Boy packets are sent through the joypad register and the colorized screen is built
+	// from the frames of the PPU
+	gb.SGB = nil
+	if gb.EmulationModel == SGB {
+		gb.SGB = sgb.New(rom.Header().SGBFunctions())
+		gb.Joypad.SetPacketReceiver(gb.SGB)
+		gb.PPU.FrameCompleted = func() { gb.SGB.FrameCompleted(gb.PPU.GetFrame()) }
+	}
+
+	gb.CPU.IllegalOpcodeCallback = func(addr uint16, opcode uint8) {
+		if gb.IllegalOpcodeCallback != nil {
+			gb.IllegalOpcodeCallback(addr, opcode)
+		}
+	}
+
+	// Devices plugged into the serial and IR ports
+	gb.SerialPort.SetDevice(gb.serialDevice)
+	gb.Infrared.SetDevice(gb.infraredDevice)
+
+	// Cartridge clocking (MBC3 and HuC3 RTC, camera capture)
+	if c, ok := rom.(cpu.Ticker); ok {
+		gb.CPU.AddTicker(c)
+	}
+}
+
+// connectCartridge connects the cartridge peripherals to the providers and to the components
+func (gb *GameBoy) connectCartridge(rom cartridge.Cartridge) {
+	// MBC7 accelerometer
+	if c, ok := rom.(*cartridge.MBC7); ok {
+		c.SetTiltProvider(gb.tiltProvider)
+	}
+
+	// Rumble motor
+	if c, ok := rom.(cartridge.Rumbler); ok {
+		c.SetRumbleCallback(func(on bool) {
+			if gb.RumbleCallback != nil {
+				gb.RumbleCallback(on)
+			}
+		})
+	}
+
+	// Game Boy Camera sensor
+	if c, ok := rom.(*cartridge.Camera); ok {
+		c.SetImageSource(gb.imageSource)
+	}
+
+	// Infrared light, the cartridge LED shines through the device of the IR port
+	if c, ok := rom.(cartridge.InfraredCartridge); ok {
+		c.SetInfrared(gb.Infrared.Cartridge())
+	}
 }
 
 func (gb *GameBoy) Reset() {
@@ -101,8 +275,8 @@ func (gb *GameBoy) Load(rom cartridge.Cartridge) {
 		} else {
 			gb.EmulationModel = CGB
 		}
-	} else if gb.Model == DMG {
-		gb.EmulationModel = DMG
+	} else if gb.Model == DMG || gb.Model == SGB {
+		gb.EmulationModel = gb.Model
 
 		if rom.Header().CgbMode == cartridge.CgbOnly {
 			log.Println("WARNING: DMG doesn't support CGB only games, running as CGB")
@@ -112,11 +286,6 @@ func (gb *GameBoy) Load(rom cartridge.Cartridge) {
 	}
 
 	gb.initComponents(rom)
-
-	// MBC3 RTC clocking
-	if c, ok := rom.(cpu.Ticker); ok {
-		gb.CPU.AddTicker(c)
-	}
 }
 
 func (gb *GameBoy) LoadBootROM(bootRom []uint8) {
@@ -131,9 +300,14 @@ func (gb *GameBoy) LoadBootROM(bootRom []uint8) {
 
 func (gb *GameBoy) skipBootROM() {
 	gb.Memory.DisableBootROM()
+	header := gb.Memory.Cartridge.Header()
 
-	if gb.EmulationModel == DMG {
-		gb.CPU.SkipDMGBoot()
+	if gb.EmulationModel != CGB {
+		if gb.EmulationModel == SGB {
+			gb.CPU.SkipSGBBoot()
+		} else {
+			gb.CPU.SkipDMGBoot(header.HeaderChecksum)
+		}
 		gb.Timer.SkipDMGBoot()
 		gb.Memory.SkipBoot()
 		gb.PPU.SkipDMGBoot()
@@ -146,4 +320,10 @@ func (gb *GameBoy) skipBootROM() {
 		gb.CPU.SkipCGBBoot(titleChecksum)
 		gb.APU.SkipBoot()
 	}
+
+	// The boot ROM never hands control to the game if the header check fails
+	if !header.BootCheckPassed(gb.EmulationModel == CGB) {
+		log.Println("[WARN] cartridge header check failed, the boot ROM locks up")
+		gb.CPU.Lock()
+	}
 }
@@ -0,0 +1,114 @@
+package infrared
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Device is what the IR port faces: it sees the LED of the Game Boy and shines on its receiver.
+// Devices with their own timing (with a Tick(ticks int) method) are clocked by the port at ClockRate.
+type Device interface {
+	// SetLED is called when the LED is switched on or off
+	SetLED(on bool)
+	// ReceivingLight reports whether the receiver currently detects light
+	ReceivingLight() bool
+}
+
+// Dark is a port facing nothing: no light is ever received
+type Dark struct{}
+
+func (Dark) SetLED(bool)          {}
+func (Dark) ReceivingLight() bool { return false }
+
+const (
+	// Ambient light flashes last 20-200 µs, on average every 2 ms
+	noiseMinFlash = ClockRate / 50000
+	noiseMaxFlash = ClockRate / 5000
+	noiseMeanGap  = ClockRate / 500
+)
+
+// Noise is a port exposed to ambient light (lamps, sunlight): short flashes are received at random
+// intervals. The flashes only depend on the seed, so runs can be reproduced.
+type Noise struct {
+	rng *rand.Rand
+
+	light bool
+	// Ticks before the light changes
+	remaining int
+}
+
+// NewNoise creates ambient noise with the flashes generated from seed
+func NewNoise(seed uint64) *Noise {
+	n := &Noise{rng: rand.New(rand.NewPCG(seed, seed))}
+	n.remaining = n.duration()
+	return n
+}
+
+func (n *Noise) SetLED(bool) {}
+
+func (n *Noise) ReceivingLight() bool {
+	return n.light
+}
+
+func (n *Noise) Tick(ticks int) {
+	n.remaining -= ticks
+	for n.remaining <= 0 {
+		n.light = !n.light
+		n.remaining += n.duration()
+	}
+}
+
+// duration returns the length of the next flash (or of the next gap if the light is on)
+func (n *Noise) duration() int {
+	if n.light {
+		return noiseMinFlash + n.rng.IntN(noiseMaxFlash-noiseMinFlash)
+	}
+	return 1 + n.rng.IntN(2*noiseMeanGap)
+}
+
+// pairEnd is one of two ports facing each other
+type pairEnd struct {
+	led   atomic.Bool
+	other *pairEnd
+}
+
+// NewPair returns two ports facing each other, for emulators running in the same process: the LED of
+// each one shines on the receiver of the other without delay. If the two Game Boys run in lockstep,
+// light pulses are received with the exact duration they are emitted.
+func NewPair() (a, b Device) {
+	endA, endB := &pairEnd{}, &pairEnd{}
+	endA.other, endB.other = endB, endA
+	return endA, endB
+}
+
+func (e *pairEnd) SetLED(on bool) {
+	e.led.Store(on)
+}
+
+func (e *pairEnd) ReceivingLight() bool {
+	return e.other.led.Load()
+}
+
+// ParseDevice parses the name of a built-in device: none (or dark) or noise[=SEED]
+func ParseDevice(s string) (Device, error) {
+	name, arg, _ := strings.Cut(s, "=")
+
+	switch name {
+	case "", "none", "dark":
+		return Dark{}, nil
+	case "noise":
+		var seed uint64
+		if arg != "" {
+			var err error
+			if seed, err = strconv.ParseUint(arg, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid noise seed %q", arg)
+			}
+		}
+		return NewNoise(seed), nil
+	default:
+		return nil, fmt.Errorf("unknown infrared device %q (none, noise[=SEED])", s)
+	}
+}
@@ -0,0 +1,249 @@
+package infrared
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync/atomic"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/internal/netlink"
+)
+
+const (
+	// Sent by both ends when the connection is established, followed by the protocol version
+	linkMagic   = "LBIR"
+	LinkVersion = 2 // Increased when the link protocol changes
+
+	// Light changes are sent as the state of the LED (0: off, 1: on) followed by the emulated time
+	// of the change (ticks since the link was created, big endian). Heartbeats have the same length.
+	msgLen       = 9
+	msgHeartbeat = 0x02
+
+	// Light changes waiting to be sent or shown
+	queueLen = 4096
+	// Darkness longer than this ends a burst of pulses
+	burstGap = ClockRate / 60
+	// Delay before showing a burst, so that the changes following the first one arrive in time
+	// even if the network is slower for a while (10 ms)
+	jitterBuffer = ClockRate / 100
+)
+
+// heartbeat tells the other end that the connection is alive while the LED does not change
+var heartbeat = []uint8{msgHeartbeat, 0, 0, 0, 0, 0, 0, 0, 0}
+
+// change is the LED switched on or off at the emulated time of the sender
+type change struct {
+	on   bool
+	time uint64
+	// The connection was lost, the light is switched off
+	lost bool
+}
+
+// Link shines the LED on the receiver of another emulator over TCP.
+//
+// The two emulators are not synchronized, so the light changes are timestamped with the emulated
+// time of the sender and replayed with the same timing: the first change of a burst of pulses is
+// shown shortly after it is received, the following ones after the same number of ticks elapsed at
+// the other end. Pulse lengths are preserved, while the network adds latency between bursts.
+//
+// The connection is established again when it is lost; in the meantime the receiver sees no light.
+type Link struct {
+	endpoint *netlink.Endpoint[queue]
+
+	// Emulated time and LED, read when a new connection is established
+	now atomic.Uint64
+	led atomic.Bool
+
+	// Changes received from the other end
+	received chan change
+	// Changes to show (used only by the emulation)
+	pending []change
+	light   bool
+	// Local time minus remote time of the current burst
+	offset     int64
+	lastRemote uint64
+	anchored   bool
+}
+
+// queue holds the changes to send to the other end
+type queue chan change
+
+type session = netlink.Session[queue]
+
+func newLink() *Link {
+	l := &Link{received: make(chan change, queueLen)}
+	l.endpoint = netlink.NewEndpoint(netlink.Protocol[queue]{
+		Name:      "[IR]",
+		Handshake: handshake,
+		Serve:     l.serve,
+	})
+	return l
+}
+
+// ListenLink waits for the other emulator on addr (host:port), accepting it again whenever
+// the connection is lost
+func ListenLink(addr string) (*Link, error) {
+	l := newLink()
+	if err := l.endpoint.Listen(addr); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// DialLink connects to the other emulator on addr (host:port), retrying until it is reachable
+// and whenever the connection is lost
+func DialLink(addr string) *Link {
+	l := newLink()
+	l.endpoint.Dial(addr)
+	return l
+}
+
+// Addr returns the address the link is listening on (nil if it dials the other emulator)
+func (l *Link) Addr() net.Addr {
+	return l.endpoint.Addr()
+}
+
+// Connected reports whether the other emulator is connected
+func (l *Link) Connected() bool {
+	return l.endpoint.Connected()
+}
+
+// Close disconnects the other emulator and stops reconnecting
+func (l *Link) Close() {
+	l.endpoint.Close()
+}
+
+func (l *Link) SetLED(on bool) {
+	l.led.Store(on)
+
+	s := l.endpoint.Session()
+	if s == nil {
+		return
+	}
+	select {
+	case s.State <- change{on: on, time: l.now.Load()}:
+	default:
+		log.Println("[IR] the other emulator is not keeping up, light change dropped")
+	}
+}
+
+func (l *Link) ReceivingLight() bool {
+	return l.light
+}
+
+// Tick shows the light changes due
+func (l *Link) Tick(ticks int) {
+	now := l.now.Add(uint64(ticks))
+
+	// Non blocking
+receive:
+	for len(l.pending) < queueLen {
+		select {
+		case c := <-l.received:
+			l.pending = append(l.pending, c)
+		default:
+			break receive
+		}
+	}
+
+	for len(l.pending) > 0 {
+		c := l.pending[0]
+		if c.lost {
+			l.light, l.anchored = false, false
+			l.pending = l.pending[1:]
+			continue
+		}
+
+		// A new burst is shown after the jitter buffer
+		if !l.anchored || (c.on && !l.light && c.time-l.lastRemote > burstGap) {
+			l.offset = int64(now) + jitterBuffer - int64(c.time)
+			l.anchored = true
+		}
+		if int64(c.time)+l.offset > int64(now) {
+			break
+		}
+
+		l.light, l.lastRemote = c.on, c.time
+		l.pending = l.pending[1:]
+	}
+}
+
+// handshake checks that the other end speaks the same protocol
+func handshake(conn net.Conn) (queue, error) {
+	if _, err := conn.Write(append([]uint8(linkMagic), LinkVersion)); err != nil {
+		return nil, err
+	}
+
+	header := make([]uint8, len(linkMagic)+1)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	if string(header[:len(linkMagic)]) != linkMagic {
+		return nil, errors.New("the other end is not an infrared link")
+	}
+	if version := header[len(linkMagic)]; version != LinkVersion {
+		return nil, fmt.Errorf("infrared protocol version %d, want %d", version, LinkVersion)
+	}
+	return make(queue, queueLen), nil
+}
+
+// serve exchanges the light changes until the connection is lost
+func (l *Link) serve(s *session) {
+	log.Printf("[IR] connected to %s", s.RemoteAddr())
+
+	// The other end starts from the current state of the LED
+	s.State <- change{on: l.led.Load(), time: l.now.Load()}
+
+	go write(s)
+	go s.Heartbeat(heartbeat)
+	l.listen(s)
+
+	select {
+	case l.received <- change{lost: true}:
+	case <-l.endpoint.Closed():
+	}
+	log.Println("[IR] disconnected")
+}
+
+// listen receives the light changes until the connection is lost
+func (l *Link) listen(s *session) {
+	msg := make([]uint8, msgLen)
+
+	for s.Receive(msg) == nil {
+		if msg[0] == msgHeartbeat {
+			continue
+		}
+
+		c := change{on: msg[0] != 0, time: binary.BigEndian.Uint64(msg[1:])}
+		select {
+		case l.received <- c:
+		case <-s.Done():
+			return
+		}
+	}
+}
+
+// write sends the light changes, the connection is closed if they cannot be written in time
+func write(s *session) {
+	msg := make([]uint8, msgLen)
+
+	for {
+		select {
+		case <-s.Done():
+			return
+		case c := <-s.State:
+			msg[0] = 0
+			if c.on {
+				msg[0] = 1
+			}
+			binary.BigEndian.PutUint64(msg[1:], c.time)
+
+			if s.Send(msg) != nil {
+				return
+			}
+		}
+	}
+}
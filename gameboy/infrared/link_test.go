@@ -0,0 +1,117 @@
+package infrared
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/internal/netlink/netlinktest"
+)
+
+func newTestLinks(t *testing.T) (*Link, *Link) {
+	return netlinktest.Pair(t, ListenLink, DialLink)
+}
+
+// Ticks run by the receiver at a time while waiting for the light
+const step = 64
+
+// waitLight runs the receiver until the light is switched on or off and returns the ticks elapsed
+func waitLight(t *testing.T, l *Link, on bool) int {
+	t.Helper()
+
+	ticks := 0
+	for deadline := time.Now().Add(5 * time.Second); l.ReceivingLight() != on; {
+		if time.Now().After(deadline) {
+			t.Fatalf("light = %v, want %v", l.ReceivingLight(), on)
+		}
+		l.Tick(step)
+		ticks += step
+		time.Sleep(time.Microsecond)
+	}
+	return ticks
+}
+
+func TestLink(t *testing.T) {
+	sender, receiver := newTestLinks(t)
+
+	// Two pulses of 500 and 2000 ticks, 1000 ticks apart
+	sender.Tick(1000)
+	for _, length := range []int{500, 2000} {
+		sender.SetLED(true)
+		sender.Tick(length)
+		sender.SetLED(false)
+		sender.Tick(1000)
+	}
+
+	if delay := waitLight(t, receiver, true); delay < jitterBuffer {
+		t.Errorf("light received after %d ticks, want at least %d", delay, jitterBuffer)
+	}
+	for i, want := range []int{500, 1000, 2000} {
+		got := waitLight(t, receiver, i%2 == 1)
+		if got < want-step || got > want+step {
+			t.Errorf("change %d after %d ticks, want %d", i, got, want)
+		}
+	}
+}
+
+func TestLinkDisconnect(t *testing.T) {
+	sender, receiver := newTestLinks(t)
+
+	sender.SetLED(true)
+	waitLight(t, receiver, true)
+
+	sender.Close()
+	netlinktest.WaitConnected(t, receiver, false)
+	waitLight(t, receiver, false)
+}
+
+func TestLinkHandshake(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]uint8("LBLINK\x01\x03dmg"))
+		time.Sleep(time.Second)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := handshake(conn); err == nil {
+		t.Error("handshake with a link cable should fail")
+	}
+}
+
+func TestLinkHalfOpen(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// The other end completes the handshake, then goes silent without closing the connection
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(append([]uint8(linkMagic), LinkVersion))
+		time.Sleep(10 * time.Second)
+	}()
+
+	l := DialLink(ln.Addr().String())
+	defer l.Close()
+	netlinktest.WaitConnected(t, l, true)
+	netlinktest.WaitConnected(t, l, false)
+}
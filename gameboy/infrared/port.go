@@ -0,0 +1,124 @@
+// Package infrared emulates the CGB infrared port and the light travelling between Game Boys
+package infrared
+
+import "github.com/danielecanzoneri/lucky-boy/util"
+
+const (
+	RPAddr = 0xFF56
+
+	// Devices are clocked at the single speed rate, so that the duration of the light pulses does not
+	// depend on the speed of the CPU
+	ClockRate = 4194304
+
+	readEnable = 0b11000000
+	rpUnused   = 0b00111100
+)
+
+// Port is the infrared communication port of the CGB (RP register):
+//
+//	bit 0:    LED (0: off, 1: on)
+//	bit 1:    signal received (0: receiving light, 1: normal), read only
+//	bits 6-7: data read enable (3: enable, otherwise bit 1 reads 1)
+//
+// Cartridges with their own IR port (HuC1, HuC3) share the device plugged into the port:
+// the light seen by the other end is on when any of the LEDs is on.
+type Port struct {
+	rp uint8
+
+	// LED of the cartridge
+	cartridgeLED bool
+
+	doubleSpeed bool
+	// Odd tick left in double speed mode
+	halfTick int
+
+	// Device that sees the LED and shines on the receiver
+	device Device
+	// Set when the device has its own timing
+	deviceTicker ticker
+}
+
+type ticker interface {
+	Tick(ticks int)
+}
+
+func NewPort() *Port {
+	return &Port{device: Dark{}}
+}
+
+// SetDevice connects the port to a device (nil for complete darkness)
+func (p *Port) SetDevice(device Device) {
+	if device == nil {
+		device = Dark{}
+	}
+	p.device = device
+	p.deviceTicker, _ = device.(ticker)
+	p.updateLED()
+}
+
+// Device returns the device connected to the port
+func (p *Port) Device() Device {
+	return p.device
+}
+
+func (p *Port) Read() uint8 {
+	value := p.rp | rpUnused | 0b10
+	if p.rp&readEnable == readEnable && p.device.ReceivingLight() {
+		util.SetBit(&value, 1, 0)
+	}
+	return value
+}
+
+func (p *Port) Write(v uint8) {
+	p.rp = v & (readEnable | 1)
+	p.updateLED()
+}
+
+// LED reports whether the LED of the port is on
+func (p *Port) LED() bool {
+	return util.ReadBit(p.rp, 0) > 0
+}
+
+func (p *Port) updateLED() {
+	p.device.SetLED(p.LED() || p.cartridgeLED)
+}
+
+func (p *Port) Tick(ticks int) {
+	if p.deviceTicker == nil {
+		return
+	}
+
+	if p.doubleSpeed {
+		ticks += p.halfTick
+		p.halfTick = ticks & 1
+		ticks >>= 1
+	}
+	if ticks > 0 {
+		p.deviceTicker.Tick(ticks)
+	}
+}
+
+// SwitchSpeed is called when the CGB switches speed
+func (p *Port) SwitchSpeed(doubleSpeed bool) {
+	p.doubleSpeed = doubleSpeed
+	p.halfTick = 0
+}
+
+// Cartridge returns the IR connection of cartridges with an IR port
+func (p *Port) Cartridge() *CartridgeIR {
+	return &CartridgeIR{port: p}
+}
+
+// CartridgeIR connects the IR port of the cartridge to the device of the Game Boy port
+type CartridgeIR struct {
+	port *Port
+}
+
+func (c *CartridgeIR) SetLED(on bool) {
+	c.port.cartridgeLED = on
+	c.port.updateLED()
+}
+
+func (c *CartridgeIR) ReceivingLight() bool {
+	return c.port.device.ReceivingLight()
+}
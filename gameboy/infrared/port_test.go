@@ -0,0 +1,143 @@
+package infrared
+
+import "testing"
+
+// recorder remembers the state of the LED and shines light when told to
+type recorder struct {
+	led, light bool
+	ticks      int
+}
+
+func (r *recorder) SetLED(on bool)       { r.led = on }
+func (r *recorder) ReceivingLight() bool { return r.light }
+func (r *recorder) Tick(ticks int)       { r.ticks += ticks }
+
+func TestRP(t *testing.T) {
+	r := &recorder{light: true}
+	p := NewPort()
+	p.SetDevice(r)
+
+	for _, tt := range []struct {
+		write, want uint8
+		led         bool
+	}{
+		{0x00, 0x3E, false}, // Reading disabled
+		{0x01, 0x3F, true},
+		{0x40, 0x7E, false}, // Only one bit of read enable
+		{0xC0, 0xFC, false}, // Light received
+		{0xFF, 0xFD, true},
+	} {
+		p.Write(tt.write)
+		if got := p.Read(); got != tt.want {
+			t.Errorf("write %02X: RP = %02X, want %02X", tt.write, got, tt.want)
+		}
+		if r.led != tt.led {
+			t.Errorf("write %02X: LED = %t", tt.write, r.led)
+		}
+	}
+
+	r.light = false
+	if got := p.Read(); got != 0xFF {
+		t.Errorf("RP without light = %02X, want FF", got)
+	}
+}
+
+func TestCartridgeLED(t *testing.T) {
+	r := &recorder{}
+	p := NewPort()
+	p.SetDevice(r)
+	cart := p.Cartridge()
+
+	cart.SetLED(true)
+	if !r.led {
+		t.Error("the cartridge LED should be seen")
+	}
+	p.Write(0x01)
+	cart.SetLED(false)
+	if !r.led {
+		t.Error("the LED of the port is still on")
+	}
+	p.Write(0x00)
+	if r.led {
+		t.Error("both LEDs are off")
+	}
+
+	r.light = true
+	if !cart.ReceivingLight() {
+		t.Error("the cartridge should receive the light")
+	}
+}
+
+func TestDoubleSpeedTicks(t *testing.T) {
+	r := &recorder{}
+	p := NewPort()
+	p.SetDevice(r)
+
+	p.Tick(4)
+	p.SwitchSpeed(true)
+	for range 5 {
+		p.Tick(3)
+	}
+	if r.ticks != 4+7 {
+		t.Errorf("device clocked for %d ticks, want 11", r.ticks)
+	}
+}
+
+func TestPair(t *testing.T) {
+	a, b := NewPair()
+
+	a.SetLED(true)
+	if !b.ReceivingLight() || a.ReceivingLight() {
+		t.Error("the light of a should only reach b")
+	}
+	a.SetLED(false)
+	b.SetLED(true)
+	if !a.ReceivingLight() || b.ReceivingLight() {
+		t.Error("the light of b should only reach a")
+	}
+}
+
+// flashes returns the start and the length of the flashes received in ticks
+func flashes(n *Noise, ticks int) [][2]int {
+	var out [][2]int
+	for i := range ticks {
+		n.Tick(1)
+		switch light := n.ReceivingLight(); {
+		case light && (len(out) == 0 || out[len(out)-1][1] != 0):
+			out = append(out, [2]int{i, 0})
+		case !light && len(out) > 0 && out[len(out)-1][1] == 0:
+			out[len(out)-1][1] = i - out[len(out)-1][0]
+		}
+	}
+	return out
+}
+
+func TestNoise(t *testing.T) {
+	got := flashes(NewNoise(1), ClockRate/10)
+	if len(got) < 10 {
+		t.Fatalf("%d flashes in 100 ms", len(got))
+	}
+	for _, f := range got[:len(got)-1] {
+		if f[1] < noiseMinFlash || f[1] > noiseMaxFlash {
+			t.Errorf("flash at %d lasts %d ticks", f[0], f[1])
+		}
+	}
+
+	again := flashes(NewNoise(1), ClockRate/10)
+	if len(again) != len(got) || again[0] != got[0] || again[len(got)-1] != got[len(got)-1] {
+		t.Error("the same seed should give the same flashes")
+	}
+}
+
+func TestParseDevice(t *testing.T) {
+	for _, name := range []string{"", "none", "dark", "noise", "noise=42"} {
+		if _, err := ParseDevice(name); err != nil {
+			t.Errorf("%q: %v", name, err)
+		}
+	}
+	for _, name := range []string{"noise=x", "sun"} {
+		if _, err := ParseDevice(name); err == nil {
+			t.Errorf("%q should be invalid", name)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package infrared
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+
+// SaveState stores the RP register, the device connected to the port is not part of the state
+func (p *Port) SaveState(e *savestate.Encoder) {
+	e.U8(p.rp)
+	e.Bool(p.doubleSpeed)
+	e.Int(p.halfTick)
+}
+
+func (p *Port) LoadState(d *savestate.Decoder) {
+	d.U8(&p.rp)
+	d.Bool(&p.doubleSpeed)
+	d.Int(&p.halfTick)
+	p.updateLED()
+}
@@ -0,0 +1,266 @@
+// Package netlink connects two emulators over TCP for the link cable, the infrared port and the
+// 4 player adapter: it establishes the connection again whenever it is lost and checks that the
+// other end is alive, while each protocol only encodes its messages.
+package netlink
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	HandshakeTimeout  = 5 * time.Second
+	HeartbeatInterval = time.Second
+	// Without messages for this long, the other end is considered gone
+	ReadTimeout  = 3 * HeartbeatInterval
+	WriteTimeout = time.Second
+	RetryDelay   = time.Second
+)
+
+// Protocol describes the messages exchanged by an Endpoint. T is the state of a session, created
+// by the handshake (e.g. the model of the other emulator and the messages received).
+type Protocol[T any] struct {
+	// Prefix of the log messages (e.g. "[LINK]")
+	Name string
+
+	// Handshake checks that the other end speaks the same protocol, it must complete within
+	// HandshakeTimeout
+	Handshake func(conn net.Conn) (T, error)
+	// Serve exchanges the messages until the session is closed
+	Serve func(s *Session[T])
+}
+
+// Endpoint is one end of a connection to another emulator, accepting or dialing it again
+// whenever the connection is lost until the endpoint is closed
+type Endpoint[T any] struct {
+	protocol Protocol[T]
+
+	// Opens the next connection (accepting or dialing)
+	connect  func() (net.Conn, error)
+	listener net.Listener
+
+	// Current connection (nil when disconnected)
+	session   atomic.Pointer[Session[T]]
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewEndpoint creates an endpoint speaking the protocol, connected with Listen or Dial
+func NewEndpoint[T any](p Protocol[T]) *Endpoint[T] {
+	return &Endpoint[T]{
+		protocol: p,
+		closed:   make(chan struct{}),
+	}
+}
+
+// Listen waits for the other emulator on addr (host:port), accepting it again whenever
+// the connection is lost
+func (e *Endpoint[T]) Listen(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	e.listener = ln
+	e.connect = ln.Accept
+	go e.run()
+	return nil
+}
+
+// Dial connects to the other emulator on addr (host:port), retrying until it is reachable
+// and whenever the connection is lost
+func (e *Endpoint[T]) Dial(addr string) {
+	e.connect = func() (net.Conn, error) {
+		return net.DialTimeout("tcp", addr, HandshakeTimeout)
+	}
+	go e.run()
+}
+
+// Addr returns the address the endpoint is listening on (nil if it dials the other emulator)
+func (e *Endpoint[T]) Addr() net.Addr {
+	if e.listener == nil {
+		return nil
+	}
+	return e.listener.Addr()
+}
+
+// Session returns the current connection (nil when disconnected)
+func (e *Endpoint[T]) Session() *Session[T] {
+	return e.session.Load()
+}
+
+// Connected reports whether the other emulator is connected
+func (e *Endpoint[T]) Connected() bool {
+	return e.session.Load() != nil
+}
+
+// Closed is closed when the endpoint is closed
+func (e *Endpoint[T]) Closed() <-chan struct{} {
+	return e.closed
+}
+
+// Close disconnects the other emulator and stops reconnecting
+func (e *Endpoint[T]) Close() {
+	e.closeOnce.Do(func() {
+		close(e.closed)
+		if e.listener != nil {
+			e.listener.Close()
+		}
+		if s := e.session.Load(); s != nil {
+			s.Close()
+		}
+	})
+}
+
+func (e *Endpoint[T]) isClosed() bool {
+	select {
+	case <-e.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// run connects to the other end until the endpoint is closed
+func (e *Endpoint[T]) run() {
+	var lastErr string
+
+	for !e.isClosed() {
+		conn, err := e.connect()
+		if err == nil {
+			var state T
+			if state, err = e.handshake(conn); err == nil {
+				lastErr = ""
+				e.serve(NewSession(e.protocol.Name, conn, state))
+				continue
+			}
+			conn.Close()
+		}
+
+		// Failures are logged once until they change
+		if e.isClosed() {
+			return
+		}
+		if err.Error() != lastErr {
+			lastErr = err.Error()
+			log.Println(e.protocol.Name, "connection failed:", err)
+		}
+
+		select {
+		case <-e.closed:
+		case <-time.After(RetryDelay):
+		}
+	}
+}
+
+func (e *Endpoint[T]) handshake(conn net.Conn) (T, error) {
+	conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	return e.protocol.Handshake(conn)
+}
+
+// serve runs the session until the connection is lost
+func (e *Endpoint[T]) serve(s *Session[T]) {
+	e.session.Store(s)
+	if e.isClosed() {
+		s.Close()
+	}
+
+	e.protocol.Serve(s)
+	s.Close()
+
+	e.session.Store(nil)
+}
+
+// Session is a connection after the handshake
+type Session[T any] struct {
+	conn net.Conn
+	name string
+
+	// State created by the handshake
+	State T
+
+	// Closed when the connection is lost
+	done      chan struct{}
+	closeOnce sync.Once
+	writeMu   sync.Mutex
+}
+
+// NewSession wraps a connection after the handshake, name is the prefix of the log messages
+func NewSession[T any](name string, conn net.Conn, state T) *Session[T] {
+	return &Session[T]{
+		conn:  conn,
+		name:  name,
+		State: state,
+		done:  make(chan struct{}),
+	}
+}
+
+// RemoteAddr returns the address of the other end
+func (s *Session[T]) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+// Done is closed when the connection is lost
+func (s *Session[T]) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *Session[T]) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.conn.Close()
+	})
+}
+
+// Receive reads the next message into msg. The connection is closed if it fails or if nothing
+// is received for ReadTimeout, the other end must send heartbeats while it has nothing to say.
+func (s *Session[T]) Receive(msg []uint8) error {
+	s.conn.SetReadDeadline(time.Now().Add(ReadTimeout))
+	_, err := io.ReadFull(s.conn, msg)
+	if err != nil {
+		if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+			log.Println(s.name, "connection error:", err)
+		}
+		s.Close()
+	}
+	return err
+}
+
+// Send writes a message, the connection is closed if it cannot be written in time
+func (s *Session[T]) Send(msg []uint8) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	_, err := s.conn.Write(msg)
+	if err != nil {
+		if !errors.Is(err, net.ErrClosed) {
+			log.Println(s.name, "connection error:", err)
+		}
+		s.Close()
+	}
+	return err
+}
+
+// Heartbeat sends msg every HeartbeatInterval until the connection is lost,
+// telling the other end that it is alive
+func (s *Session[T]) Heartbeat(msg []uint8) {
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.Send(msg)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package netlink
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/internal/netlink/netlinktest"
+)
+
+// hello exchanges a byte in the handshake (0 rejects the other end), then only heartbeats
+func hello(b uint8) Protocol[uint8] {
+	return Protocol[uint8]{
+		Name: "[TEST]",
+		Handshake: func(conn net.Conn) (uint8, error) {
+			if _, err := conn.Write([]uint8{b}); err != nil {
+				return 0, err
+			}
+			buf := make([]uint8, 1)
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				return 0, err
+			}
+			if buf[0] == 0 {
+				return 0, errors.New("rejected")
+			}
+			return buf[0], nil
+		},
+		Serve: func(s *Session[uint8]) {
+			go s.Heartbeat([]uint8{0})
+			buf := make([]uint8, 1)
+			for s.Receive(buf) == nil {
+			}
+		},
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	server := NewEndpoint(hello(1))
+	if err := server.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	addr := server.Addr().String()
+
+	client := NewEndpoint(hello(2))
+	client.Dial(addr)
+	defer client.Close()
+
+	netlinktest.WaitConnected(t, server, true)
+	netlinktest.WaitConnected(t, client, true)
+	if server.Session().State != 2 || client.Session().State != 1 {
+		t.Errorf("handshake states %d and %d, want 2 and 1", server.Session().State, client.Session().State)
+	}
+
+	// The client connects again when the server is back
+	server.Close()
+	netlinktest.WaitConnected(t, client, false)
+
+	server = NewEndpoint(hello(3))
+	if err := server.Listen(addr); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	netlinktest.WaitConnected(t, client, true)
+	if client.Session().State != 3 {
+		t.Errorf("handshake state %d after reconnecting, want 3", client.Session().State)
+	}
+}
+
+func TestEndpointHandshakeRejected(t *testing.T) {
+	server := NewEndpoint(hello(0))
+	if err := server.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	client := NewEndpoint(hello(2))
+	client.Dial(server.Addr().String())
+	defer client.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	if client.Connected() {
+		t.Error("connection accepted after a failed handshake")
+	}
+}
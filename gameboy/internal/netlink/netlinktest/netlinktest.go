@@ -0,0 +1,54 @@
+// Package netlinktest provides helpers for testing the links built on netlink
+package netlinktest
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// Timeout is the longest wait for a condition
+const Timeout = 5 * time.Second
+
+// WaitUntil polls cond until it is true, failing the test after Timeout
+func WaitUntil(t testing.TB, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(Timeout); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition still false after %v", Timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Link is one end of a link to another emulator
+type Link interface {
+	Addr() net.Addr
+	Connected() bool
+	Close()
+}
+
+// WaitConnected waits until the link is connected or disconnected
+func WaitConnected(t testing.TB, l Link, connected bool) {
+	t.Helper()
+	WaitUntil(t, func() bool { return l.Connected() == connected })
+}
+
+// Pair connects a link to another one listening on a free local port, waiting for the connection.
+// Both links are closed when the test ends.
+func Pair[L Link](t testing.TB, listen func(addr string) (L, error), dial func(addr string) L) (server, client L) {
+	t.Helper()
+
+	server, err := listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	client = dial(server.Addr().String())
+	t.Cleanup(client.Close)
+
+	WaitConnected(t, server, true)
+	WaitConnected(t, client, true)
+	return server, client
+}
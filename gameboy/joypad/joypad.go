@@ -8,6 +8,13 @@ type InputProvider interface {
 	IsKeyPressed(key Key) bool
 }
 
+// PacketReceiver receives the writes to P1 on the Super Game Boy, which uses the pulses on P14 and P15
+// to receive command packets, and provides the ID of the selected joypad in multiplayer mode
+type PacketReceiver interface {
+	WriteP1(v uint8)
+	Player() uint8
+}
+
 // Key represents a Game Boy key
 type Key uint8
 
@@ -33,6 +40,7 @@ type Joypad struct {
 
 	RequestInterrupt func()
 	inputProvider    InputProvider
+	sgb              PacketReceiver
 }
 
 func New() *Joypad {
@@ -50,9 +58,18 @@ func (jp *Joypad) Write(v uint8) {
 		jp.bLeft = 1
 		jp.aRight = 1
 	}
+
+	if jp.sgb != nil {
+		jp.sgb.WriteP1(v)
+	}
 }
 
 func (jp *Joypad) Read() uint8 {
+	// With both lines deselected the SGB returns the ID of the current joypad ($F = joypad 1)
+	if jp.sgb != nil && jp.selectButtons == 1 && jp.selectDPad == 1 {
+		return 0xF0 | (0xF - jp.sgb.Player())
+	}
+
 	return 0xC0 | (jp.selectButtons << 5) | (jp.selectDPad << 4) |
 		(jp.startDown << 3) | (jp.selectUp << 2) | (jp.bLeft << 1) | jp.aRight
 }
@@ -62,11 +79,20 @@ func (jp *Joypad) SetInputProvider(provider InputProvider) {
 	jp.inputProvider = provider
 }
 
+// SetPacketReceiver connects the joypad to the Super Game Boy
+func (jp *Joypad) SetPacketReceiver(receiver PacketReceiver) {
+	jp.sgb = receiver
+}
+
 // DetectKeysPressed detects key presses using the configured InputProvider
 func (jp *Joypad) DetectKeysPressed() {
 	if jp.inputProvider == nil {
 		return
 	}
+	// Only the first SGB joypad is connected
+	if jp.sgb != nil && jp.sgb.Player() != 0 {
+		return
+	}
 
 	if jp.selectButtons == 0 {
 		if jp.inputProvider.IsKeyPressed(KeyStart) {
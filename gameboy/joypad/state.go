@@ -0,0 +1,21 @@
+package joypad
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+
+func (jp *Joypad) SaveState(e *savestate.Encoder) {
+	e.U8(jp.selectButtons)
+	e.U8(jp.selectDPad)
+	e.U8(jp.startDown)
+	e.U8(jp.selectUp)
+	e.U8(jp.bLeft)
+	e.U8(jp.aRight)
+}
+
+func (jp *Joypad) LoadState(d *savestate.Decoder) {
+	d.U8(&jp.selectButtons)
+	d.U8(&jp.selectDPad)
+	d.U8(&jp.startDown)
+	d.U8(&jp.selectUp)
+	d.U8(&jp.bLeft)
+	d.U8(&jp.aRight)
+}
@@ -0,0 +1,72 @@
+package gameboy
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/internal/netlink/netlinktest"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/serial"
+)
+
+// linkProgram sends sb with the given SC and waits for the end of the transfer
+func linkProgram(sb, sc uint8) []uint8 {
+	return []uint8{
+		0x3E, sb, // LD A,sb
+		0xE0, 0x01, // LDH ($01),A
+		0x3E, sc, // LD A,sc
+		0xE0, 0x02, // LDH ($02),A
+		0xF0, 0x02, // LDH A,($02)
+		0x87,       // ADD A
+		0x38, 0xFB, // JR C,-5
+		0x18, 0xFE, // JR -2
+	}
+}
+
+func TestLinkCable(t *testing.T) {
+	master := newTestGameBoy(t, DMG, "LINK MASTER", linkProgram(0x99, 0x81))
+	slave := newTestGameBoy(t, CGB, "LINK SLAVE", linkProgram(0x42, 0x80))
+
+	server, client := netlinktest.Pair(t,
+		func(addr string) (*serial.Link, error) {
+			return serial.ListenLink(addr, master.EmulationModel.String())
+		},
+		func(addr string) *serial.Link { return serial.DialLink(addr, slave.EmulationModel.String()) })
+	if server.PeerModel() != "cgb" || client.PeerModel() != "dmg" {
+		t.Errorf("peer models %q and %q", server.PeerModel(), client.PeerModel())
+	}
+
+	master.SetSerialDevice(server)
+	slave.SetSerialDevice(client)
+	master.Reset()
+	slave.Reset()
+
+	// The slave waits for the external clock before the master starts: bits clocked earlier are
+	// answered with 1 and lost, like on hardware
+	slave.RunFrame()
+
+	// The cores run on their own until the transfer is complete, the master waits for the bits
+	// answered by the slave
+	var wg sync.WaitGroup
+	deadline := time.Now().Add(5 * time.Second)
+	for _, gb := range []*GameBoy{master, slave} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				gb.RunFrame()
+				if gb.SerialPort.Read(0xFF02)&0x80 == 0 {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if sb := master.SerialPort.Read(0xFF01); sb != 0x42 {
+		t.Errorf("master SB = %02X, want 42", sb)
+	}
+	if sb := slave.SerialPort.Read(0xFF01); sb != 0x99 {
+		t.Errorf("slave SB = %02X, want 99", sb)
+	}
+}
@@ -0,0 +1,110 @@
+package gameboy
+
+import (
+	"slices"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/dmg07"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/infrared"
+)
+
+// Lockstep runs linked Game Boys in the same thread. The Game Boy that is behind always runs the
+// next instruction, so their clocks never drift apart by more than an instruction and each bit
+// reaches the other Game Boys at the same emulated time it is sent. Since nothing depends on the
+// host timing, linked sessions are deterministic.
+//
+// The first two Game Boys are linked by a cable, up to four can be plugged into a DMG-07.
+type Lockstep struct {
+	GameBoys []*GameBoy
+
+	ends [2]*cableEnd
+	// Time elapsed on each Game Boy in ticks at double speed (two per tick at normal speed)
+	elapsed []uint64
+}
+
+// cableEnd is plugged into a Game Boy linked in the same process: the bits clocked by the
+// Game Boy are shifted into the port of the other Game Boy at once
+type cableEnd struct {
+	peer *GameBoy
+	// End plugged into the other Game Boy
+	other *cableEnd
+}
+
+func (c *cableEnd) ExchangeBit(out uint8) uint8 {
+	// The cable may have been unplugged from the other Game Boy
+	if c.peer.SerialPort.Device() != c.other {
+		return 1
+	}
+	return c.peer.SerialPort.ExternalClock(out)
+}
+
+func (c *cableEnd) DrivesClock() bool {
+	// The bits clocked by the other Game Boy are shifted in directly
+	return false
+}
+
+// NewLockstep runs two to four Game Boys together, linking the first two with a cable
+func NewLockstep(a, b *GameBoy, others ...*GameBoy) *Lockstep {
+	if len(others) > dmg07.Players-2 {
+		panic("at most four Game Boys can be linked")
+	}
+
+	l := &Lockstep{GameBoys: append([]*GameBoy{a, b}, others...)}
+	l.elapsed = make([]uint64, len(l.GameBoys))
+	l.ends[0] = &cableEnd{peer: b}
+	l.ends[1] = &cableEnd{peer: a, other: l.ends[0]}
+	l.ends[0].other = l.ends[1]
+
+	l.Connect()
+	return l
+}
+
+// Connect plugs the cable into the first two Game Boys
+func (l *Lockstep) Connect() {
+	for i, end := range l.ends {
+		l.GameBoys[i].SetSerialDevice(end)
+	}
+}
+
+// ConnectAdapter plugs all the Game Boys into a DMG-07, in order of player
+func (l *Lockstep) ConnectAdapter() *dmg07.Adapter {
+	adapter := dmg07.NewAdapter()
+	for i, gb := range l.GameBoys {
+		gb.SetSerialDevice(adapter.Port(i))
+	}
+	return adapter
+}
+
+// ConnectInfrared points the IR ports of the first two Game Boys at each other
+func (l *Lockstep) ConnectInfrared() {
+	a, b := infrared.NewPair()
+	l.GameBoys[0].SetInfraredDevice(a)
+	l.GameBoys[1].SetInfraredDevice(b)
+}
+
+// Step executes an instruction on the Game Boy that is behind
+func (l *Lockstep) Step() {
+	i := 0
+	for j, elapsed := range l.elapsed {
+		if elapsed < l.elapsed[i] {
+			i = j
+		}
+	}
+
+	gb := l.GameBoys[i]
+	start := gb.ticks
+	gb.Step()
+
+	elapsed := uint64(gb.ticks - start)
+	if !gb.Memory.DoubleSpeed {
+		elapsed *= 2
+	}
+	l.elapsed[i] += elapsed
+}
+
+// RunCycles runs all the Game Boys for at least n ticks at normal speed
+func (l *Lockstep) RunCycles(n int) {
+	end := slices.Min(l.elapsed) + 2*uint64(n)
+	for slices.Min(l.elapsed) < end {
+		l.Step()
+	}
+}
@@ -0,0 +1,161 @@
+package gameboy
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLockstepTransfer(t *testing.T) {
+	for _, model := range []SystemModel{DMG, CGB} {
+		master := newTestGameBoy(t, DMG, "LINK MASTER", linkProgram(0x99, 0x81))
+		slave := newTestGameBoy(t, model, "LINK SLAVE", linkProgram(0x42, 0x80))
+		l := NewLockstep(master, slave)
+
+		// 8 bits at 8192 Hz
+		l.RunCycles(9 * 512)
+
+		if sb := master.SerialPort.Read(0xFF01); sb != 0x42 {
+			t.Errorf("model %d: master SB = %02X, want 42", model, sb)
+		}
+		if sb := slave.SerialPort.Read(0xFF01); sb != 0x99 {
+			t.Errorf("model %d: slave SB = %02X, want 99", model, sb)
+		}
+		if master.SerialPort.Read(0xFF02)&0x80 != 0 || slave.SerialPort.Read(0xFF02)&0x80 != 0 {
+			t.Errorf("model %d: transfer not complete", model)
+		}
+	}
+}
+
+func TestLockstepUnplugged(t *testing.T) {
+	master := newTestGameBoy(t, DMG, "LINK MASTER", linkProgram(0x99, 0x81))
+	slave := newTestGameBoy(t, DMG, "LINK SLAVE", linkProgram(0x42, 0x80))
+	l := NewLockstep(master, slave)
+	slave.SetSerialDevice(nil)
+
+	l.RunCycles(9 * 512)
+
+	if sb := master.SerialPort.Read(0xFF01); sb != 0xFF {
+		t.Errorf("master SB = %02X, want FF", sb)
+	}
+	if sb := slave.SerialPort.Read(0xFF01); sb != 0x42 {
+		t.Errorf("slave SB = %02X, want 42", sb)
+	}
+}
+
+func TestLockstepDeterministic(t *testing.T) {
+	masterProgram := []uint8{
+		0x04,       // INC B
+		0x78,       // LD A,B
+		0xE0, 0x01, // LDH ($01),A
+		0x3E, 0x81, // LD A,$81
+		0xE0, 0x02, // LDH ($02),A
+		0xF0, 0x02, // LDH A,($02) ; wait for the end of the transfer
+		0x87,       // ADD A
+		0x38, 0xFB, // JR C,-5
+		0x18, 0xF1, // JR -15
+	}
+	slaveProgram := []uint8{
+		0x3E, 0x80, // LD A,$80
+		0xE0, 0x02, // LDH ($02),A
+		0xF0, 0x02, // LDH A,($02) ; wait for the end of the transfer
+		0x87,       // ADD A
+		0x38, 0xFB, // JR C,-5
+		0xF0, 0x01, // LDH A,($01) ; answer the next transfer with the byte received + 1
+		0x3C,       // INC A
+		0xE0, 0x01, // LDH ($01),A
+		0x18, 0xF0, // JR -16
+	}
+
+	var states [2][2][]uint8
+	for i := range states {
+		master := newTestGameBoy(t, CGB, "LINK MASTER", masterProgram)
+		slave := newTestGameBoy(t, DMG, "LINK SLAVE", slaveProgram)
+		l := NewLockstep(master, slave)
+		l.RunCycles(3 * FrameTicks)
+
+		// A transfer lasts 4096 ticks
+		if master.CPU.B < 10 {
+			t.Fatalf("only %d transfers", master.CPU.B)
+		}
+
+		states[i] = [2][]uint8{saveState(t, master), saveState(t, slave)}
+	}
+
+	for i := range 2 {
+		if !bytes.Equal(states[0][i], states[1][i]) {
+			t.Errorf("Game Boy %d: the sessions differ", i+1)
+		}
+	}
+}
+
+func TestLockstepInfrared(t *testing.T) {
+	sender := newTestGameBoy(t, CGB, "IR SENDER", []uint8{
+		0x3E, 0x01, // LD A,$01
+		0xE0, 0x56, // LDH ($56),A ; LED on
+		0x18, 0xFE, // JR -2
+	})
+	receiver := newTestGameBoy(t, CGB, "IR RECEIVER", []uint8{
+		0x3E, 0xC0, // LD A,$C0
+		0xE0, 0x56, // LDH ($56),A ; read enable
+		0xF0, 0x56, // LDH A,($56)
+		0x47,       // LD B,A
+		0x18, 0xFB, // JR -5
+	})
+	l := NewLockstep(sender, receiver)
+
+	l.RunCycles(1000)
+	if b := receiver.CPU.B; b != 0xFE {
+		t.Errorf("RP without IR = %02X, want FE", b)
+	}
+
+	l.ConnectInfrared()
+	l.RunCycles(1000)
+	if b := receiver.CPU.B; b != 0xFC {
+		t.Errorf("RP receiving light = %02X, want FC", b)
+	}
+	if sender.Infrared.Read()&0x02 == 0 {
+		t.Error("the sender should not see its own LED")
+	}
+}
+
+func TestLockstepAdapter(t *testing.T) {
+	// Answer $88 to every byte like a game in the ping phase, storing the bytes received from $C000
+	program := []uint8{
+		0x21, 0x00, 0xC0, // LD HL,$C000
+		0x3E, 0x88, // LD A,$88
+		0xE0, 0x01, // LDH ($01),A
+		0x3E, 0x80, // LD A,$80
+		0xE0, 0x02, // LDH ($02),A ; wait for the external clock
+		0xF0, 0x02, // LDH A,($02) ; wait for the end of the transfer
+		0x87,       // ADD A
+		0x38, 0xFB, // JR C,-5
+		0xF0, 0x01, // LDH A,($01)
+		0x22,       // LD (HL+),A
+		0x18, 0xEE, // JR -18
+	}
+
+	var gbs []*GameBoy
+	for range 4 {
+		gbs = append(gbs, newTestGameBoy(t, DMG, "DMG-07", program))
+	}
+	l := NewLockstep(gbs[0], gbs[1], gbs[2:]...)
+	adapter := l.ConnectAdapter()
+
+	// 3 ping packets, $88 as RATE makes the bytes 40960 ticks apart
+	l.RunCycles(10 * 40960)
+
+	if connected := adapter.Hub().Connected(); connected != [4]bool{true, true, true, true} {
+		t.Errorf("connected = %v", connected)
+	}
+	for i, gb := range gbs {
+		received := make([]uint8, 12)
+		for j := range received {
+			received[j] = gb.Memory.Read(0xC000 + uint16(j))
+		}
+
+		stat := 0xF0 | uint8(i+1)
+		if want := []uint8{0xFE, stat, stat, stat}; !bytes.Contains(received, want) {
+			t.Errorf("player %d received % X, want % X", i+1, received, want)
+		}
+	}
+}
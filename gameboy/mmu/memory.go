@@ -3,6 +3,7 @@ package mmu
 import (
 	"github.com/danielecanzoneri/lucky-boy/gameboy/audio"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/cartridge"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/infrared"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/joypad"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/ppu"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/serial"
@@ -21,6 +22,7 @@ type MMU struct {
 	timer  *timer.Timer
 	joypad *joypad.Joypad
 	serial *serial.Port
+	ir     *infrared.Port
 
 	// wRAM bank register
 	vbk uint8
@@ -61,13 +63,14 @@ type MMU struct {
 	cgb bool
 }
 
-func New(ppu *ppu.PPU, apu *audio.APU, timer *timer.Timer, jp *joypad.Joypad, serialPort *serial.Port, cgb bool) *MMU {
+func New(ppu *ppu.PPU, apu *audio.APU, timer *timer.Timer, jp *joypad.Joypad, serialPort *serial.Port, ir *infrared.Port, cgb bool) *MMU {
 	return &MMU{
 		ppu:         ppu,
 		apu:         apu,
 		timer:       timer,
 		joypad:      jp,
 		serial:      serialPort,
+		ir:          ir,
 		cgb:         cgb,
 		speedFactor: 0,
 	}
@@ -0,0 +1,80 @@
+package mmu
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+
+// SaveState stores memory and DMA state, the cartridge is saved separately
+func (mmu *MMU) SaveState(e *savestate.Encoder) {
+	e.Bytes(mmu.wRAM[:])
+	e.Bytes(mmu.hRAM[:])
+	e.U8(mmu.vbk)
+
+	e.U8(mmu.dmaReg)
+	e.U8(mmu.ifReg)
+	e.U8(mmu.ieReg)
+
+	// DMA
+	e.Int(mmu.delayDmaTicks)
+	e.Int(mmu.dmaTicks)
+	e.Bool(mmu.dmaTransfer)
+	e.U16(mmu.dmaOffset)
+	e.U8(mmu.dmaValue)
+
+	// vRAM DMA
+	e.Bool(mmu.vDMAActive)
+	e.Int(mmu.vDMATicks)
+	e.Bool(mmu.vDMAHBlank)
+	e.U16(mmu.vDMASrcAddress)
+	e.U16(mmu.vDMADestAddress)
+	e.U8(mmu.vDMALength)
+
+	e.Bool(mmu.PrepareSpeedSwitch)
+	e.Bool(mmu.DoubleSpeed)
+	e.Int(mmu.speedFactor)
+
+	e.Bool(mmu.BootRomDisabled)
+	e.Bytes(mmu.BootRom)
+}
+
+func (mmu *MMU) LoadState(d *savestate.Decoder) {
+	d.BytesInto(mmu.wRAM[:])
+	d.BytesInto(mmu.hRAM[:])
+	d.U8(&mmu.vbk)
+
+	d.U8(&mmu.dmaReg)
+	d.U8(&mmu.ifReg)
+	d.U8(&mmu.ieReg)
+
+	// DMA
+	d.Int(&mmu.delayDmaTicks)
+	d.Int(&mmu.dmaTicks)
+	d.Bool(&mmu.dmaTransfer)
+	d.U16(&mmu.dmaOffset)
+	d.U8(&mmu.dmaValue)
+
+	// vRAM DMA
+	d.Bool(&mmu.vDMAActive)
+	d.Int(&mmu.vDMATicks)
+	d.Bool(&mmu.vDMAHBlank)
+	d.U16(&mmu.vDMASrcAddress)
+	d.U16(&mmu.vDMADestAddress)
+	d.U8(&mmu.vDMALength)
+
+	d.Bool(&mmu.PrepareSpeedSwitch)
+	d.Bool(&mmu.DoubleSpeed)
+	d.Int(&mmu.speedFactor)
+
+	d.Bool(&mmu.BootRomDisabled)
+	mmu.BootRom = d.Bytes()
+	if len(mmu.BootRom) == 0 {
+		mmu.BootRom = nil
+	}
+
+	// HBlank vRAM DMA is resumed by the PPU callback
+	if mmu.vDMAHBlank {
+		mmu.ppu.HBlankCallback = func() {
+			mmu.vDMAActive = true
+		}
+	} else {
+		mmu.ppu.HBlankCallback = nil
+	}
+}
@@ -0,0 +1,72 @@
+// Package palette converts the colors produced by the PPU and the Super Game Boy to RGB colors
+package palette
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/ppu"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/sgb"
+)
+
+type Palette interface {
+	Get(uint16) color.Color
+}
+
+var dmgPalette = [4]color.Color{
+	color.RGBA{R: 198, G: 222, B: 140, A: 255},
+	color.RGBA{R: 132, G: 165, B: 99, A: 255},
+	color.RGBA{R: 57, G: 97, B: 57, A: 255},
+	color.RGBA{R: 8, G: 24, B: 16, A: 255},
+}
+
+type DMG struct{}
+
+func (p DMG) Get(c uint16) color.Color {
+	return dmgPalette[c]
+}
+
+type CGBColor struct {
+	// 5 bit
+	r, g, b uint8
+}
+
+func (c CGBColor) RGBA() (r, g, b, a uint32) {
+	r = uint32(float32(c.r) / 0x1f * 0xffff)
+	g = uint32(float32(c.g) / 0x1f * 0xffff)
+	b = uint32(float32(c.b) / 0x1f * 0xffff)
+	a = 0xffff
+	return
+}
+
+type CGB struct{}
+
+func (p CGB) Get(c uint16) color.Color {
+	return CGBColor{
+		r: uint8(c & 0x1F),
+		g: uint8((c >> 5) & 0x1F),
+		b: uint8((c >> 10) & 0x1F),
+	}
+}
+
+// Frame converts a frame produced by the PPU to an image
+func Frame(frame *[ppu.FrameHeight][ppu.FrameWidth]uint16, p Palette) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, ppu.FrameWidth, ppu.FrameHeight))
+	for y := range ppu.FrameHeight {
+		for x := range ppu.FrameWidth {
+			img.Set(x, y, p.Get(frame[y][x]))
+		}
+	}
+	return img
+}
+
+// SGBFrame converts a Super Game Boy frame (RGB555 colors) to an image
+func SGBFrame(frame *sgb.Frame) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, sgb.ScreenWidth, sgb.ScreenHeight))
+	for y := range sgb.ScreenHeight {
+		for x := range sgb.ScreenWidth {
+			img.Set(x, y, CGB{}.Get(frame[y][x]))
+		}
+	}
+	return img
+}
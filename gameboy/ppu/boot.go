@@ -37,8 +37,8 @@ func (ppu *PPU) SkipDMGBoot() {
 	ppu.vRAM.tileData[0][23].raw = [16]uint8{0xCF, 0, 0xCF, 0, 0xCF, 0, 0xCF, 0, 0xCF, 0, 0xCF, 0, 0xC3, 0, 0xC3, 0}
 	ppu.vRAM.tileData[0][24].raw = [16]uint8{0x0F, 0, 0x0F, 0, 0x0F, 0, 0x0F, 0, 0x0F, 0, 0x0F, 0, 0xFC, 0, 0xFC, 0}
 	ppu.vRAM.tileData[0][25].raw = [16]uint8{0x3C, 0, 0x42, 0, 0xB9, 0, 0xA5, 0, 0xB9, 0, 0xA5, 0, 0x42, 0, 0x3C, 0}
-	for _, t := range ppu.vRAM.tileData[0] {
-		t.updatePixels()
+	for i := range ppu.vRAM.tileData[0] {
+		ppu.vRAM.tileData[0][i].updatePixels()
 	}
 
 	for i := range 13 { // 260: 1 ... 271: 12
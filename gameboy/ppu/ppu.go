@@ -68,8 +68,13 @@ type PPU struct {
 	// Callback to be called on VBlank and HBlank
 	VBlankCallback func()
 	HBlankCallback func()
+	// Called when a frame is complete, after swapping the buffers (used by the Super Game Boy)
+	FrameCompleted func()
 
 	modeTicksElapsed uint
+
+	// Frames completed (used by frontends to detect new frames)
+	frameCount uint
 }
 
 func New(cgb bool) *PPU {
@@ -33,6 +33,10 @@ func (st *vBlank) Init(ppu *PPU) {
 
 		// Frame complete, switch buffers
 		ppu.swapBuffers()
+		ppu.frameCount++
+		if ppu.FrameCompleted != nil {
+			ppu.FrameCompleted()
+		}
 
 		if ppu.VBlankCallback != nil {
 			ppu.VBlankCallback()
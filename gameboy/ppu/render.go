@@ -52,6 +52,16 @@ func (ppu *PPU) GetFrame() *[FrameHeight][FrameWidth]uint16 {
 	return ppu.frontBuffer
 }
 
+// FrameCount returns the number of frames completed (VBlank reached with the LCD on)
+func (ppu *PPU) FrameCount() uint {
+	return ppu.frameCount
+}
+
+// LCDEnabled reports if the LCD is on (LCDC bit 7)
+func (ppu *PPU) LCDEnabled() bool {
+	return ppu.active
+}
+
 func (ppu *PPU) swapBuffers() {
 	ppu.frontBuffer = ppu.backBuffer
 	ppu.backBuffer = new([FrameHeight][FrameWidth]uint16)
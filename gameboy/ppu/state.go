@@ -0,0 +1,248 @@
+package ppu
+
+import (
+	"fmt"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+)
+
+// Tags used to store the internal state machine
+const (
+	stateNone uint8 = iota
+	stateHBlank
+	stateVBlankStart
+	stateVBlank
+	stateGlitchedOamScan
+	stateOamScan
+	stateOamScanToDrawing
+	stateDrawing
+)
+
+func (ppu *PPU) SaveState(e *savestate.Encoder) {
+	e.Int(ppu.dots)
+
+	// Internal state machine
+	switch st := ppu.internalState.(type) {
+	case nil:
+		e.U8(stateNone)
+	case *hBlank:
+		e.U8(stateHBlank)
+		e.Int(st.length)
+	case *vBlankStart:
+		e.U8(stateVBlankStart)
+	case *vBlank:
+		e.U8(stateVBlank)
+	case *glitchedOamScan:
+		e.U8(stateGlitchedOamScan)
+	case *oamScan:
+		e.U8(stateOamScan)
+		e.U8(st.rowAccessed)
+	case *oamScanToDrawing:
+		e.U8(stateOamScanToDrawing)
+	case *drawing:
+		e.U8(stateDrawing)
+		e.Int(st.penaltyDots)
+	default:
+		panic(fmt.Sprintf("PPU: cannot save state %T", st))
+	}
+	e.Int(ppu.internalStateLength)
+	e.U8(ppu.interruptMode)
+
+	// vRAM
+	e.U8(ppu.vRAM.bankNumber)
+	for bank := range ppu.vRAM.tileData {
+		for _, t := range ppu.vRAM.tileData[bank] {
+			e.Bytes(t.raw[:])
+		}
+		e.Bytes(ppu.vRAM.tileMaps[bank][:])
+	}
+	e.Bool(ppu.vRAM.readDisabled)
+	e.Bool(ppu.vRAM.writeDisabled)
+
+	// OAM
+	for _, obj := range ppu.oam.Data {
+		e.U8(obj.y)
+		e.U8(obj.x)
+		e.U8(obj.tileIndex)
+		e.U8(obj.flags)
+	}
+	e.Bool(ppu.oam.readDisabled)
+	e.Bool(ppu.oam.writeDisabled)
+	e.Bool(ppu.oam.buggedRead)
+	e.Bool(ppu.oam.buggedWrite)
+	e.U8(ppu.oam.buggedRow)
+
+	// Objects selected during OAM scan
+	e.Int(ppu.numObjs)
+	for _, obj := range ppu.objsLY[:ppu.numObjs] {
+		e.U8(obj.y)
+		e.U8(obj.x)
+		e.U8(obj.tileIndex)
+		e.U8(obj.flags)
+	}
+
+	// Frame buffers
+	for y := range FrameHeight {
+		e.U16s(ppu.frontBuffer[y][:])
+	}
+	for y := range FrameHeight {
+		e.U16s(ppu.backBuffer[y][:])
+	}
+
+	// Registers
+	e.U8(ppu.LCDC)
+	e.U8(ppu.STAT)
+	e.U8(ppu.SCY)
+	e.U8(ppu.SCX)
+	e.U8(ppu.LY)
+	e.U8(ppu.LYC)
+	e.U8(uint8(ppu.BGP))
+	e.U8(uint8(ppu.OBP[0]))
+	e.U8(uint8(ppu.OBP[1]))
+	e.U8(ppu.WY)
+	e.U8(ppu.WX)
+
+	e.Bool(ppu.DmgCompatibility)
+	e.U8(ppu.BGPI)
+	e.U8(ppu.OBPI)
+	e.Bytes(ppu.BGPalette[:])
+	e.Bytes(ppu.OBJPalette[:])
+
+	e.U8(ppu.wyCounter)
+
+	// LCD control
+	e.Bool(ppu.active)
+	e.U16(ppu.windowTileMapAddr)
+	e.Bool(ppu.windowEnabled)
+	e.U8(ppu.bgWindowTileDataArea)
+	e.U16(ppu.bgTileMapAddr)
+	e.Bool(ppu.obj8x16Size)
+	e.Bool(ppu.objEnabled)
+	e.Bool(ppu.bgWindowEnabled)
+
+	e.Bool(ppu.STATInterruptLine)
+	e.Uint(ppu.modeTicksElapsed)
+}
+
+func (ppu *PPU) LoadState(d *savestate.Decoder) {
+	d.Int(&ppu.dots)
+
+	// Internal state machine
+	var tag uint8
+	d.U8(&tag)
+	switch tag {
+	case stateNone:
+		ppu.internalState = nil
+	case stateHBlank:
+		st := new(hBlank)
+		d.Int(&st.length)
+		ppu.internalState = st
+	case stateVBlankStart:
+		ppu.internalState = new(vBlankStart)
+	case stateVBlank:
+		ppu.internalState = new(vBlank)
+	case stateGlitchedOamScan:
+		ppu.internalState = new(glitchedOamScan)
+	case stateOamScan:
+		st := new(oamScan)
+		d.U8(&st.rowAccessed)
+		ppu.internalState = st
+	case stateOamScanToDrawing:
+		ppu.internalState = new(oamScanToDrawing)
+	case stateDrawing:
+		st := new(drawing)
+		d.Int(&st.penaltyDots)
+		ppu.internalState = st
+	default:
+		d.Fail(fmt.Errorf("PPU: unknown internal state %d", tag))
+	}
+	d.Int(&ppu.internalStateLength)
+	d.U8(&ppu.interruptMode)
+
+	// vRAM
+	d.U8(&ppu.vRAM.bankNumber)
+	for bank := range ppu.vRAM.tileData {
+		for i := range ppu.vRAM.tileData[bank] {
+			t := &ppu.vRAM.tileData[bank][i]
+			d.BytesInto(t.raw[:])
+			t.updatePixels()
+		}
+		d.BytesInto(ppu.vRAM.tileMaps[bank][:])
+	}
+	d.Bool(&ppu.vRAM.readDisabled)
+	d.Bool(&ppu.vRAM.writeDisabled)
+
+	// OAM
+	for i := range ppu.oam.Data {
+		obj := &ppu.oam.Data[i]
+		d.U8(&obj.y)
+		d.U8(&obj.x)
+		d.U8(&obj.tileIndex)
+		d.U8(&obj.flags)
+	}
+	d.Bool(&ppu.oam.readDisabled)
+	d.Bool(&ppu.oam.writeDisabled)
+	d.Bool(&ppu.oam.buggedRead)
+	d.Bool(&ppu.oam.buggedWrite)
+	d.U8(&ppu.oam.buggedRow)
+
+	// Objects selected during OAM scan (they are copies of OAM entries)
+	d.Int(&ppu.numObjs)
+	if ppu.numObjs < 0 || ppu.numObjs > objsLimit {
+		d.Fail(fmt.Errorf("PPU: invalid number of objects %d", ppu.numObjs))
+		ppu.numObjs = 0
+	}
+	for i := range ppu.numObjs {
+		obj := new(Object)
+		d.U8(&obj.y)
+		d.U8(&obj.x)
+		d.U8(&obj.tileIndex)
+		d.U8(&obj.flags)
+		ppu.objsLY[i] = obj
+	}
+
+	// Frame buffers
+	for y := range FrameHeight {
+		d.U16s(ppu.frontBuffer[y][:])
+	}
+	for y := range FrameHeight {
+		d.U16s(ppu.backBuffer[y][:])
+	}
+
+	// Registers
+	var bgp, obp0, obp1 uint8
+	d.U8(&ppu.LCDC)
+	d.U8(&ppu.STAT)
+	d.U8(&ppu.SCY)
+	d.U8(&ppu.SCX)
+	d.U8(&ppu.LY)
+	d.U8(&ppu.LYC)
+	d.U8(&bgp)
+	d.U8(&obp0)
+	d.U8(&obp1)
+	d.U8(&ppu.WY)
+	d.U8(&ppu.WX)
+	ppu.BGP = DMGPalette(bgp)
+	ppu.OBP = [2]DMGPalette{DMGPalette(obp0), DMGPalette(obp1)}
+
+	d.Bool(&ppu.DmgCompatibility)
+	d.U8(&ppu.BGPI)
+	d.U8(&ppu.OBPI)
+	d.BytesInto(ppu.BGPalette[:])
+	d.BytesInto(ppu.OBJPalette[:])
+
+	d.U8(&ppu.wyCounter)
+
+	// LCD control
+	d.Bool(&ppu.active)
+	d.U16(&ppu.windowTileMapAddr)
+	d.Bool(&ppu.windowEnabled)
+	d.U8(&ppu.bgWindowTileDataArea)
+	d.U16(&ppu.bgTileMapAddr)
+	d.Bool(&ppu.obj8x16Size)
+	d.Bool(&ppu.objEnabled)
+	d.Bool(&ppu.bgWindowEnabled)
+
+	d.Bool(&ppu.STATInterruptLine)
+	d.Uint(&ppu.modeTicksElapsed)
+}
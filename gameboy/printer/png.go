@@ -0,0 +1,34 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// savePNG writes the image to the first free file print-NNN.png in dir
+func savePNG(dir string, img image.Image) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	for n := 1; ; n++ {
+		path := filepath.Join(dir, fmt.Sprintf("print-%03d.png", n))
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		err = png.Encode(f, img)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return path, err
+	}
+}
@@ -0,0 +1,373 @@
+// Package printer emulates the Game Boy Printer connected to the serial port
+package printer
+
+import (
+	"image"
+	"log"
+	"sync"
+)
+
+const (
+	Width = 160 // Printed pixels per line
+
+	magic1   = 0x88
+	magic2   = 0x33
+	deviceID = 0x81 // Sent back after the checksum
+
+	// Commands
+	cmdInit   = 0x01
+	cmdPrint  = 0x02
+	cmdData   = 0x04
+	cmdStatus = 0x0F
+
+	// Image data is sent as 2bpp tiles, 20 per row
+	tilesPerRow = Width / 8
+	tileRowLen  = tilesPerRow * 16
+	bufferLen   = 9 * 2 * tileRowLen // 160x144 pixels
+
+	// Pixel rows fed for each line feed of the margins
+	feedRows = 8
+	// Time taken to print a row of pixels (in ticks)
+	ticksPerRow = (1 << 22) / 64
+)
+
+// Status bits
+const (
+	statusChecksumError = 1 << 0
+	statusPrinting      = 1 << 1
+	statusFull          = 1 << 2 // Image data received
+	statusUnprocessed   = 1 << 3
+	statusPacketError   = 1 << 4
+)
+
+// Packet bytes
+const (
+	stateMagic1 = iota
+	stateMagic2
+	stateCommand
+	stateCompression
+	stateLengthLow
+	stateLengthHigh
+	stateData
+	stateChecksumLow
+	stateChecksumHigh
+	stateDeviceID
+	stateStatus
+)
+
+// Printer is the Game Boy Printer. The Game Boy sends packets made of:
+//
+//	offset  size    desc
+//	0       2       magic $88 $33
+//	2       1       command
+//	3       1       compression (bit 0)
+//	4       2       data length (little endian)
+//	6       n       data
+//	6+n     2       checksum: sum of command, compression, length and data bytes (little endian)
+//	8+n     1       the printer answers $81
+//	9+n     1       the printer answers the status
+//
+// Commands are INIT (clear the image data), DATA (up to 640 bytes of image data, an empty packet
+// ends the image), PRINT (sheets, margins, palette and exposure) and STATUS. Compressed data is
+// run-length encoded. The exposure is not emulated.
+//
+// Printed images are added to a strip of paper, which is complete when a PRINT command
+// requests a margin after the image. Completed strips are written to PNG files.
+type Printer struct {
+	// Packet reception
+	state       int
+	command     uint8
+	compression uint8
+	length      int
+	packet      []uint8
+	sum         uint16 // Checksum computed
+	checksum    uint16 // Checksum received
+
+	status uint8
+	buffer []uint8 // Image data waiting to be printed
+
+	// Remaining ticks to complete printing
+	printTicks int
+
+	// Printed paper, guarded by mu since it is read by the frontend
+	mu      sync.Mutex
+	strip   []uint8 // Gray pixels of the strip being printed
+	last    []uint8 // Last strip completed
+	version uint
+
+	// Directory where the strips are saved (not saved if empty)
+	dir string
+}
+
+// New creates a printer saving the completed strips as PNG files in dir
+func New(dir string) *Printer {
+	return &Printer{dir: dir}
+}
+
+// Reply returns the byte sent back while the next byte of the packet is received
+func (p *Printer) Reply() uint8 {
+	switch p.state {
+	case stateDeviceID:
+		return deviceID
+	case stateStatus:
+		return p.status
+	default:
+		return 0x00
+	}
+}
+
+// Receive receives a byte of a packet
+func (p *Printer) Receive(b uint8) {
+	switch p.state {
+	case stateMagic1:
+		if b == magic1 {
+			p.state = stateMagic2
+		}
+
+	case stateMagic2:
+		switch b {
+		case magic2:
+			p.state = stateCommand
+		case magic1:
+		default:
+			p.state = stateMagic1
+		}
+
+	case stateCommand:
+		p.command = b
+		p.sum = uint16(b)
+		p.state = stateCompression
+
+	case stateCompression:
+		p.compression = b
+		p.sum += uint16(b)
+		p.state = stateLengthLow
+
+	case stateLengthLow:
+		p.length = int(b)
+		p.sum += uint16(b)
+		p.state = stateLengthHigh
+
+	case stateLengthHigh:
+		p.length |= int(b) << 8
+		p.sum += uint16(b)
+		p.packet = p.packet[:0]
+
+		switch {
+		case p.length > bufferLen:
+			p.status |= statusPacketError
+			p.state = stateMagic1
+		case p.length == 0:
+			p.state = stateChecksumLow
+		default:
+			p.state = stateData
+		}
+
+	case stateData:
+		p.packet = append(p.packet, b)
+		p.sum += uint16(b)
+		if len(p.packet) == p.length {
+			p.state = stateChecksumLow
+		}
+
+	case stateChecksumLow:
+		p.checksum = uint16(b)
+		p.state = stateChecksumHigh
+
+	case stateChecksumHigh:
+		p.checksum |= uint16(b) << 8
+		p.state = stateDeviceID
+
+		if p.checksum != p.sum {
+			p.status |= statusChecksumError
+		} else {
+			p.status &^= statusChecksumError
+			p.execute()
+		}
+
+	case stateDeviceID:
+		p.state = stateStatus
+
+	case stateStatus:
+		p.state = stateMagic1
+	}
+}
+
+// Tick advances the printing
+func (p *Printer) Tick(ticks int) {
+	if p.printTicks <= 0 {
+		return
+	}
+
+	p.printTicks -= ticks
+	if p.printTicks <= 0 {
+		p.status &^= statusPrinting
+	}
+}
+
+func (p *Printer) execute() {
+	switch p.command {
+	case cmdInit:
+		p.buffer = p.buffer[:0]
+		p.status = 0
+		p.printTicks = 0
+
+	case cmdData:
+		data := p.packet
+		if p.compression&1 != 0 {
+			data = decompress(data)
+		}
+
+		// An empty packet ends the image
+		if len(data) == 0 {
+			p.status |= statusFull
+			return
+		}
+
+		free := bufferLen - len(p.buffer)
+		p.buffer = append(p.buffer, data[:min(len(data), free)]...)
+		p.status |= statusUnprocessed
+		if len(p.buffer) == bufferLen {
+			p.status |= statusFull
+		}
+
+	case cmdPrint:
+		if len(p.packet) < 4 {
+			p.status |= statusPacketError
+			return
+		}
+		p.print(p.packet[0], p.packet[1], p.packet[2])
+
+	case cmdStatus:
+
+	default:
+		log.Printf("[WARN] unknown printer command $%02X", p.command)
+		p.status |= statusPacketError
+	}
+}
+
+// decompress expands run-length encoded data: a control byte with bit 7 set is followed by
+// a byte repeated (control & $7F) + 2 times, otherwise by (control + 1) bytes copied as they are
+func decompress(data []uint8) []uint8 {
+	var out []uint8
+
+	for i := 0; i < len(data); {
+		control := data[i]
+		i++
+
+		if control&0x80 != 0 {
+			if i == len(data) {
+				break
+			}
+			for range int(control&0x7F) + 2 {
+				out = append(out, data[i])
+			}
+			i++
+		} else {
+			n := min(int(control)+1, len(data)-i)
+			out = append(out, data[i:i+n]...)
+			i += n
+		}
+	}
+
+	return out
+}
+
+// print adds the image data to the strip. The high nibble of margins is the number of line
+// feeds before the image, the low nibble the line feeds after it. Each pair of bits of the
+// palette is the shade of a color, like BGP ($00 is the same as $E4).
+func (p *Printer) print(sheets, margins, palette uint8) {
+	if palette == 0 {
+		palette = 0xE4
+	}
+	rows := len(p.buffer) / tileRowLen * 8
+
+	p.mu.Lock()
+	p.feed(int(margins >> 4))
+	for range sheets {
+		for y := range rows {
+			p.strip = append(p.strip, p.imageRow(y, palette)...)
+		}
+	}
+	p.feed(int(margins & 0xF))
+	p.version++
+	p.mu.Unlock()
+
+	if margins&0xF != 0 {
+		p.completeStrip()
+	}
+
+	p.buffer = p.buffer[:0]
+	p.status &^= statusUnprocessed | statusFull
+	p.status |= statusPrinting
+	p.printTicks = max(rows*int(sheets), 1) * ticksPerRow
+}
+
+func (p *Printer) feed(lines int) {
+	for range lines * feedRows * Width {
+		p.strip = append(p.strip, 0xFF)
+	}
+}
+
+// imageRow returns the gray pixels of a row of the image data
+func (p *Printer) imageRow(y int, palette uint8) []uint8 {
+	row := make([]uint8, Width)
+	base := (y/8)*tileRowLen + (y%8)*2
+
+	for x := range Width {
+		tile := base + (x/8)*16
+		bit := 7 - x%8
+		color := (p.buffer[tile]>>bit)&1 | ((p.buffer[tile+1]>>bit)&1)<<1
+		shade := (palette >> (2 * color)) & 3
+		row[x] = 0xFF - shade*0x55
+	}
+	return row
+}
+
+// completeStrip saves the strip and starts a new one
+func (p *Printer) completeStrip() {
+	p.mu.Lock()
+	p.last = p.strip
+	p.strip = nil
+	img := grayImage(p.last)
+	p.mu.Unlock()
+
+	if p.dir == "" {
+		return
+	}
+	path, err := savePNG(p.dir, img)
+	if err != nil {
+		log.Println("[WARN] could not save the printed image:", err)
+		return
+	}
+	log.Println("Printed", path)
+}
+
+// Strip returns the strip being printed (or the last completed one if nothing is being printed)
+// and a counter increased every time it changes. The image is nil if nothing has been printed.
+func (p *Printer) Strip() (*image.Gray, uint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.strip) > 0 {
+		return grayImage(p.strip), p.version
+	}
+	if len(p.last) > 0 {
+		return grayImage(p.last), p.version
+	}
+	return nil, p.version
+}
+
+// Version returns the counter increased every time the strip changes
+func (p *Printer) Version() uint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.version
+}
+
+func grayImage(pixels []uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, Width, len(pixels)/Width))
+	copy(img.Pix, pixels)
+	return img
+}
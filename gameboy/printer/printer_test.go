@@ -0,0 +1,185 @@
+package printer
+
+import (
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// exchange transfers a byte and returns the byte answered at the same time
+func exchange(p *Printer, b uint8) uint8 {
+	reply := p.Reply()
+	p.Receive(b)
+	return reply
+}
+
+// send transfers a packet and returns the bytes answered by the printer
+func send(p *Printer, command, compression uint8, data []uint8) []uint8 {
+	packet := []uint8{magic1, magic2, command, compression, uint8(len(data)), uint8(len(data) >> 8)}
+	packet = append(packet, data...)
+
+	var sum uint16
+	for _, b := range packet[2:] {
+		sum += uint16(b)
+	}
+	packet = append(packet, uint8(sum), uint8(sum>>8), 0x00, 0x00)
+
+	answer := make([]uint8, len(packet))
+	for i, b := range packet {
+		answer[i] = exchange(p, b)
+	}
+	return answer
+}
+
+// status sends a STATUS packet and returns the status
+func status(p *Printer) uint8 {
+	answer := send(p, cmdStatus, 0, nil)
+	return answer[len(answer)-1]
+}
+
+// tileRows returns image data where every pixel has the color
+func tileRows(rows int, color uint8) []uint8 {
+	var low, high uint8
+	if color&1 != 0 {
+		low = 0xFF
+	}
+	if color&2 != 0 {
+		high = 0xFF
+	}
+
+	data := make([]uint8, rows*tileRowLen)
+	for i := 0; i < len(data); i += 2 {
+		data[i], data[i+1] = low, high
+	}
+	return data
+}
+
+func TestAnswers(t *testing.T) {
+	p := New("")
+
+	answer := send(p, cmdInit, 0, nil)
+	if want := []uint8{0, 0, 0, 0, 0, 0, 0, 0, deviceID, 0}; !slices.Equal(answer, want) {
+		t.Errorf("INIT answer = % X, want % X", answer, want)
+	}
+
+	send(p, cmdData, 0, tileRows(2, 1))
+	if got := status(p); got != statusUnprocessed {
+		t.Errorf("status after DATA = %08b, want %08b", got, statusUnprocessed)
+	}
+	send(p, cmdData, 0, nil)
+	if got := status(p); got != statusUnprocessed|statusFull {
+		t.Errorf("status after end of data = %08b", got)
+	}
+}
+
+func TestChecksumError(t *testing.T) {
+	p := New("")
+
+	packet := []uint8{magic1, magic2, cmdData, 0, 2, 0, 0xAA, 0xBB, 0x00, 0x00, 0x00, 0x00}
+	var answer uint8
+	for _, b := range packet {
+		answer = exchange(p, b)
+	}
+
+	if answer&statusChecksumError == 0 {
+		t.Errorf("status = %08b, want checksum error", answer)
+	}
+	if len(p.buffer) != 0 {
+		t.Error("data with wrong checksum should be discarded")
+	}
+
+	// The error is cleared by the next valid packet
+	if got := status(p); got != 0 {
+		t.Errorf("status = %08b after a valid packet", got)
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	compressed := []uint8{
+		0x82, 0xAA, // $AA repeated 4 times
+		0x02, 0x01, 0x02, 0x03, // 3 bytes
+		0x80, 0xFF, // $FF repeated twice
+	}
+	want := []uint8{0xAA, 0xAA, 0xAA, 0xAA, 0x01, 0x02, 0x03, 0xFF, 0xFF}
+	if got := decompress(compressed); !slices.Equal(got, want) {
+		t.Errorf("decompress = % X, want % X", got, want)
+	}
+
+	// Compressed packets fill the buffer like uncompressed ones
+	p := New("")
+	send(p, cmdData, 1, []uint8{0xFF, 0xAA, 0x01, 0x01, 0x02})
+	if len(p.buffer) != 131 || !slices.Equal(p.buffer[128:], []uint8{0xAA, 0x01, 0x02}) {
+		t.Errorf("buffer = % X", p.buffer)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	dir := t.TempDir()
+	p := New(dir)
+
+	send(p, cmdInit, 0, nil)
+	send(p, cmdData, 0, tileRows(2, 1))
+	send(p, cmdData, 0, nil)
+	// 1 sheet, 1 line feed before, 0 after, color 1 printed as black
+	send(p, cmdPrint, 0, []uint8{1, 0x10, 0b00_00_11_00, 0x40})
+
+	if status(p)&statusPrinting == 0 {
+		t.Error("printer should be busy after PRINT")
+	}
+	p.Tick(16 * ticksPerRow)
+	if got := status(p); got != 0 {
+		t.Errorf("status after printing = %08b", got)
+	}
+
+	// Continue the strip with another image and 2 line feeds after it
+	send(p, cmdInit, 0, nil)
+	send(p, cmdData, 0, tileRows(2, 2))
+	send(p, cmdData, 0, nil)
+	send(p, cmdPrint, 0, []uint8{1, 0x02, 0xE4, 0x40})
+
+	strip, _ := p.Strip()
+	wantHeight := feedRows + 16 + 16 + 2*feedRows
+	if strip == nil || strip.Bounds().Dy() != wantHeight {
+		t.Fatalf("strip = %v, want %d rows", strip.Bounds(), wantHeight)
+	}
+	for _, tt := range []struct {
+		y    int
+		want uint8
+	}{
+		{0, 0xFF},              // Margin
+		{feedRows, 0x00},       // Color 1 with the custom palette
+		{feedRows + 16, 0x55},  // Color 2 with the default palette
+		{wantHeight - 1, 0xFF}, // Margin
+	} {
+		if got := strip.GrayAt(5, tt.y).Y; got != tt.want {
+			t.Errorf("row %d = $%02X, want $%02X", tt.y, got, tt.want)
+		}
+	}
+
+	// The strip is complete
+	f, err := os.Open(filepath.Join(dir, "print-001.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != strip.Bounds() {
+		t.Errorf("saved image %v, want %v", img.Bounds(), strip.Bounds())
+	}
+}
+
+func TestPrintWithoutDirectory(t *testing.T) {
+	p := New("")
+	send(p, cmdData, 0, tileRows(2, 3))
+	send(p, cmdPrint, 0, []uint8{1, 0x01, 0xE4, 0x40})
+
+	version := p.Version()
+	if strip, _ := p.Strip(); strip == nil || version == 0 {
+		t.Error("the last strip should be available for the preview")
+	}
+}
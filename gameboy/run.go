@@ -0,0 +1,66 @@
+package gameboy
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/ppu"
+
+// FrameTicks is the number of ticks in a frame at normal speed (154 lines of 456 dots)
+const FrameTicks = 154 * 456
+
+// tickCounter counts the ticks elapsed since the components were initialized
+type tickCounter uint64
+
+func (c *tickCounter) Tick(ticks int) {
+	*c += tickCounter(ticks)
+}
+
+// Ticks returns the number of ticks elapsed since the game was loaded
+func (gb *GameBoy) Ticks() uint64 {
+	return uint64(gb.ticks)
+}
+
+// Step executes a single CPU instruction (polling the input provider first)
+func (gb *GameBoy) Step() {
+	gb.Joypad.DetectKeysPressed()
+	gb.CPU.ExecuteInstruction()
+}
+
+// RunCycles executes instructions until at least n ticks have elapsed
+// and returns the number of ticks actually run
+func (gb *GameBoy) RunCycles(n int) int {
+	start := gb.ticks
+	for int(gb.ticks-start) < n {
+		gb.Step()
+	}
+	return int(gb.ticks - start)
+}
+
+// RunUntilVBlank executes instructions until the PPU enters VBlank. If the LCD is off
+// it returns false after the duration of a frame, so it never runs indefinitely.
+func (gb *GameBoy) RunUntilVBlank() bool {
+	frame := gb.PPU.FrameCount()
+
+	// In double speed mode the CPU runs twice as many ticks in a frame
+	limit := tickCounter(FrameTicks)
+	if gb.Memory.DoubleSpeed {
+		limit *= 2
+	}
+
+	var lcdOffTicks tickCounter
+	for gb.PPU.FrameCount() == frame {
+		before := gb.ticks
+		gb.Step()
+
+		if !gb.PPU.LCDEnabled() {
+			lcdOffTicks += gb.ticks - before
+			if lcdOffTicks >= limit {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// RunFrame emulates a whole frame and returns it
+func (gb *GameBoy) RunFrame() *[ppu.FrameHeight][ppu.FrameWidth]uint16 {
+	gb.RunUntilVBlank()
+	return gb.PPU.GetFrame()
+}
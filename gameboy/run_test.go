@@ -0,0 +1,242 @@
+package gameboy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/cartridge"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/serial"
+)
+
+func TestRunFrame(t *testing.T) {
+	for _, model := range []SystemModel{DMG, CGB} {
+		gb := newTestGameBoy(t, model, "RUN TEST", loopProgram)
+
+		// Instructions last up to 24 ticks, so VBlank can be overshot a little
+		for range 10 {
+			frames := gb.PPU.FrameCount()
+			ticks := gb.Ticks()
+			if !gb.RunUntilVBlank() {
+				t.Fatalf("model %d: VBlank not reached", model)
+			}
+			if gb.PPU.FrameCount() != frames+1 {
+				t.Errorf("model %d: expected one frame, got %d", model, gb.PPU.FrameCount()-frames)
+			}
+			if elapsed := gb.Ticks() - ticks; elapsed > FrameTicks+24 {
+				t.Errorf("model %d: frame lasted %d ticks", model, elapsed)
+			}
+		}
+
+		// From VBlank to VBlank
+		ticks := gb.Ticks()
+		if frame := gb.RunFrame(); frame != gb.PPU.GetFrame() {
+			t.Errorf("model %d: RunFrame did not return the current frame", model)
+		}
+		if elapsed := gb.Ticks() - ticks; elapsed < FrameTicks-24 || elapsed > FrameTicks+24 {
+			t.Errorf("model %d: expected frame of %d ticks, got %d", model, FrameTicks, elapsed)
+		}
+	}
+}
+
+func TestRunCycles(t *testing.T) {
+	gb := newTestGameBoy(t, DMG, "RUN TEST", loopProgram)
+
+	for _, n := range []int{1, 4, 100, FrameTicks} {
+		ticks := gb.Ticks()
+		run := gb.RunCycles(n)
+		if run < n || run > n+24 {
+			t.Errorf("RunCycles(%d) ran %d ticks", n, run)
+		}
+		if gb.Ticks()-ticks != uint64(run) {
+			t.Errorf("RunCycles(%d) returned %d, but %d ticks elapsed", n, run, gb.Ticks()-ticks)
+		}
+	}
+}
+
+func TestRunLCDOff(t *testing.T) {
+	program := []uint8{
+		0xAF,       // XOR A
+		0xE0, 0x40, // LDH ($40),A ; LCD off
+		0x18, 0xFE, // JR -2
+	}
+	gb := newTestGameBoy(t, DMG, "LCD OFF", program)
+	gb.RunCycles(16)
+
+	ticks := gb.Ticks()
+	if gb.RunUntilVBlank() {
+		t.Errorf("VBlank reached with LCD off")
+	}
+	if elapsed := gb.Ticks() - ticks; elapsed < FrameTicks || elapsed > FrameTicks+24 {
+		t.Errorf("expected a frame of %d ticks, got %d", FrameTicks, elapsed)
+	}
+}
+
+func TestRunWithoutAudio(t *testing.T) {
+	gb := New(nil, 44100)
+	gb.Load(newTestGameBoy(t, DMG, "NO AUDIO", loopProgram).Memory.Cartridge)
+	gb.LoadBootROM(nil)
+
+	for range 5 {
+		gb.RunFrame()
+	}
+}
+
+func TestBootCheckLockUp(t *testing.T) {
+	rom := testROM("BAD CHECKSUM", loopProgram)
+	rom[0x14D]++
+
+	cart, err := cartridge.NewCartridge(rom, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cart.Header().Warnings) == 0 {
+		t.Errorf("expected header warnings")
+	}
+
+	gb := New(nil, 44100)
+	gb.Load(cart)
+	gb.LoadBootROM(nil)
+	gb.RunFrame()
+
+	// The CPU is stuck but the other components are running
+	if !gb.CPU.Locked() || gb.CPU.PC != 0x0100 {
+		t.Errorf("expected CPU locked at $0100, PC = %04X", gb.CPU.PC)
+	}
+	if gb.PPU.FrameCount() == 0 {
+		t.Errorf("PPU is not running")
+	}
+}
+
+func TestSaveChanged(t *testing.T) {
+	gb := newTestGameBoy(t, DMG, "SAVE CHANGED", []uint8{0x18, 0xFE}) // JR -2
+	gb.RunFrame()
+	if gb.SaveChanged() {
+		t.Errorf("save changed without RAM writes")
+	}
+
+	// MBC registers are not part of the save
+	gb.Memory.Write(0x2000, 0x01)
+	if gb.SaveChanged() {
+		t.Errorf("save changed writing MBC registers")
+	}
+
+	// Writes are ignored while the RAM is disabled
+	gb.Memory.Write(0xA000, 0x42)
+	if gb.SaveChanged() {
+		t.Errorf("save changed writing disabled RAM")
+	}
+
+	gb.Memory.Write(0x0000, 0x0A)
+	gb.Memory.Write(0xA000, 0x42)
+	if !gb.SaveChanged() {
+		t.Errorf("RAM write not detected")
+	}
+	if gb.SaveChanged() {
+		t.Errorf("change reported twice")
+	}
+
+	// Writing the same value does not change the save
+	gb.Memory.Write(0xA000, 0x42)
+	if gb.SaveChanged() {
+		t.Errorf("save changed writing the same value")
+	}
+}
+
+func TestSerialDevice(t *testing.T) {
+	gb := newTestGameBoy(t, DMG, "SERIAL", []uint8{
+		0x3E, 0x88, // LD A,$88
+		0xE0, 0x01, // LDH ($01),A ; SB
+		0x3E, 0x81, // LD A,$81
+		0xE0, 0x02, // LDH ($02),A ; start transfer with internal clock
+		0x18, 0xFE, // JR -2
+	})
+
+	for _, tt := range []struct {
+		name   string
+		device serial.Device
+		want   uint8
+	}{
+		{"disconnected", nil, 0xFF},
+		{"loopback", serial.Loopback{}, 0x88},
+		{"scripted", serial.Bytes(serial.NewScripted(0x5A)), 0x5A},
+	} {
+		gb.SetSerialDevice(tt.device)
+		gb.Reset()
+
+		// 8 bits at 8192 Hz
+		gb.RunCycles(9 * 512)
+
+		if sb := gb.SerialPort.Read(0xFF01); sb != tt.want {
+			t.Errorf("%s: SB = %02X, want %02X", tt.name, sb, tt.want)
+		}
+		if sc := gb.SerialPort.Read(0xFF02); sc&0x80 != 0 {
+			t.Errorf("%s: transfer not complete, SC = %02X", tt.name, sc)
+		}
+	}
+}
+
+func TestSerialLogger(t *testing.T) {
+	var program []uint8
+	for _, c := range "OK" {
+		program = append(program,
+			0x3E, uint8(c), // LD A,c
+			0xE0, 0x01, // LDH ($01),A
+			0x3E, 0x81, // LD A,$81
+			0xE0, 0x02, // LDH ($02),A
+			0xF0, 0x02, // LDH A,($02) ; wait for the end of the transfer
+			0x87,       // ADD A
+			0x38, 0xFB, // JR C,-5
+		)
+	}
+	program = append(program, 0x18, 0xFE) // JR -2
+	gb := newTestGameBoy(t, DMG, "SERIAL LOG", program)
+
+	var output bytes.Buffer
+	gb.SetSerialDevice(serial.Bytes(serial.NewLogger(&output)))
+	gb.Reset()
+	gb.RunCycles(20 * 512)
+
+	if got := output.String(); got != "OK" {
+		t.Errorf("logged %q, want \"OK\"", got)
+	}
+}
+
+func TestSerialDoubleSpeed(t *testing.T) {
+	gb := newTestGameBoy(t, CGB, "FAST SERIAL", []uint8{
+		0x3E, 0x01, // LD A,$01
+		0xE0, 0x4D, // LDH ($4D),A ; prepare speed switch
+		0x10, 0x00, // STOP
+		0x3E, 0x83, // LD A,$83
+		0xE0, 0x02, // LDH ($02),A ; start transfer with the fast internal clock
+		0x18, 0xFE, // JR -2
+	})
+
+	// The CPU is halted while switching speed
+	gb.RunCycles(0x20000 + 256)
+
+	if !gb.Memory.DoubleSpeed {
+		t.Fatal("speed not switched")
+	}
+	if rate := gb.SerialPort.ClockRate(); rate != 524288 {
+		t.Errorf("serial clock %d Hz, want 524288", rate)
+	}
+	if sc := gb.SerialPort.Read(0xFF02); sc&0x80 != 0 {
+		t.Errorf("transfer not complete, SC = %02X", sc)
+	}
+}
+
+func TestInfraredDMG(t *testing.T) {
+	gb := newTestGameBoy(t, DMG, "IR DMG", []uint8{
+		0x3E, 0xC1, // LD A,$C1
+		0xE0, 0x56, // LDH ($56),A
+		0x18, 0xFE, // JR -2
+	})
+	gb.RunCycles(100)
+
+	if rp := gb.Memory.Read(0xFF56); rp != 0xFF {
+		t.Errorf("RP = %02X on DMG, want FF", rp)
+	}
+	if gb.Infrared.LED() {
+		t.Error("the DMG has no IR LED")
+	}
+}
@@ -0,0 +1,142 @@
+package savefile
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// Writes are delayed at most by this many times the autosave delay when the game keeps writing
+	maxDelayFactor = 5
+
+	// The save is backed up on the first write and then at most this often,
+	// so that the backups are not all from the last minutes of play
+	rotateInterval = 5 * time.Minute
+)
+
+// Autosaver writes the battery save in the background once the cartridge RAM stops changing.
+// Saves are written atomically and the previous ones are kept as rotating backups.
+type Autosaver struct {
+	path    string
+	delay   time.Duration
+	backups int
+
+	mu           sync.Mutex
+	pending      []uint8
+	timer        *time.Timer
+	firstPending time.Time
+	// Increased for every change, so that stale data is never written over a newer save
+	generation uint64
+
+	// Serializes file writes
+	writeMu    sync.Mutex
+	written    uint64 // Generation of the data in the file
+	lastRotate time.Time
+}
+
+// NewAutosaver creates an autosaver for the save file, writing delay after the last change
+// (never if delay is 0) and keeping the given number of backups
+func NewAutosaver(path string, delay time.Duration, backups int) *Autosaver {
+	return &Autosaver{
+		path:    path,
+		delay:   delay,
+		backups: backups,
+	}
+}
+
+// Changed schedules writing the save data (which is copied) in the background
+func (a *Autosaver) Changed(data []uint8) {
+	if a.delay <= 0 || data == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pending = append(a.pending[:0:0], data...)
+	a.generation++
+	switch {
+	case a.timer == nil:
+		a.firstPending = time.Now()
+		a.timer = time.AfterFunc(a.delay, a.flush)
+	case time.Since(a.firstPending) < maxDelayFactor*a.delay:
+		// Debounce, unless the data has been waiting for too long
+		a.timer.Reset(a.delay)
+	}
+}
+
+// flush writes the pending data
+func (a *Autosaver) flush() {
+	data, generation := a.takePending()
+	if data == nil {
+		return
+	}
+	if err := a.write(data, generation); err != nil {
+		log.Println("error writing game save:", err)
+	}
+}
+
+// takePending returns the pending data and its generation, clearing it
+func (a *Autosaver) takePending() ([]uint8, uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	data := a.pending
+	a.pending = nil
+	a.timer = nil
+	return data, a.generation
+}
+
+// Save writes the save data immediately, discarding the pending changes
+func (a *Autosaver) Save(data []uint8) error {
+	a.mu.Lock()
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+	a.pending = nil
+	a.generation++
+	generation := a.generation
+	a.mu.Unlock()
+
+	if data == nil {
+		return nil
+	}
+	return a.write(data, generation)
+}
+
+// Close writes the pending changes, if any, and waits for the writes in progress
+func (a *Autosaver) Close() {
+	a.mu.Lock()
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	a.mu.Unlock()
+
+	a.flush()
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+}
+
+// write writes the data unless newer data has been written in the meantime
+func (a *Autosaver) write(data []uint8, generation uint64) error {
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+
+	if generation <= a.written {
+		return nil
+	}
+
+	if time.Since(a.lastRotate) >= rotateInterval {
+		if err := Rotate(a.path, a.backups); err != nil {
+			return err
+		}
+		a.lastRotate = time.Now()
+	}
+	if err := WriteAtomic(a.path, data); err != nil {
+		return err
+	}
+	a.written = generation
+	return nil
+}
@@ -0,0 +1,118 @@
+package savefile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readSave(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestAutosaver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+	a := NewAutosaver(path, 20*time.Millisecond, 2)
+
+	// Changes are debounced: only the last one is written
+	a.Changed([]uint8("1"))
+	a.Changed([]uint8("2"))
+	time.Sleep(100 * time.Millisecond)
+	if got := readSave(t, path); got != "2" {
+		t.Errorf("autosave: got %q", got)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("unexpected backup")
+	}
+
+	// The data is copied when the change is notified
+	data := []uint8("3")
+	a.Changed(data)
+	data[0] = 'X'
+	a.Close()
+	if got := readSave(t, path); got != "3" {
+		t.Errorf("close: got %q", got)
+	}
+
+	// The save is backed up once per session
+	if err := a.Save([]uint8("4")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readSave(t, path); got != "4" {
+		t.Errorf("save: got %q", got)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("backup written by every save")
+	}
+
+	// Only the last 2 saves are kept as backups
+	for _, data := range []string{"5", "6", "7"} {
+		if err := NewAutosaver(path, 0, 2).Save([]uint8(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := readSave(t, path); got != "7" {
+		t.Errorf("save: got %q", got)
+	}
+	if got := readSave(t, path+".1"); got != "6" {
+		t.Errorf("backup 1: got %q", got)
+	}
+	if got := readSave(t, path+".2"); got != "5" {
+		t.Errorf("backup 2: got %q", got)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("too many backups")
+	}
+
+	// No temporary files are left
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 3 {
+		t.Errorf("got %d files in the save directory", len(entries))
+	}
+}
+
+func TestAutosaverSaveRacesFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+	a := NewAutosaver(path, time.Hour, 1)
+
+	// The pending data is taken by a flush, then Save writes newer data before it
+	a.Changed([]uint8("old"))
+	data, generation := a.takePending()
+	if err := a.Save([]uint8("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.write(data, generation); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readSave(t, path); got != "new" {
+		t.Errorf("stale data written over the save: got %q", got)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("save rotated by the stale write")
+	}
+}
+
+func TestAutosaverDisabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+	a := NewAutosaver(path, 0, 1)
+
+	a.Changed([]uint8("1"))
+	a.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("save written with autosave disabled")
+	}
+
+	if err := a.Save([]uint8("2")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readSave(t, path); got != "2" {
+		t.Errorf("save: got %q", got)
+	}
+}
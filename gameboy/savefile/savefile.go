@@ -0,0 +1,115 @@
+// Package savefile manages the battery save files stored next to the ROMs
+package savefile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/cartridge"
+)
+
+// Path returns the path of the save file of the ROM
+func Path(romPath string) string {
+	// Remove gb extension
+	return romPath[:len(romPath)-len(filepath.Ext(romPath))] + ".sav"
+}
+
+// Load reads the save of the ROM (nil if there is none). Saves written by other emulators
+// or flash carts are converted with cartridge.ImportSave: the original file is backed up,
+// then replaced by the converted save so that it is converted only once.
+func Load(romPath string, romData []uint8) ([]uint8, error) {
+	path := Path(romPath)
+	savData, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	data, converted, err := cartridge.ImportSave(romData, savData)
+	if err != nil {
+		return nil, err
+	}
+	if converted {
+		backup, err := Backup(path)
+		if err != nil {
+			return nil, fmt.Errorf("backing up save file: %w", err)
+		}
+		if err := WriteAtomic(path, data); err != nil {
+			return nil, fmt.Errorf("writing converted save file: %w", err)
+		}
+		log.Printf("[WARN] %s has been converted, the original file is kept in %s", filepath.Base(path), filepath.Base(backup))
+	}
+	return data, nil
+}
+
+// LoadCartridge reads the ROM and its save and creates the cartridge. If the save does not fit
+// the cartridge, the game starts with empty RAM: saving the game would overwrite the save, so it
+// is backed up first.
+func LoadCartridge(romPath string, clock cartridge.Clock, multicart cartridge.MulticartMode, rtcMode cartridge.RTCMode) (cartridge.Cartridge, error) {
+	romData, err := os.ReadFile(romPath)
+	if err != nil {
+		return nil, err
+	}
+	savData, err := Load(romPath, romData)
+	if err != nil {
+		return nil, err
+	}
+
+	rom, err := cartridge.NewCartridgeWithClock(romData, savData, clock)
+	var saveErr *cartridge.SaveSizeError
+	if errors.As(err, &saveErr) {
+		backup, backupErr := Backup(Path(romPath))
+		if backupErr != nil {
+			return nil, fmt.Errorf("backing up save file: %w", backupErr)
+		}
+		log.Printf("[WARN] %s: %v, starting with empty RAM (the save is kept in %s)", filepath.Base(romPath), err, filepath.Base(backup))
+		rom, err = cartridge.NewCartridgeWithClock(romData, nil, clock)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(romPath), err)
+	}
+
+	for _, warning := range rom.Header().Warnings {
+		log.Println("[WARN]", warning)
+	}
+	multicart.Apply(rom)
+	rtcMode.Apply(rom)
+	return rom, nil
+}
+
+// Backup copies the file to the first free name among path.bak, path.bak.1, path.bak.2, ...
+// and returns the name of the copy. If one of them already has the same content, its name is
+// returned instead.
+func Backup(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	backup := path + ".bak"
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			if existing, err := os.ReadFile(backup); err == nil && bytes.Equal(existing, data) {
+				return backup, nil
+			}
+			backup = fmt.Sprintf("%s.bak.%d", path, i)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return backup, err
+	}
+}
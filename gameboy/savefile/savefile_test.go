@@ -0,0 +1,109 @@
+package savefile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/cartridge"
+)
+
+func TestLoadBacksUpConvertedSaves(t *testing.T) {
+	dir := t.TempDir()
+	romPath := filepath.Join(dir, "game.gb")
+
+	// MBC5 + RAM + BATTERY with 8 KiB of RAM
+	rom := make([]uint8, 0x8000)
+	rom[0x147], rom[0x149] = 0x1B, 0x02
+
+	if data, err := Load(romPath, rom); err != nil || data != nil {
+		t.Fatalf("missing save: got %v, %v", data, err)
+	}
+
+	// Native save: no backup
+	savPath := Path(romPath)
+	if err := os.WriteFile(savPath, make([]uint8, 0x2000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(romPath, rom); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(savPath + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("native save backed up")
+	}
+
+	// Padded save: backed up and converted once
+	padded := make([]uint8, 0x8000)
+	padded[0] = 0x42
+	if err := os.WriteFile(savPath, padded, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		data, err := Load(romPath, rom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) != 0x2000 || data[0] != 0x42 {
+			t.Errorf("save not converted")
+		}
+	}
+	if b, err := os.ReadFile(savPath + ".bak"); err != nil || len(b) != len(padded) {
+		t.Errorf("backup: %v", err)
+	}
+	if b, err := os.ReadFile(savPath); err != nil || len(b) != 0x2000 {
+		t.Errorf("converted save not written: %v", err)
+	}
+	if _, err := os.Stat(savPath + ".bak.1"); !os.IsNotExist(err) {
+		t.Errorf("save backed up twice")
+	}
+}
+
+func TestLoadCartridge(t *testing.T) {
+	dir := t.TempDir()
+	romPath := filepath.Join(dir, "game.gb")
+
+	// MBC5 + RAM + BATTERY with 8 KiB of RAM
+	rom := make([]uint8, 0x8000)
+	rom[0x147], rom[0x149] = 0x1B, 0x02
+	if err := os.WriteFile(romPath, rom, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadCartridge(filepath.Join(dir, "missing.gb"), nil, cartridge.MulticartAuto, cartridge.RTCEmulated); err == nil {
+		t.Errorf("missing ROM loaded")
+	}
+
+	// Padded save, converted when loaded
+	padded := make([]uint8, 0x8000)
+	padded[0] = 0x42
+	if err := os.WriteFile(Path(romPath), padded, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadCartridge(romPath, nil, cartridge.MulticartAuto, cartridge.RTCEmulated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ram := c.RAMDump(); len(ram) != 0x2000 || ram[0] != 0x42 {
+		t.Errorf("got %d bytes of RAM", len(ram))
+	}
+}
+
+func TestBackupReusesIdenticalCopy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+	if err := os.WriteFile(path, []uint8("1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if backup, err := Backup(path); err != nil || backup != path+".bak" {
+			t.Errorf("got %q, %v", backup, err)
+		}
+	}
+
+	if err := os.WriteFile(path, []uint8("2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if backup, err := Backup(path); err != nil || backup != path+".bak.1" {
+		t.Errorf("changed file: got %q, %v", backup, err)
+	}
+}
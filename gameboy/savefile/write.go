@@ -0,0 +1,60 @@
+package savefile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteAtomic writes the file through a temporary file renamed over it,
+// so that a crash while writing never leaves a truncated save
+func WriteAtomic(path string, data []uint8) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
+// Rotate shifts the backups of the file (path.1 is the most recent) keeping at most n of them,
+// then moves the file to path.1
+func Rotate(path string, n int) error {
+	if n <= 0 {
+		return nil
+	}
+
+	backup := func(i int) string {
+		return fmt.Sprintf("%s.%d", path, i)
+	}
+	for i := n - 1; i > 0; i-- {
+		if err := os.Rename(backup(i), backup(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	// Copy instead of renaming, so that the save file is never missing
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return WriteAtomic(backup(1), data)
+}
@@ -0,0 +1,159 @@
+// Package savestate implements the binary encoding used by the emulator components
+// to serialize their internal state.
+//
+// Values are stored in little endian. The Encoder and Decoder record the first error
+// that happens, so that components can write or read all their fields and check the
+// error only once at the end.
+package savestate
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrLengthMismatch = errors.New("savestate: length mismatch")
+
+type Encoder struct {
+	w   io.Writer
+	err error
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Err returns the first error encountered while encoding
+func (e *Encoder) Err() error {
+	return e.err
+}
+
+func (e *Encoder) write(v any) {
+	if e.err != nil {
+		return
+	}
+	e.err = binary.Write(e.w, binary.LittleEndian, v)
+}
+
+func (e *Encoder) U8(v uint8)   { e.write(v) }
+func (e *Encoder) U16(v uint16) { e.write(v) }
+func (e *Encoder) U32(v uint32) { e.write(v) }
+func (e *Encoder) U64(v uint64) { e.write(v) }
+func (e *Encoder) Bool(v bool)  { e.write(v) }
+
+// Int stores an int as a 64-bit value
+func (e *Encoder) Int(v int) { e.write(int64(v)) }
+
+// Uint stores an uint as a 64-bit value
+func (e *Encoder) Uint(v uint) { e.write(uint64(v)) }
+
+// Float64 stores a float64 as its IEEE 754 representation
+func (e *Encoder) Float64(v float64) { e.write(v) }
+
+// Bytes stores a slice with its length
+func (e *Encoder) Bytes(v []uint8) {
+	e.U32(uint32(len(v)))
+	e.write(v)
+}
+
+// U8s and U16s store a fixed length slice, the decoder must know its length
+func (e *Encoder) U8s(v []uint8)   { e.write(v) }
+func (e *Encoder) U16s(v []uint16) { e.write(v) }
+
+// String stores a string with its length
+func (e *Encoder) String(v string) {
+	e.Bytes([]uint8(v))
+}
+
+type Decoder struct {
+	r   io.Reader
+	err error
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Err returns the first error encountered while decoding
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// Fail records err if no other error was encountered before
+func (d *Decoder) Fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *Decoder) read(v any) {
+	if d.err != nil {
+		return
+	}
+	d.err = binary.Read(d.r, binary.LittleEndian, v)
+}
+
+func (d *Decoder) U8(v *uint8)   { d.read(v) }
+func (d *Decoder) U16(v *uint16) { d.read(v) }
+func (d *Decoder) U32(v *uint32) { d.read(v) }
+func (d *Decoder) U64(v *uint64) { d.read(v) }
+func (d *Decoder) Bool(v *bool)  { d.read(v) }
+
+func (d *Decoder) Int(v *int) {
+	var x int64
+	d.read(&x)
+	*v = int(x)
+}
+
+func (d *Decoder) Uint(v *uint) {
+	var x uint64
+	d.read(&x)
+	*v = uint(x)
+}
+
+func (d *Decoder) Float64(v *float64) { d.read(v) }
+
+// Bytes reads a slice stored with Encoder.Bytes
+func (d *Decoder) Bytes() []uint8 {
+	var n uint32
+	d.U32(&n)
+	if d.err != nil {
+		return nil
+	}
+
+	// Do not trust the length blindly, read at most what is available
+	buf, err := io.ReadAll(io.LimitReader(d.r, int64(n)))
+	if err != nil {
+		d.Fail(err)
+		return nil
+	}
+	if len(buf) != int(n) {
+		d.Fail(io.ErrUnexpectedEOF)
+		return nil
+	}
+	return buf
+}
+
+// BytesInto reads a slice stored with Encoder.Bytes into dst,
+// the stored slice must have the same length of dst
+func (d *Decoder) BytesInto(dst []uint8) {
+	buf := d.Bytes()
+	if d.err != nil {
+		return
+	}
+	if len(buf) != len(dst) {
+		d.Fail(fmt.Errorf("%w: got %d bytes, expected %d", ErrLengthMismatch, len(buf), len(dst)))
+		return
+	}
+	copy(dst, buf)
+}
+
+// U8s and U16s read a slice stored with Encoder.U8s and Encoder.U16s, filling dst
+func (d *Decoder) U8s(dst []uint8)   { d.read(dst) }
+func (d *Decoder) U16s(dst []uint16) { d.read(dst) }
+
+// String reads a string stored with Encoder.String
+func (d *Decoder) String() string {
+	return string(d.Bytes())
+}
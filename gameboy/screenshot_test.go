@@ -0,0 +1,203 @@
+package gameboy
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/palette"
+)
+
+// ROMs and reference images are in testdata/screenshots, the reference of a test is
+// named after the ROM and the model (e.g. dmg-acid2-dmg.png). On mismatch the actual
+// frame and a diff image are written to testdata/screenshots/output.
+//
+// Run `go test -run TestScreenshots -args -update` to write the reference images.
+var updateScreenshots = flag.Bool("update", false, "write the screenshot reference images")
+
+const (
+	screenshotsDir       = "testdata/screenshots"
+	screenshotsOutputDir = "testdata/screenshots/output"
+)
+
+var screenshotTests = []struct {
+	rom    string
+	model  SystemModel
+	frames int
+}{
+	{"pattern.gb", DMG, 10},
+	{"pattern.gb", CGB, 10},
+	{"dmg-acid2.gb", DMG, 60},
+	{"dmg-acid2.gb", CGB, 60},
+	{"cgb-acid2.gbc", CGB, 60},
+}
+
+// patternProgram fills the tiles and the background map with a pattern of their addresses,
+// so that the screenshot tests always have a ROM to run
+var patternProgram = []uint8{
+	// wait:
+	0xF0, 0x44, // LDH A,($44) ; LY
+	0xFE, 0x90, // CP $90
+	0x20, 0xFA, // JR NZ,wait
+	0xAF,       // XOR A
+	0xE0, 0x40, // LDH ($40),A ; LCD off
+	0x21, 0x00, 0x80, // LD HL,$8000
+	// tiles:
+	0x7D,       // LD A,L
+	0xAC,       // XOR H
+	0x22,       // LD (HL+),A
+	0x7C,       // LD A,H
+	0xFE, 0x90, // CP $90
+	0x20, 0xF8, // JR NZ,tiles
+	0x21, 0x00, 0x98, // LD HL,$9800
+	// map:
+	0x7D,       // LD A,L
+	0xAC,       // XOR H
+	0x22,       // LD (HL+),A
+	0x7C,       // LD A,H
+	0xFE, 0x9C, // CP $9C
+	0x20, 0xF8, // JR NZ,map
+	0x3E, 0xE4, // LD A,$E4
+	0xE0, 0x47, // LDH ($47),A ; BGP
+	0x3E, 0x91, // LD A,$91
+	0xE0, 0x40, // LDH ($40),A ; LCD on, tiles at $8000
+	0x18, 0xFE, // JR $
+}
+
+// generatedROMs are built by the tests instead of being read from testdata
+var generatedROMs = map[string][]uint8{
+	"pattern.gb": testROM("PATTERN", patternProgram),
+}
+
+func TestScreenshots(t *testing.T) {
+	for _, test := range screenshotTests {
+		name := strings.TrimSuffix(test.rom, filepath.Ext(test.rom)) + "-" + test.model.String()
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			romPath := filepath.Join(screenshotsDir, test.rom)
+			if data, ok := generatedROMs[test.rom]; ok {
+				romPath = filepath.Join(t.TempDir(), test.rom)
+				if err := os.WriteFile(romPath, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			} else if _, err := os.Stat(romPath); err != nil {
+				t.Skipf("%s not found", romPath)
+			}
+
+			gb, err := loadTestROM(romPath, test.model)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for range test.frames {
+				gb.RunFrame()
+			}
+			actual := screenshot(gb)
+
+			refPath := filepath.Join(screenshotsDir, name+".png")
+			if *updateScreenshots {
+				if err := writePNG(refPath, actual); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := readPNG(refPath)
+			if errors.Is(err, os.ErrNotExist) {
+				t.Skipf("%s not found (run with -args -update to create it)", refPath)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			diff, count := diffImages(expected, actual)
+			if count == 0 {
+				return
+			}
+
+			// Save actual frame and differences
+			actualPath := filepath.Join(screenshotsOutputDir, name+".png")
+			diffPath := filepath.Join(screenshotsOutputDir, name+"-diff.png")
+			if err := os.MkdirAll(screenshotsOutputDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := writePNG(actualPath, actual); err != nil {
+				t.Fatal(err)
+			}
+			if err := writePNG(diffPath, diff); err != nil {
+				t.Fatal(err)
+			}
+			t.Errorf("%d pixels differ from %s (see %s and %s)", count, refPath, actualPath, diffPath)
+		})
+	}
+}
+
+// screenshot converts the current frame to an image with the palette used by the frontend
+func screenshot(gb *GameBoy) *image.RGBA {
+	var p palette.Palette = palette.DMG{}
+	if gb.EmulationModel == CGB {
+		p = palette.CGB{}
+	}
+	return palette.Frame(gb.PPU.GetFrame(), p)
+}
+
+// diffImages returns an image with the different pixels in red over the faded
+// expected image, and the number of different pixels
+func diffImages(expected image.Image, actual *image.RGBA) (*image.RGBA, int) {
+	bounds := actual.Bounds()
+	diff := image.NewRGBA(bounds)
+	count := 0
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			er, eg, eb, _ := expected.At(x, y).RGBA()
+			ar, ag, ab, _ := actual.At(x, y).RGBA()
+
+			if er>>8 == ar>>8 && eg>>8 == ag>>8 && eb>>8 == ab>>8 {
+				gray := uint8((er + eg + eb) / 3 >> 8)
+				diff.Set(x, y, color.RGBA{R: 128 + gray/2, G: 128 + gray/2, B: 128 + gray/2, A: 255})
+			} else {
+				diff.Set(x, y, color.RGBA{R: 255, A: 255})
+				count++
+			}
+		}
+	}
+
+	// Images of different size are completely different
+	if !expected.Bounds().Eq(bounds) {
+		count = max(count, bounds.Dx()*bounds.Dy())
+	}
+	return diff, count
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return img, nil
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return png.Encode(f, img)
+}
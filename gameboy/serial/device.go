@@ -0,0 +1,183 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// Device is plugged into the other end of the link cable. Bits are exchanged one at a time, clocked
+// either by the Game Boy (internal clock) or by the device (external clock).
+type Device interface {
+	// ExchangeBit receives the bit sent by the Game Boy and returns the bit sent back
+	ExchangeBit(out uint8) uint8
+	// DrivesClock is polled at every tick while the Game Boy waits for the external clock:
+	// it reports whether the device clocked a bit, which is then exchanged with ExchangeBit
+	DrivesClock() bool
+}
+
+// follower is implemented by devices answering the bits clocked by the other end on their own
+// goroutine, without waiting for the emulation. The port tells them SB and whether it waits for
+// the external clock whenever they change.
+type follower interface {
+	FollowPort(sb uint8, waiting bool)
+}
+
+// ByteDevice exchanges whole bytes clocked by the Game Boy, like the Game Boy Printer.
+// As on real hardware, the byte sent back is ready before the transfer starts.
+type ByteDevice interface {
+	// Reply returns the byte sent back during the next transfer
+	Reply() uint8
+	// Receive is called with the byte sent by the Game Boy when the transfer is complete
+	Receive(b uint8)
+}
+
+// byteShifter shifts the bytes of a ByteDevice one bit at a time
+type byteShifter struct {
+	device ByteDevice
+	ticker ticker
+
+	in, out uint8
+	bits    int
+}
+
+// Bytes plugs a ByteDevice into the serial port. Devices with their own timing (with a
+// Tick(ticks int) method) are clocked by the port.
+func Bytes(device ByteDevice) Device {
+	s := &byteShifter{device: device}
+	s.ticker, _ = device.(ticker)
+	return s
+}
+
+func (s *byteShifter) ExchangeBit(out uint8) uint8 {
+	if s.bits == 0 {
+		s.in = s.device.Reply()
+	}
+
+	bit := s.in >> 7
+	s.in <<= 1
+	s.out = s.out<<1 | out&1
+
+	if s.bits++; s.bits == 8 {
+		s.bits = 0
+		s.device.Receive(s.out)
+	}
+	return bit
+}
+
+func (s *byteShifter) DrivesClock() bool {
+	return false
+}
+
+func (s *byteShifter) Tick(ticks int) {
+	if s.ticker != nil {
+		s.ticker.Tick(ticks)
+	}
+}
+
+// Close closes the device if it holds resources, like the file of a logger
+func (s *byteShifter) Close() error {
+	if c, ok := s.device.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Disconnected is an unplugged cable: the Game Boy receives $FF
+type Disconnected struct{}
+
+func (Disconnected) ExchangeBit(uint8) uint8 { return 1 }
+func (Disconnected) DrivesClock() bool       { return false }
+
+// Loopback connects the output of the serial port to its input: the Game Boy receives the byte it sends
+type Loopback struct{}
+
+func (Loopback) ExchangeBit(out uint8) uint8 { return out }
+func (Loopback) DrivesClock() bool           { return false }
+
+// Logger writes the bytes sent by the Game Boy (test ROMs print their results this way).
+// The Game Boy receives $FF like with a disconnected cable.
+type Logger struct {
+	w io.Writer
+	// File opened by ParseDevice, closed with the logger
+	file *os.File
+}
+
+// NewLogger creates a logger writing to w
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{w: w}
+}
+
+func (l *Logger) Reply() uint8 {
+	return 0xFF
+}
+
+func (l *Logger) Receive(b uint8) {
+	if _, err := l.w.Write([]uint8{b}); err != nil {
+		log.Println("[WARN] serial logger:", err)
+	}
+}
+
+// Close flushes and closes the file opened by ParseDevice, the writers passed to NewLogger
+// are left open
+func (l *Logger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return errors.Join(l.file.Sync(), l.file.Close())
+}
+
+// Scripted answers the bytes of a script in order (then $FF) and records the bytes received, for tests
+type Scripted struct {
+	replies  []uint8
+	received []uint8
+}
+
+// NewScripted creates a device answering replies
+func NewScripted(replies ...uint8) *Scripted {
+	return &Scripted{replies: replies}
+}
+
+func (s *Scripted) Reply() uint8 {
+	if len(s.received) < len(s.replies) {
+		return s.replies[len(s.received)]
+	}
+	return 0xFF
+}
+
+func (s *Scripted) Receive(b uint8) {
+	s.received = append(s.received, b)
+}
+
+// Received returns the bytes sent by the Game Boy
+func (s *Scripted) Received() []uint8 {
+	return s.received
+}
+
+// ParseDevice parses the name of a built-in device: none, loopback or log[=FILE]
+// (the log is written to stdout if no file is given). The file is closed when the device is
+// closed (io.Closer).
+func ParseDevice(s string) (Device, error) {
+	name, arg, _ := strings.Cut(s, "=")
+
+	switch name {
+	case "", "none":
+		return Disconnected{}, nil
+	case "loopback":
+		return Loopback{}, nil
+	case "log":
+		if arg == "" {
+			return Bytes(NewLogger(os.Stdout)), nil
+		}
+		f, err := os.Create(arg)
+		if err != nil {
+			return nil, err
+		}
+		return Bytes(&Logger{w: f, file: f}), nil
+	default:
+		return nil, fmt.Errorf("unknown serial device %q (none, loopback, log[=FILE])", s)
+	}
+}
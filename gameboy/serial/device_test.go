@@ -0,0 +1,73 @@
+package serial
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// transfer starts a transfer with the internal clock and runs it to the end
+func transfer(port *Port, b uint8) {
+	port.Write(SBAddr, b)
+	port.Write(SCAddr, 0x81)
+	for port.SC&0x80 != 0 {
+		port.Tick(4)
+	}
+}
+
+func newTestPort(device Device) *Port {
+	port := NewPort(false)
+	port.SetDevice(device)
+	port.RequestInterrupt = func() {}
+	return port
+}
+
+func TestBytes(t *testing.T) {
+	script := NewScripted(0x12, 0x34)
+	port := newTestPort(Bytes(script))
+
+	for i, want := range []uint8{0x12, 0x34, 0xFF} {
+		transfer(port, 0xA0+uint8(i))
+		if port.SB != want {
+			t.Errorf("byte %d: SB = %02X, want %02X", i, port.SB, want)
+		}
+	}
+	if got := script.Received(); !bytes.Equal(got, []uint8{0xA0, 0xA1, 0xA2}) {
+		t.Errorf("received % X", got)
+	}
+}
+
+func TestParseDevice(t *testing.T) {
+	for _, name := range []string{"", "none", "loopback", "log"} {
+		if _, err := ParseDevice(name); err != nil {
+			t.Errorf("%q: %v", name, err)
+		}
+	}
+	if _, err := ParseDevice("cable"); err == nil {
+		t.Error("unknown device accepted")
+	}
+}
+
+func TestLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "serial.log")
+	device, err := ParseDevice("log=" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := newTestPort(device)
+	transfer(port, 'o')
+	transfer(port, 'k')
+
+	closer, ok := device.(io.Closer)
+	if !ok {
+		t.Fatal("log file device is not an io.Closer")
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "ok" {
+		t.Errorf("log = %q, %v", got, err)
+	}
+}
@@ -9,6 +9,8 @@ const (
 	SCAddr = 0xFF02
 
 	SCMask = 0x7E
+	// Bit 1 selects the clock speed on CGB
+	cgbSCMask = 0x7C
 )
 
 func (port *Port) Read(addr uint16) uint8 {
@@ -16,7 +18,7 @@ func (port *Port) Read(addr uint16) uint8 {
 	case SBAddr:
 		return port.SB
 	case SCAddr:
-		return port.SC | SCMask
+		return port.SC | port.scMask()
 	default:
 		panic("Serial: unknown addr " + strconv.FormatUint(uint64(addr), 16))
 	}
@@ -26,10 +28,26 @@ func (port *Port) Write(addr uint16, v uint8) {
 	switch addr {
 	case SBAddr:
 		port.SB = v
+		port.follow()
 	case SCAddr:
-		port.SC = v &^ SCMask
+		port.SC = v &^ port.scMask()
+
+		if port.isTransferring() && port.isMaster() {
+			if port.TransferCallback != nil {
+				port.TransferCallback(port.SB)
+			}
+		}
+		port.follow()
 
 	default:
 		panic("Serial: unknown addr " + strconv.FormatUint(uint64(addr), 16))
 	}
 }
+
+// scMask returns the unused bits of SC
+func (port *Port) scMask() uint8 {
+	if port.isCGB {
+		return cgbSCMask
+	}
+	return SCMask
+}
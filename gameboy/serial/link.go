@@ -0,0 +1,248 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/internal/netlink"
+)
+
+const (
+	// Sent by both ends when the connection is established, followed by the protocol version
+	// and the emulated model (length and name)
+	linkMagic   = "LBLINK"
+	LinkVersion = 2 // Increased when the link protocol changes
+
+	// Messages exchanged after the handshake, bits are stored in bit 0
+	msgBit       = 0x00 // Bit clocked by the Game Boy driving the clock
+	msgHeartbeat = 0x02
+	msgReply     = 0x04 // Bit sent back
+
+	// Longest wait for the bit answered by the other emulator, which answers right away
+	// unless the connection is dead
+	replyTimeout = time.Second
+)
+
+// Link is a link cable to another emulator over TCP: each bit sent by the Game Boy driving the
+// clock is answered by the other end with the upper bit of its SB, or 1 if its Game Boy is not
+// waiting for the external clock. Bits are answered as soon as they are received, so that the
+// emulation is never blocked by the other emulator.
+//
+// The connection is established again when it is lost; in the meantime the cable is unplugged
+// and the game receives $FF.
+type Link struct {
+	endpoint *netlink.Endpoint[peer]
+
+	// Serial port as seen by the emulation (FollowPort) and bits clocked by the other Game Boy,
+	// not shifted into SB yet
+	mu      sync.Mutex
+	sb      uint8
+	waiting bool
+	queue   []uint8
+
+	// Set when the port has taken a bit of the queue
+	clocked bool
+	// Timeout of the bits sent
+	replyTimer *time.Timer
+}
+
+// peer is the other end of a connection
+type peer struct {
+	model string
+	// Bits answered by the other end
+	replies chan uint8
+}
+
+type session = netlink.Session[peer]
+
+// newLink creates a link exchanging the emulated model in the handshake, then the bits
+func newLink(model string) *Link {
+	l := &Link{
+		replyTimer: time.NewTimer(replyTimeout),
+	}
+	l.endpoint = netlink.NewEndpoint(netlink.Protocol[peer]{
+		Name:      "[LINK]",
+		Handshake: func(conn net.Conn) (peer, error) { return handshake(conn, model) },
+		Serve:     l.serve,
+	})
+	l.replyTimer.Stop()
+	return l
+}
+
+// ListenLink waits for the other emulator on addr (host:port), accepting it again whenever
+// the connection is lost
+func ListenLink(addr, model string) (*Link, error) {
+	l := newLink(model)
+	if err := l.endpoint.Listen(addr); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// DialLink connects to the other emulator on addr (host:port), retrying until it is reachable
+// and whenever the connection is lost
+func DialLink(addr, model string) *Link {
+	l := newLink(model)
+	l.endpoint.Dial(addr)
+	return l
+}
+
+// Addr returns the address the link is listening on (nil if it dials the other emulator)
+func (l *Link) Addr() net.Addr {
+	return l.endpoint.Addr()
+}
+
+// Connected reports whether the cable is plugged into the other emulator
+func (l *Link) Connected() bool {
+	return l.endpoint.Connected()
+}
+
+// PeerModel returns the model emulated at the other end (empty if unplugged)
+func (l *Link) PeerModel() string {
+	if s := l.endpoint.Session(); s != nil {
+		return s.State.model
+	}
+	return ""
+}
+
+// Close unplugs the cable and stops reconnecting
+func (l *Link) Close() {
+	l.endpoint.Close()
+}
+
+// handshake exchanges the protocol version and the emulated model
+func handshake(conn net.Conn, model string) (peer, error) {
+	hello := append([]uint8(linkMagic), LinkVersion, uint8(len(model)))
+	hello = append(hello, model...)
+	if _, err := conn.Write(hello); err != nil {
+		return peer{}, err
+	}
+
+	header := make([]uint8, len(linkMagic)+2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return peer{}, err
+	}
+	if string(header[:len(linkMagic)]) != linkMagic {
+		return peer{}, errors.New("the other end is not a link cable")
+	}
+	if version := header[len(linkMagic)]; version != LinkVersion {
+		return peer{}, fmt.Errorf("link protocol version %d, want %d", version, LinkVersion)
+	}
+	peerModel := make([]uint8, header[len(linkMagic)+1])
+	if _, err := io.ReadFull(conn, peerModel); err != nil {
+		return peer{}, err
+	}
+
+	return peer{
+		model:   string(peerModel),
+		replies: make(chan uint8, 1),
+	}, nil
+}
+
+// serve plugs the cable until the connection is lost
+func (l *Link) serve(s *session) {
+	log.Printf("[LINK] connected to %s (%s)", s.RemoteAddr(), s.State.model)
+	go s.Heartbeat([]uint8{msgHeartbeat})
+	l.listen(s)
+	log.Println("[LINK] cable unplugged")
+}
+
+// FollowPort is called by the serial port whenever SB or the clock mode change
+func (l *Link) FollowPort(sb uint8, waiting bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sb, l.waiting = sb, waiting
+	if !waiting {
+		l.queue = l.queue[:0]
+	}
+}
+
+func (l *Link) DrivesClock() bool {
+	if !l.clocked {
+		l.mu.Lock()
+		l.clocked = len(l.queue) > 0
+		l.mu.Unlock()
+	}
+	return l.clocked
+}
+
+func (l *Link) ExchangeBit(out uint8) uint8 {
+	// Shift in the bit clocked by the other Game Boy, which has already been answered
+	if l.clocked {
+		l.clocked = false
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if len(l.queue) == 0 {
+			return 1
+		}
+		bit := l.queue[0]
+		l.queue = l.queue[1:]
+		l.sb = l.sb<<1 | bit
+		return bit
+	}
+
+	s := l.endpoint.Session()
+	if s == nil || s.Send([]uint8{msgBit | out&1}) != nil {
+		return 1
+	}
+
+	// Wait for the bit sent back by the other emulator
+	l.replyTimer.Reset(replyTimeout)
+	defer l.replyTimer.Stop()
+
+	select {
+	case bit := <-s.State.replies:
+		return bit
+	case <-s.Done():
+		return 1
+	case <-l.replyTimer.C:
+		log.Println("[LINK] the other emulator is not answering")
+		s.Close()
+		return 1
+	}
+}
+
+// listen receives the messages of the other end until the connection is lost
+func (l *Link) listen(s *session) {
+	buf := make([]uint8, 1)
+
+	for s.Receive(buf) == nil {
+		switch buf[0] &^ 1 {
+		case msgBit:
+			if s.Send([]uint8{msgReply | l.clock(buf[0]&1)}) != nil {
+				return
+			}
+		case msgReply:
+			select {
+			case s.State.replies <- buf[0] & 1:
+			default: // Not waiting for it
+			}
+		}
+	}
+}
+
+// clock queues the bit clocked by the other Game Boy if the port waits for the external clock,
+// returning the bit sent back
+func (l *Link) clock(bit uint8) uint8 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.waiting {
+		return 1
+	}
+
+	// SB once the bits already received are shifted in
+	sb := l.sb
+	for _, b := range l.queue {
+		sb = sb<<1 | b
+	}
+	l.queue = append(l.queue, bit)
+	return sb >> 7
+}
@@ -0,0 +1,137 @@
+package serial
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/internal/netlink/netlinktest"
+)
+
+func newTestLinks(t *testing.T) (*Link, *Link) {
+	return netlinktest.Pair(t,
+		func(addr string) (*Link, error) { return ListenLink(addr, "cgb") },
+		func(addr string) *Link { return DialLink(addr, "dmg") })
+}
+
+// linkTransfer sends b from master while slave waits for the external clock
+func linkTransfer(master, slave *Port, b, answer uint8) {
+	slave.Write(SBAddr, answer)
+	slave.Write(SCAddr, 0x80)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for slave.SC&0x80 != 0 {
+			slave.Tick(4)
+		}
+	}()
+
+	transfer(master, b)
+	<-done
+}
+
+func TestLink(t *testing.T) {
+	server, client := newTestLinks(t)
+	if server.PeerModel() != "dmg" || client.PeerModel() != "cgb" {
+		t.Errorf("peer models %q and %q", server.PeerModel(), client.PeerModel())
+	}
+
+	master, slave := newTestPort(server), newTestPort(client)
+	linkTransfer(master, slave, 0x99, 0x42)
+	if master.SB != 0x42 || slave.SB != 0x99 {
+		t.Errorf("master SB = %02X, slave SB = %02X, want 42 and 99", master.SB, slave.SB)
+	}
+
+	// Roles can be swapped
+	linkTransfer(slave, master, 0x12, 0x34)
+	if master.SB != 0x12 || slave.SB != 0x34 {
+		t.Errorf("master SB = %02X, slave SB = %02X, want 12 and 34", master.SB, slave.SB)
+	}
+}
+
+func TestLinkBothInternalClock(t *testing.T) {
+	server, client := newTestLinks(t)
+	port1, port2 := newTestPort(server), newTestPort(client)
+
+	// Neither Game Boy waits for the external clock, both receive $FF without waiting
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		transfer(port2, 0x34)
+	}()
+	transfer(port1, 0x12)
+	<-done
+
+	if port1.SB != 0xFF || port2.SB != 0xFF {
+		t.Errorf("SB = %02X and %02X, want FF", port1.SB, port2.SB)
+	}
+	if elapsed := time.Since(start); elapsed > replyTimeout/2 {
+		t.Errorf("transfers took %v", elapsed)
+	}
+	if !server.Connected() || !client.Connected() {
+		t.Error("link disconnected")
+	}
+}
+
+func TestLinkUnplugged(t *testing.T) {
+	server, client := newTestLinks(t)
+	client.Close()
+	netlinktest.WaitConnected(t, server, false)
+
+	// The game receives $FF without waiting
+	port := newTestPort(server)
+	start := time.Now()
+	transfer(port, 0x99)
+	if port.SB != 0xFF {
+		t.Errorf("SB = %02X, want FF", port.SB)
+	}
+	if elapsed := time.Since(start); elapsed > replyTimeout/2 {
+		t.Errorf("transfer took %v", elapsed)
+	}
+
+	// Another emulator can be connected
+	client = DialLink(server.Addr().String(), "dmg")
+	defer client.Close()
+	netlinktest.WaitConnected(t, server, true)
+}
+
+func TestLinkReconnect(t *testing.T) {
+	server, client := newTestLinks(t)
+	addr := server.Addr().String()
+	server.Close()
+	netlinktest.WaitConnected(t, client, false)
+
+	server, err := ListenLink(addr, "cgb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	netlinktest.WaitConnected(t, client, true)
+}
+
+func TestLinkHandshake(t *testing.T) {
+	server, err := ListenLink("127.0.0.1:0", "cgb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write(append([]uint8(linkMagic), LinkVersion+1, 0))
+
+	// The server sends its hello, then closes the connection
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatal(err)
+	}
+	if server.Connected() {
+		t.Error("connection with another protocol version accepted")
+	}
+}
@@ -1,77 +1,147 @@
 package serial
 
-import (
-	"net"
-)
-
-type LinkState int
+import "github.com/danielecanzoneri/lucky-boy/util"
 
 const (
-	Disconnected LinkState = iota
-	Connecting
-	Connected
+	// Periods of the internal clock in CPU ticks: 8192 Hz, or 262144 Hz in CGB fast mode.
+	// Since the clock is derived from the CPU clock, the rates are doubled in double speed mode.
+	slowClockPeriod = 512
+	fastClockPeriod = 16
 )
 
 type Port struct {
-	// TCP socket
-	Conn net.Conn
-	// Connection state
-	State LinkState
-
 	SB uint8
-	// Serial control (bit 7: transfer enable, bit 0: clock select)
+	// Serial control (bit 7: transfer enable, bit 1: clock speed (CGB only), bit 0: clock select)
 	SC uint8
 
-	// Counter used to determine serial clock
-	clockTimer int
+	isCGB       bool
+	doubleSpeed bool
+
+	// Counter the serial clock is derived from (ticks modulo the slow clock period)
+	clock int
 	// Exchange one bit at a time, when all bit are exchanged, set SC bit 7 to 0 and request interrupt
 	bitsTransferred int
-	// Channel where data is received from socket
-	dataChannel chan uint8
+
+	// Device plugged into the other end of the cable
+	device Device
+	// Set when the device has its own timing
+	deviceTicker ticker
+	// Set when the device answers the external clock on its own
+	follower follower
 
 	RequestInterrupt func()
+
+	// Callback called with the byte being sent when a transfer with internal clock is started
+	TransferCallback func(uint8)
+}
+
+type ticker interface {
+	Tick(ticks int)
 }
 
-func NewPort() *Port {
+func NewPort(cgb bool) *Port {
 	return &Port{
-		// Synchronous channel
-		dataChannel: make(chan uint8),
+		device: Disconnected{},
+		isCGB:  cgb,
 		// It seems that at startup actual Game Boy timer has elapsed for eight ticks (check Timer)
-		clockTimer: 512 - 8,
+		clock: 8,
 	}
 }
 
+// SetDevice plugs a device into the serial port (nil unplugs the cable)
+func (port *Port) SetDevice(device Device) {
+	if device == nil {
+		device = Disconnected{}
+	}
+	port.device = device
+	port.deviceTicker, _ = device.(ticker)
+	port.follower, _ = device.(follower)
+	port.follow()
+}
+
+// Device returns the device plugged into the serial port
+func (port *Port) Device() Device {
+	return port.device
+}
+
 func (port *Port) Tick(ticks int) {
+	if port.deviceTicker != nil {
+		port.deviceTicker.Tick(ticks)
+	}
+
 	// Serial clock runs at 8 kHz, since game boy runs at 4 MHz
-	// each serial clock happens once every 4 MHz / 8 kHz = 512 game boy ticks
+	// each serial clock happens once every 4 MHz / 8 kHz = 512 game boy ticks (16 in CGB fast mode)
 	// Note that serial clock is always running even when not transmitting data
-	port.clockTimer -= ticks
-	if port.clockTimer <= 0 {
-		// Restart master clock for the next bit
-		port.clockTimer += 512
-
-		if port.isTransferring() && port.isMaster() {
-			if port.State == Connected {
-				port.sendBit() // Send bit to slave
-
-				// Block until a bit is received back from slave
-				bitReceived := <-port.dataChannel
-				port.handleIncomingBit(bitReceived)
-			} else {
-				// Emulate disconnected cable
-				port.handleIncomingBit(1)
-			}
-		}
+	period := port.clockPeriod()
+	edge := port.clock%period+ticks >= period
+	port.clock = (port.clock + ticks) % slowClockPeriod
+
+	if edge && port.isTransferring() && port.isMaster() {
+		port.exchangeBit()
+	}
+
+	// If the device clocked a bit, immediately send back the upper bit of SB
+	if port.isSlave() && port.device.DrivesClock() {
+		port.exchangeBit()
 	}
+}
 
+// SwitchSpeed is called when the CGB switches speed: the clock restarts, since the divider
+// it is derived from is reset
+func (port *Port) SwitchSpeed(doubleSpeed bool) {
+	port.doubleSpeed = doubleSpeed
+	port.clock = 0
+}
+
+// ClockRate returns the frequency of the internal clock in Hz
+func (port *Port) ClockRate() int {
+	rate := 4194304 / port.clockPeriod()
+	if port.doubleSpeed {
+		rate *= 2
+	}
+	return rate
+}
+
+func (port *Port) clockPeriod() int {
+	if port.isFast() {
+		return fastClockPeriod
+	}
+	return slowClockPeriod
+}
+
+// exchangeBit sends bit 7 of SB to the device and shifts in the bit received
+func (port *Port) exchangeBit() {
+	port.shiftIn(port.device.ExchangeBit(util.ReadBit(port.SB, 7)))
+}
+
+// ExternalClock shifts in a bit clocked by the other end of the cable at once, if the Game Boy
+// is using the external clock, and returns the bit sent back
+func (port *Port) ExternalClock(bitIn uint8) uint8 {
+	bitOut := util.ReadBit(port.SB, 7)
 	if port.isSlave() {
-		select {
-		// If slave received a bit, immediately send back lower bit of SB
-		case bit := <-port.dataChannel:
-			port.sendBit()
-			port.handleIncomingBit(bit)
-
-		default: // Non blocking
-		}
+		port.shiftIn(bitIn)
+	}
+	return bitOut
+}
+
+func (port *Port) shiftIn(bitIn uint8) {
+	// Set bit 0 of SB
+	port.SB = (port.SB << 1) | (bitIn & 1)
+	port.bitsTransferred++
+
+	if port.bitsTransferred == 8 {
+		port.bitsTransferred = 0
+
+		// Disable transferring and request interrupt
+		util.SetBit(&port.SC, 7, 0)
+		port.RequestInterrupt()
+	}
+	port.follow()
+}
+
+// follow tells the device the content of SB and whether the Game Boy waits for the external clock
+func (port *Port) follow() {
+	if port.follower != nil {
+		port.follower.FollowPort(port.SB, port.isSlave() && port.isTransferring())
 	}
 }
@@ -0,0 +1,59 @@
+package serial
+
+import "testing"
+
+func TestClockRates(t *testing.T) {
+	for _, tt := range []struct {
+		sc          uint8
+		doubleSpeed bool
+		rate        int
+		ticks       int // CPU ticks to transfer a byte
+	}{
+		{0x81, false, 8192, 8 * 512},
+		{0x81, true, 16384, 8 * 512},
+		{0x83, false, 262144, 8 * 16},
+		{0x83, true, 524288, 8 * 16},
+	} {
+		port := newTestPort(Loopback{})
+		port.isCGB = true
+		port.SwitchSpeed(tt.doubleSpeed)
+
+		port.Write(SBAddr, 0x5A)
+		port.Write(SCAddr, tt.sc)
+		if got := port.ClockRate(); got != tt.rate {
+			t.Errorf("SC=%02X double speed %v: rate %d Hz, want %d", tt.sc, tt.doubleSpeed, got, tt.rate)
+		}
+
+		ticks := 0
+		for port.SC&0x80 != 0 {
+			port.Tick(4)
+			ticks += 4
+		}
+		if ticks != tt.ticks {
+			t.Errorf("SC=%02X double speed %v: transfer lasted %d ticks, want %d", tt.sc, tt.doubleSpeed, ticks, tt.ticks)
+		}
+		if port.SB != 0x5A {
+			t.Errorf("SC=%02X double speed %v: SB = %02X", tt.sc, tt.doubleSpeed, port.SB)
+		}
+	}
+}
+
+func TestFastClockDMG(t *testing.T) {
+	port := newTestPort(Loopback{})
+	port.Write(SCAddr, 0x83)
+
+	if sc := port.Read(SCAddr); sc != 0xFF {
+		t.Errorf("SC = %02X, want FF", sc)
+	}
+	if rate := port.ClockRate(); rate != 8192 {
+		t.Errorf("rate %d Hz, want 8192", rate)
+	}
+
+	// The CGB keeps the speed bit
+	port = newTestPort(Loopback{})
+	port.isCGB = true
+	port.Write(SCAddr, 0x01)
+	if sc := port.Read(SCAddr); sc != 0x7D {
+		t.Errorf("CGB SC = %02X, want 7D", sc)
+	}
+}
@@ -0,0 +1,21 @@
+package serial
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+
+// SaveState stores the serial registers and clock, the device plugged into the port is not part of the state
+func (port *Port) SaveState(e *savestate.Encoder) {
+	e.U8(port.SB)
+	e.U8(port.SC)
+	e.Bool(port.doubleSpeed)
+	e.Int(port.clock)
+	e.Int(port.bitsTransferred)
+}
+
+func (port *Port) LoadState(d *savestate.Decoder) {
+	d.U8(&port.SB)
+	d.U8(&port.SC)
+	d.Bool(&port.doubleSpeed)
+	d.Int(&port.clock)
+	d.Int(&port.bitsTransferred)
+	port.follow()
+}
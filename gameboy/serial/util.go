@@ -16,3 +16,8 @@ func (port *Port) isMaster() bool {
 func (port *Port) isSlave() bool {
 	return util.ReadBit(port.SC, 0) == 0
 }
+
+// isFast returns whether the CGB fast clock is selected
+func (port *Port) isFast() bool {
+	return port.isCGB && util.ReadBit(port.SC, 1) > 0
+}
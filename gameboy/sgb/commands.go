@@ -0,0 +1,312 @@
+package sgb
+
+import (
+	"encoding/binary"
+	"log"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/ppu"
+)
+
+const (
+	cmdPal01   = 0x00
+	cmdPal23   = 0x01
+	cmdPal03   = 0x02
+	cmdPal12   = 0x03
+	cmdAttrBlk = 0x04
+	cmdAttrLin = 0x05
+	cmdAttrDiv = 0x06
+	cmdAttrChr = 0x07
+	cmdSound   = 0x08
+	cmdSouTrn  = 0x09
+	cmdPalSet  = 0x0A
+	cmdPalTrn  = 0x0B
+	cmdMltReq  = 0x11
+	cmdChrTrn  = 0x13
+	cmdPctTrn  = 0x14
+	cmdAttrTrn = 0x15
+	cmdAttrSet = 0x16
+	cmdMaskEn  = 0x17
+
+	// No VRAM transfer pending
+	noTransfer  = 0xFF
+	transferLen = 0x1000
+)
+
+var commandNames = [...]string{
+	"PAL01", "PAL23", "PAL03", "PAL12", "ATTR_BLK", "ATTR_LIN", "ATTR_DIV", "ATTR_CHR",
+	"SOUND", "SOU_TRN", "PAL_SET", "PAL_TRN", "ATRC_EN", "TEST_EN", "ICON_EN", "DATA_SND",
+	"DATA_TRN", "MLT_REQ", "JUMP", "CHR_TRN", "PCT_TRN", "ATTR_TRN", "ATTR_SET", "MASK_EN",
+	"OBJ_TRN",
+}
+
+func commandName(command uint8) string {
+	if int(command) < len(commandNames) {
+		return commandNames[command]
+	}
+	return "unknown"
+}
+
+// Number of joypads selected by MLT_REQ
+var mltPlayers = [4]uint8{1, 2, 1, 4}
+
+func (s *SGB) execute(data []uint8) {
+	command := data[0] >> 3
+
+	switch command {
+	case cmdPal01:
+		s.setPalettes(0, 1, data)
+	case cmdPal23:
+		s.setPalettes(2, 3, data)
+	case cmdPal03:
+		s.setPalettes(0, 3, data)
+	case cmdPal12:
+		s.setPalettes(1, 2, data)
+
+	case cmdAttrBlk:
+		s.attrBlock(data)
+	case cmdAttrLin:
+		s.attrLine(data)
+	case cmdAttrDiv:
+		s.attrDivide(data)
+	case cmdAttrChr:
+		s.attrCharacter(data)
+
+	case cmdPalSet:
+		s.paletteSet(data)
+	case cmdAttrSet:
+		s.applyAttrFile(data[1] & 0x3F)
+		if data[1]&0x40 != 0 {
+			s.mask = maskNone
+		}
+
+	case cmdPalTrn, cmdChrTrn, cmdPctTrn, cmdAttrTrn:
+		// Data is read from the next frame
+		s.transfer = command
+		s.transferArg = data[1]
+
+	case cmdMltReq:
+		s.players = mltPlayers[data[1]&3]
+		s.player = 0
+
+	case cmdMaskEn:
+		s.mask = data[1] & 3
+
+	case cmdSound, cmdSouTrn:
+		log.Printf("[SGB] %s not emulated: % X", commandName(command), data[:packetLen])
+
+	default:
+		log.Printf("[WARN] unsupported SGB command %s ($%02X)", commandName(command), command)
+	}
+}
+
+func color(data []uint8) uint16 {
+	return binary.LittleEndian.Uint16(data) & 0x7FFF
+}
+
+// setPalettes sets colors 1-3 of two palettes and color 0 of all palettes
+func (s *SGB) setPalettes(a, b int, data []uint8) {
+	color0 := color(data[1:])
+	for i := range s.palettes {
+		s.palettes[i][0] = color0
+	}
+
+	for i := range 3 {
+		s.palettes[a][i+1] = color(data[3+2*i:])
+		s.palettes[b][i+1] = color(data[9+2*i:])
+	}
+}
+
+// paletteSet copies 4 system palettes (loaded by PAL_TRN) to the palettes in use
+func (s *SGB) paletteSet(data []uint8) {
+	for i := range s.palettes {
+		n := binary.LittleEndian.Uint16(data[1+2*i:]) % systemPalettes
+		s.palettes[i] = s.systemPalettes[n]
+	}
+
+	// Bit 7 applies an attribute file, bit 6 cancels the mask
+	flags := data[9]
+	if flags&0x80 != 0 {
+		s.applyAttrFile(flags & 0x3F)
+	}
+	if flags&0x40 != 0 {
+		s.mask = maskNone
+	}
+}
+
+func (s *SGB) setAttribute(x, y int, palette uint8) {
+	if x < attrWidth && y < attrHeight {
+		s.attributes[y*attrWidth+x] = palette & 3
+	}
+}
+
+// attrBlock assigns palettes to the inside, the border and the outside of up to 18 rectangles
+func (s *SGB) attrBlock(data []uint8) {
+	count := min(int(data[1]), (len(data)-2)/6)
+
+	for i := range count {
+		set := data[2+6*i : 8+6*i]
+		control := set[0] & 7
+		inside, border, outside := set[1]&3, (set[1]>>2)&3, (set[1]>>4)&3
+		x1, y1 := int(set[2]&0x1F), int(set[3]&0x1F)
+		x2, y2 := int(set[4]&0x1F), int(set[5]&0x1F)
+
+		// If only the inside or the outside is changed, the border is changed too
+		switch control {
+		case 1:
+			control |= 2
+			border = inside
+		case 4:
+			control |= 2
+			border = outside
+		}
+
+		for y := range attrHeight {
+			for x := range attrWidth {
+				switch {
+				case x > x1 && x < x2 && y > y1 && y < y2:
+					if control&1 != 0 {
+						s.setAttribute(x, y, inside)
+					}
+				case x >= x1 && x <= x2 && y >= y1 && y <= y2:
+					if control&2 != 0 {
+						s.setAttribute(x, y, border)
+					}
+				default:
+					if control&4 != 0 {
+						s.setAttribute(x, y, outside)
+					}
+				}
+			}
+		}
+	}
+}
+
+// attrLine assigns palettes to whole rows or columns
+func (s *SGB) attrLine(data []uint8) {
+	count := min(int(data[1]), len(data)-2)
+
+	for _, b := range data[2 : 2+count] {
+		line := int(b & 0x1F)
+		palette := (b >> 5) & 3
+
+		if b&0x80 != 0 { // Horizontal line
+			for x := range attrWidth {
+				s.setAttribute(x, line, palette)
+			}
+		} else {
+			for y := range attrHeight {
+				s.setAttribute(line, y, palette)
+			}
+		}
+	}
+}
+
+// attrDivide divides the screen in two halves by a row or a column
+func (s *SGB) attrDivide(data []uint8) {
+	after, before, on := data[1]&3, (data[1]>>2)&3, (data[1]>>4)&3
+	horizontal := data[1]&0x40 != 0
+	coordinate := int(data[2] & 0x1F)
+
+	for y := range attrHeight {
+		for x := range attrWidth {
+			pos := x
+			if horizontal {
+				pos = y
+			}
+
+			switch {
+			case pos < coordinate:
+				s.setAttribute(x, y, before)
+			case pos == coordinate:
+				s.setAttribute(x, y, on)
+			default:
+				s.setAttribute(x, y, after)
+			}
+		}
+	}
+}
+
+// attrCharacter assigns palettes to consecutive blocks, 2 bits per block
+func (s *SGB) attrCharacter(data []uint8) {
+	x, y := int(data[1]&0x1F), int(data[2]&0x1F)
+	count := int(binary.LittleEndian.Uint16(data[3:]))
+	count = min(count, attrWidth*attrHeight, (len(data)-6)*4)
+	vertical := data[5]&1 != 0
+
+	for i := range count {
+		palette := data[6+i/4] >> (6 - 2*(i%4))
+		s.setAttribute(x, y, palette)
+
+		if vertical {
+			if y++; y == attrHeight {
+				y = 0
+				x++
+			}
+		} else {
+			if x++; x == attrWidth {
+				x = 0
+				y++
+			}
+		}
+	}
+}
+
+func (s *SGB) applyAttrFile(n uint8) {
+	if int(n) >= attrFiles {
+		return
+	}
+
+	file := &s.attrFiles[n]
+	for i := range s.attributes {
+		s.attributes[i] = file[i/4] >> (6 - 2*(i%4)) & 3
+	}
+}
+
+// vramTransfer reads 4 KiB from the frame: the game shows the data as 256 tiles,
+// 20 per row starting from the top left corner, with the identity palette
+func (s *SGB) vramTransfer(frame *[ppu.FrameHeight][ppu.FrameWidth]uint16) {
+	var data [transferLen]uint8
+	for tile := range transferLen / 16 {
+		tx, ty := (tile%attrWidth)*8, (tile/attrWidth)*8
+		for row := range 8 {
+			var low, high uint8
+			for col := range 8 {
+				shade := frame[ty+row][tx+col]
+				low |= uint8(shade&1) << (7 - col)
+				high |= uint8(shade>>1&1) << (7 - col)
+			}
+			data[tile*16+row*2] = low
+			data[tile*16+row*2+1] = high
+		}
+	}
+
+	switch s.transfer {
+	case cmdPalTrn:
+		for i := range systemPalettes * 4 {
+			s.systemPalettes[i/4][i%4] = color(data[2*i:])
+		}
+
+	case cmdChrTrn:
+		// Bit 0 selects tiles $00-$7F or $80-$FF
+		base := int(s.transferArg&1) * borderTiles / 2
+		for i := range borderTiles / 2 {
+			copy(s.borderTiles[base+i][:], data[i*borderTileLen:])
+		}
+
+	case cmdPctTrn:
+		// 32x32 tilemap (only 28 rows are shown) followed by palettes 4-7
+		for i := range borderMapLen {
+			s.borderMap[i] = binary.LittleEndian.Uint16(data[2*i:])
+		}
+		for i := range borderPalettes * 16 {
+			s.borderPalettes[i/16][i%16] = color(data[0x800+2*i:])
+		}
+
+	case cmdAttrTrn:
+		for i := range attrFiles {
+			copy(s.attrFiles[i][:], data[i*attrFileLen:])
+		}
+	}
+
+	s.transfer = noTransfer
+}
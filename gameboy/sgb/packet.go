@@ -0,0 +1,88 @@
+package sgb
+
+const (
+	packetLen  = 16
+	maxPackets = 7
+)
+
+// P14 and P15 lines (bits 4-5 of P1)
+const (
+	linesReset = 0 // Both low: start of a transfer
+	linesOne   = 1 // P15 low
+	linesZero  = 2 // P14 low
+	linesIdle  = 3 // Both high: end of a pulse
+)
+
+// WriteP1 receives the values written to P1 ($FF00).
+//
+// Packets are sent bit by bit, least significant bit first: a pulse on P14 (P1 = $20) is a 0,
+// a pulse on P15 (P1 = $10) is a 1 and each pulse ends writing $30. A transfer starts with a
+// reset pulse (both lines low) and each packet of 16 bytes is followed by a 0 stop bit.
+// The first byte of a command is the command number * 8 + the number of packets (1-7).
+func (s *SGB) WriteP1(v uint8) {
+	lines := (v >> 4) & 3
+	prev := s.lastP1
+	s.lastP1 = lines
+
+	switch lines {
+	case linesReset:
+		s.receiving = true
+		s.pulseEnded = false
+		s.bits = 0
+		s.packet = [packetLen]uint8{}
+
+	case linesIdle:
+		s.pulseEnded = true
+
+		// Reading the buttons and releasing the lines selects the next joypad
+		if prev == linesOne && !s.receiving && s.players > 1 {
+			s.player = (s.player + 1) % s.players
+		}
+
+	case linesOne, linesZero:
+		if !s.receiving || !s.pulseEnded {
+			return
+		}
+		s.pulseEnded = false
+
+		var bit uint8
+		if lines == linesOne {
+			bit = 1
+		}
+
+		if s.bits == packetLen*8 {
+			// Stop bit, the packet is discarded if it is not 0
+			s.receiving = false
+			if bit == 0 {
+				s.receivePacket()
+			}
+			return
+		}
+
+		s.packet[s.bits/8] |= bit << (s.bits % 8)
+		s.bits++
+	}
+}
+
+func (s *SGB) receivePacket() {
+	if s.expected == 0 {
+		length := s.packet[0] & 7
+		if length == 0 {
+			return
+		}
+		s.expected = length
+		s.received = 0
+	}
+
+	copy(s.data[int(s.received)*packetLen:], s.packet[:])
+	s.received++
+	if s.received < s.expected {
+		return
+	}
+
+	data := s.data[:int(s.expected)*packetLen]
+	s.expected = 0
+	if s.enabled {
+		s.execute(data)
+	}
+}
@@ -0,0 +1,193 @@
+// Package sgb emulates the Super Game Boy: command packets sent through the joypad register,
+// screen colorization, the 256x224 border and the multiplayer joypads
+package sgb
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/ppu"
+
+const (
+	ScreenWidth  = 256
+	ScreenHeight = 224
+
+	// Position of the Game Boy screen inside the SGB screen
+	ScreenX = (ScreenWidth - ppu.FrameWidth) / 2
+	ScreenY = (ScreenHeight - ppu.FrameHeight) / 2
+
+	// Palettes are assigned to blocks of 8x8 pixels
+	attrWidth  = ppu.FrameWidth / 8
+	attrHeight = ppu.FrameHeight / 8
+
+	systemPalettes = 512
+	attrFiles      = 45
+	attrFileLen    = attrWidth * attrHeight / 4 // 2 bits per block
+
+	// Border made of 32x28 tiles with 4 bit colors (SNES format)
+	borderTiles    = 256
+	borderTileLen  = 32
+	borderMapWidth = ScreenWidth / 8
+	borderMapLen   = borderMapWidth * ScreenHeight / 8
+	borderPalettes = 4 // SNES palettes 4-7
+)
+
+// Screen masks set by MASK_EN
+const (
+	maskNone   uint8 = iota
+	maskFreeze       // Keep showing the last frame
+	maskBlack
+	maskColor0 // Fill the screen with color 0
+)
+
+// Default colors of the palettes before the game sends any command
+var defaultPalette = [4]uint16{0x67BF, 0x265B, 0x10B5, 0x2866}
+
+// Frame is the SGB screen: the Game Boy screen surrounded by the border, colors are RGB555
+type Frame = [ScreenHeight][ScreenWidth]uint16
+
+type SGB struct {
+	// Commands are executed only for games declaring SGB support in the header
+	enabled bool
+
+	// Packet reception
+	lastP1     uint8 // P14 and P15 lines of the last write
+	receiving  bool
+	pulseEnded bool // A bit can be received only after the lines are released
+	bits       int  // Bits of the current packet received
+	packet     [packetLen]uint8
+	data       [maxPackets * packetLen]uint8 // Packets of the current command
+	received   uint8
+	expected   uint8
+
+	// Multiplayer (MLT_REQ)
+	players uint8
+	player  uint8
+
+	palettes       [4][4]uint16
+	systemPalettes [systemPalettes][4]uint16
+	attributes     [attrWidth * attrHeight]uint8 // Palette of each 8x8 block
+	attrFiles      [attrFiles][attrFileLen]uint8
+	mask           uint8
+
+	borderTiles    [borderTiles][borderTileLen]uint8
+	borderMap      [borderMapLen]uint16
+	borderPalettes [borderPalettes][16]uint16
+
+	// VRAM transfer command waiting for the next frame
+	transfer    uint8
+	transferArg uint8
+
+	// Last frame received from the PPU (shades 0-3)
+	screen [ppu.FrameHeight][ppu.FrameWidth]uint16
+	output *Frame
+}
+
+// New creates a Super Game Boy, enabled reports if the game supports the SGB functions
+// (otherwise the packets are ignored and the game is shown with the default palette)
+func New(enabled bool) *SGB {
+	s := &SGB{
+		enabled:  enabled,
+		players:  1,
+		transfer: noTransfer,
+		output:   new(Frame),
+	}
+	for i := range s.palettes {
+		s.palettes[i] = defaultPalette
+	}
+	s.render()
+
+	return s
+}
+
+// Player returns the joypad currently selected (0-3)
+func (s *SGB) Player() uint8 {
+	return s.player
+}
+
+// GetFrame returns the last frame with the border
+func (s *SGB) GetFrame() *Frame {
+	return s.output
+}
+
+// FrameCompleted receives the frame just completed by the PPU, executing the pending VRAM
+// transfer and updating the SGB screen
+func (s *SGB) FrameCompleted(frame *[ppu.FrameHeight][ppu.FrameWidth]uint16) {
+	if s.transfer != noTransfer {
+		s.vramTransfer(frame)
+	}
+
+	if s.mask == maskNone {
+		s.screen = *frame
+	}
+	s.render()
+}
+
+func (s *SGB) render() {
+	out := new(Frame)
+	prev := s.output
+	backdrop := s.palettes[0][0]
+
+	for y := range ScreenHeight {
+		for x := range ScreenWidth {
+			out[y][x] = backdrop
+		}
+	}
+
+	// Game Boy screen
+	for y := range ppu.FrameHeight {
+		for x := range ppu.FrameWidth {
+			var c uint16
+			switch s.mask {
+			case maskFreeze:
+				c = prev[ScreenY+y][ScreenX+x]
+			case maskBlack:
+				c = 0
+			case maskColor0:
+				c = backdrop
+			default:
+				// Color 0 of palette 0 is shared by all the palettes
+				c = backdrop
+				if shade := s.screen[y][x] & 3; shade != 0 {
+					c = s.palettes[s.attributes[(y/8)*attrWidth+x/8]][shade]
+				}
+			}
+			out[ScreenY+y][ScreenX+x] = c
+		}
+	}
+
+	// Border, in front of the screen (color 0 is transparent)
+	for ty := range ScreenHeight / 8 {
+		for tx := range borderMapWidth {
+			entry := s.borderMap[ty*borderMapWidth+tx]
+			tile := &s.borderTiles[entry&0xFF]
+			palette := &s.borderPalettes[(entry>>10)&3]
+			xFlip := entry&0x4000 != 0
+			yFlip := entry&0x8000 != 0
+
+			for row := range 8 {
+				for col := range 8 {
+					r, c := row, col
+					if yFlip {
+						r = 7 - row
+					}
+					if xFlip {
+						c = 7 - col
+					}
+					if color := tilePixel(tile, r, c); color != 0 {
+						out[ty*8+row][tx*8+col] = palette[color]
+					}
+				}
+			}
+		}
+	}
+
+	s.output = out
+}
+
+// tilePixel returns the color of a pixel of a SNES 4bpp tile: bitplanes 0 and 1
+// are interleaved in the first 16 bytes, bitplanes 2 and 3 in the last 16 bytes
+func tilePixel(tile *[borderTileLen]uint8, row, col int) uint8 {
+	bit := 7 - col
+	var color uint8
+	for plane, offset := range [4]int{2 * row, 2*row + 1, 16 + 2*row, 17 + 2*row} {
+		color |= (tile[offset] >> bit & 1) << plane
+	}
+	return color
+}
@@ -0,0 +1,357 @@
+package sgb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/ppu"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+)
+
+type testFrame = [ppu.FrameHeight][ppu.FrameWidth]uint16
+
+// send transfers the command to the SGB through P1, padding it to whole packets
+func send(s *SGB, command ...uint8) {
+	packets := int(command[0] & 7)
+	data := make([]uint8, packets*packetLen)
+	copy(data, command)
+
+	for p := range packets {
+		s.WriteP1(0x00) // Reset pulse
+		s.WriteP1(0x30)
+		for _, b := range data[p*packetLen : (p+1)*packetLen] {
+			for bit := range 8 {
+				if b>>bit&1 == 1 {
+					s.WriteP1(0x10)
+				} else {
+					s.WriteP1(0x20)
+				}
+				s.WriteP1(0x30)
+			}
+		}
+		s.WriteP1(0x20) // Stop bit
+		s.WriteP1(0x30)
+	}
+}
+
+// transferFrame builds a frame showing data as tiles, like games do for VRAM transfers
+func transferFrame(data []uint8) *testFrame {
+	frame := new(testFrame)
+	for tile := range transferLen / 16 {
+		tx, ty := (tile%attrWidth)*8, (tile/attrWidth)*8
+		for row := range 8 {
+			low, high := data[tile*16+row*2], data[tile*16+row*2+1]
+			for col := range 8 {
+				bit := 7 - col
+				frame[ty+row][tx+col] = uint16(low>>bit&1 | (high>>bit&1)<<1)
+			}
+		}
+	}
+	return frame
+}
+
+// screenColor returns the color of a pixel of the Game Boy screen
+func screenColor(s *SGB, x, y int) uint16 {
+	return s.GetFrame()[ScreenY+y][ScreenX+x]
+}
+
+func TestPalettes(t *testing.T) {
+	s := New(true)
+
+	// PAL12: color 0, palette 1 colors 1-3, palette 2 colors 1-3
+	send(s, cmdPal12<<3|1,
+		0x1F, 0x00,
+		0x01, 0x00, 0x02, 0x00, 0x03, 0x00,
+		0x11, 0x00, 0x12, 0x00, 0x13, 0x00)
+
+	for i := range s.palettes {
+		if s.palettes[i][0] != 0x001F {
+			t.Errorf("palette %d color 0 = $%04X, want $001F", i, s.palettes[i][0])
+		}
+	}
+	if s.palettes[1] != [4]uint16{0x1F, 1, 2, 3} {
+		t.Errorf("palette 1 = %04X", s.palettes[1])
+	}
+	if s.palettes[2] != [4]uint16{0x1F, 0x11, 0x12, 0x13} {
+		t.Errorf("palette 2 = %04X", s.palettes[2])
+	}
+	if s.palettes[0] != [4]uint16{0x1F, defaultPalette[1], defaultPalette[2], defaultPalette[3]} {
+		t.Errorf("palette 0 = %04X, colors 1-3 should be unchanged", s.palettes[0])
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	s := New(false)
+	send(s, cmdMaskEn<<3|1, maskBlack)
+
+	if s.mask != maskNone {
+		t.Error("packets should be ignored for games without SGB support")
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []uint8
+		want    map[[2]int]uint8 // Block coordinates -> palette
+	}{
+		{
+			name: "ATTR_BLK",
+			// Inside 1, border 2, outside 3 of the rectangle (2,2)-(6,5)
+			command: []uint8{cmdAttrBlk<<3 | 1, 1, 7, 3<<4 | 2<<2 | 1, 2, 2, 6, 5},
+			want: map[[2]int]uint8{
+				{3, 3}: 1, {2, 2}: 2, {6, 4}: 2, {4, 5}: 2, {0, 0}: 3, {7, 3}: 3, {19, 17}: 3,
+			},
+		},
+		{
+			name: "ATTR_BLK inside only",
+			// The border takes the inside palette
+			command: []uint8{cmdAttrBlk<<3 | 1, 1, 1, 2, 2, 2, 6, 5},
+			want:    map[[2]int]uint8{{3, 3}: 2, {2, 2}: 2, {0, 0}: 0},
+		},
+		{
+			name: "ATTR_LIN",
+			// Row 4 palette 2, column 7 palette 3
+			command: []uint8{cmdAttrLin<<3 | 1, 2, 0x80 | 2<<5 | 4, 3<<5 | 7},
+			want:    map[[2]int]uint8{{0, 4}: 2, {19, 4}: 2, {7, 0}: 3, {7, 4}: 3, {0, 0}: 0},
+		},
+		{
+			name: "ATTR_DIV",
+			// Rows above 9 palette 1, row 9 palette 2, below palette 3
+			command: []uint8{cmdAttrDiv<<3 | 1, 0x40 | 2<<4 | 1<<2 | 3, 9},
+			want:    map[[2]int]uint8{{5, 8}: 1, {5, 9}: 2, {5, 10}: 3},
+		},
+		{
+			name: "ATTR_CHR",
+			// 6 blocks from (18,0) left to right: wraps to the next row
+			command: []uint8{cmdAttrChr<<3 | 1, 18, 0, 6, 0, 0, 0b01_10_11_01, 0b10_11_00_00},
+			want:    map[[2]int]uint8{{18, 0}: 1, {19, 0}: 2, {0, 1}: 3, {1, 1}: 1, {2, 1}: 2, {3, 1}: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(true)
+			send(s, tt.command...)
+
+			for pos, want := range tt.want {
+				if got := s.attributes[pos[1]*attrWidth+pos[0]]; got != want {
+					t.Errorf("block %v palette = %d, want %d", pos, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestColorization(t *testing.T) {
+	s := New(true)
+	send(s, cmdPal23<<3|1,
+		0x00, 0x7C,
+		0x01, 0x00, 0x02, 0x00, 0x03, 0x00,
+		0x04, 0x00, 0x05, 0x00, 0x06, 0x00)
+	// Right half palette 3
+	send(s, cmdAttrDiv<<3|1, 3<<4|0<<2|3, 10)
+
+	frame := new(testFrame)
+	frame[0][0] = 3
+	frame[0][100] = 2
+	frame[0][101] = 0
+	s.FrameCompleted(frame)
+
+	for _, tt := range []struct {
+		x    int
+		want uint16
+	}{
+		{0, defaultPalette[3]}, // Palette 0
+		{100, 0x05},            // Palette 3
+		{101, 0x7C00},          // Color 0 is shared
+	} {
+		if got := screenColor(s, tt.x, 0); got != tt.want {
+			t.Errorf("pixel %d = $%04X, want $%04X", tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestPaletteTransfer(t *testing.T) {
+	s := New(true)
+
+	// System palette n has colors n, n+1, n+2, n+3
+	data := make([]uint8, transferLen)
+	for i := range systemPalettes * 4 {
+		data[2*i] = uint8(i)
+		data[2*i+1] = uint8(i >> 8)
+	}
+
+	send(s, cmdPalTrn<<3|1)
+	s.FrameCompleted(transferFrame(data))
+
+	// PAL_SET with palettes 0, 1, 2, 300, cancelling the mask
+	send(s, cmdMaskEn<<3|1, maskBlack)
+	send(s, cmdPalSet<<3|1, 0, 0, 1, 0, 2, 0, 0x2C, 0x01, 0x40)
+
+	if s.mask != maskNone {
+		t.Error("PAL_SET should cancel the mask")
+	}
+	if want := [4]uint16{1200, 1201, 1202, 1203}; s.palettes[3] != want {
+		t.Errorf("palette 3 = %d, want %d", s.palettes[3], want)
+	}
+}
+
+func TestAttributeFiles(t *testing.T) {
+	s := New(true)
+
+	// Attribute file 2 assigns palette 2 to every block
+	data := make([]uint8, transferLen)
+	for i := range attrFileLen {
+		data[2*attrFileLen+i] = 0xAA
+	}
+
+	send(s, cmdAttrTrn<<3|1)
+	s.FrameCompleted(transferFrame(data))
+	send(s, cmdAttrSet<<3|1, 2)
+
+	for i, palette := range s.attributes {
+		if palette != 2 {
+			t.Fatalf("block %d palette = %d, want 2", i, palette)
+		}
+	}
+}
+
+func TestBorder(t *testing.T) {
+	s := New(true)
+
+	// Tile $81: color 5 in the top left pixel, color 15 in the bottom right
+	tiles := make([]uint8, transferLen)
+	tile := tiles[1*borderTileLen:]
+	tile[0] = 0x80  // Plane 0
+	tile[16] = 0x80 // Plane 2
+	for _, offset := range []int{14, 15, 30, 31} {
+		tile[offset] = 0x01
+	}
+	send(s, cmdChrTrn<<3|1, 1)
+	s.FrameCompleted(transferFrame(tiles))
+
+	// Tile $81 with palette 5 at (1,2), X flipped at (3,0)
+	picture := make([]uint8, transferLen)
+	picture[2*(2*borderMapWidth+1)] = 0x81
+	picture[2*(2*borderMapWidth+1)+1] = 5 << 2
+	picture[2*3] = 0x81
+	picture[2*3+1] = 4<<2 | 0x40
+	for i := range 16 {
+		picture[0x800+32+2*i] = uint8(0x40 + i) // Palette 5
+		picture[0x800+2*i] = uint8(0x20 + i)    // Palette 4
+	}
+	send(s, cmdPctTrn<<3|1)
+	s.FrameCompleted(transferFrame(picture))
+
+	out := s.GetFrame()
+	for _, tt := range []struct {
+		x, y int
+		want uint16
+	}{
+		{8, 16, 0x45},             // Top left pixel, palette 5
+		{15, 23, 0x4F},            // Bottom right pixel
+		{9, 16, s.palettes[0][0]}, // Transparent
+		{31, 0, 0x25},             // X flipped, palette 4
+		{ScreenX, ScreenY, s.palettes[0][0]},
+	} {
+		if got := out[tt.y][tt.x]; got != tt.want {
+			t.Errorf("pixel (%d,%d) = $%04X, want $%04X", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	s := New(true)
+	frame := new(testFrame)
+	frame[0][0] = 3
+	s.FrameCompleted(frame)
+
+	send(s, cmdMaskEn<<3|1, maskFreeze)
+	s.FrameCompleted(new(testFrame))
+	if got := screenColor(s, 0, 0); got != defaultPalette[3] {
+		t.Errorf("frozen screen pixel = $%04X, want $%04X", got, defaultPalette[3])
+	}
+
+	send(s, cmdMaskEn<<3|1, maskBlack)
+	s.FrameCompleted(frame)
+	if got := screenColor(s, 0, 0); got != 0 {
+		t.Errorf("black screen pixel = $%04X", got)
+	}
+
+	send(s, cmdMaskEn<<3|1, maskNone)
+	s.FrameCompleted(new(testFrame))
+	if got := screenColor(s, 0, 0); got != defaultPalette[0] {
+		t.Errorf("pixel = $%04X, want $%04X", got, defaultPalette[0])
+	}
+}
+
+func TestMultiplayer(t *testing.T) {
+	s := New(true)
+	send(s, cmdMltReq<<3|1, 3)
+
+	// Reading the buttons and releasing the lines switches joypad
+	for _, want := range []uint8{0, 1, 2, 3, 0} {
+		if s.Player() != want {
+			t.Fatalf("player = %d, want %d", s.Player(), want)
+		}
+		s.WriteP1(0x20)
+		s.WriteP1(0x10)
+		s.WriteP1(0x30)
+	}
+
+	send(s, cmdMltReq<<3|1, 0)
+	s.WriteP1(0x10)
+	s.WriteP1(0x30)
+	if s.Player() != 0 {
+		t.Errorf("player = %d in single player mode", s.Player())
+	}
+}
+
+func TestPacketStopBit(t *testing.T) {
+	s := New(true)
+
+	s.WriteP1(0x00)
+	s.WriteP1(0x30)
+	for range packetLen * 8 {
+		s.WriteP1(0x10) // 1 bits: MASK_EN (0xBF >> 3 = 0x17) with 7 packets
+		s.WriteP1(0x30)
+	}
+	s.WriteP1(0x10) // Wrong stop bit
+	s.WriteP1(0x30)
+
+	if s.expected != 0 {
+		t.Error("packet with wrong stop bit should be discarded")
+	}
+}
+
+func TestState(t *testing.T) {
+	s := New(true)
+	send(s, cmdPal01<<3|1, 0x1F, 0x00, 0x01, 0x00)
+	send(s, cmdAttrDiv<<3|1, 1, 10)
+	send(s, cmdMltReq<<3|1, 1)
+	frame := new(testFrame)
+	frame[5][5] = 1
+	s.FrameCompleted(frame)
+
+	var buf bytes.Buffer
+	e := savestate.NewEncoder(&buf)
+	s.SaveState(e)
+	if err := e.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := New(true)
+	d := savestate.NewDecoder(&buf)
+	restored.LoadState(d)
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.palettes != s.palettes || restored.attributes != s.attributes || restored.players != 2 {
+		t.Error("restored state differs")
+	}
+	if *restored.GetFrame() != *s.GetFrame() {
+		t.Error("restored frame differs")
+	}
+}
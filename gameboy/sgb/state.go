@@ -0,0 +1,92 @@
+package sgb
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+
+func (s *SGB) SaveState(e *savestate.Encoder) {
+	e.U8(s.lastP1)
+	e.Bool(s.receiving)
+	e.Bool(s.pulseEnded)
+	e.Int(s.bits)
+	e.U8s(s.packet[:])
+	e.U8s(s.data[:])
+	e.U8(s.received)
+	e.U8(s.expected)
+
+	e.U8(s.players)
+	e.U8(s.player)
+
+	for i := range s.palettes {
+		e.U16s(s.palettes[i][:])
+	}
+	for i := range s.systemPalettes {
+		e.U16s(s.systemPalettes[i][:])
+	}
+	e.U8s(s.attributes[:])
+	for i := range s.attrFiles {
+		e.U8s(s.attrFiles[i][:])
+	}
+	e.U8(s.mask)
+
+	for i := range s.borderTiles {
+		e.U8s(s.borderTiles[i][:])
+	}
+	e.U16s(s.borderMap[:])
+	for i := range s.borderPalettes {
+		e.U16s(s.borderPalettes[i][:])
+	}
+
+	e.U8(s.transfer)
+	e.U8(s.transferArg)
+	for y := range s.screen {
+		e.U16s(s.screen[y][:])
+	}
+}
+
+func (s *SGB) LoadState(d *savestate.Decoder) {
+	d.U8(&s.lastP1)
+	d.Bool(&s.receiving)
+	d.Bool(&s.pulseEnded)
+	d.Int(&s.bits)
+	d.U8s(s.packet[:])
+	d.U8s(s.data[:])
+	d.U8(&s.received)
+	d.U8(&s.expected)
+
+	d.U8(&s.players)
+	d.U8(&s.player)
+
+	for i := range s.palettes {
+		d.U16s(s.palettes[i][:])
+	}
+	for i := range s.systemPalettes {
+		d.U16s(s.systemPalettes[i][:])
+	}
+	d.U8s(s.attributes[:])
+	for i := range s.attrFiles {
+		d.U8s(s.attrFiles[i][:])
+	}
+	d.U8(&s.mask)
+
+	for i := range s.borderTiles {
+		d.U8s(s.borderTiles[i][:])
+	}
+	d.U16s(s.borderMap[:])
+	for i := range s.borderPalettes {
+		d.U16s(s.borderPalettes[i][:])
+	}
+
+	d.U8(&s.transfer)
+	d.U8(&s.transferArg)
+	for y := range s.screen {
+		d.U16s(s.screen[y][:])
+	}
+
+	// A frozen screen is lost, the last frame is shown instead
+	if s.mask == maskFreeze {
+		s.mask = maskNone
+		s.render()
+		s.mask = maskFreeze
+	} else {
+		s.render()
+	}
+}
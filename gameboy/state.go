@@ -0,0 +1,163 @@
+package gameboy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+)
+
+// Save state file layout:
+//
+//	offset  size    desc
+//	0       4       magic "LBST"
+//	4       4       format version
+//	8       n       game title (length prefixed)
+//	        1       header checksum of the game
+//	        2       global checksum of the game
+//	        1       emulated model
+//	        n       components state (length prefixed)
+//	        4       CRC32 of components state
+const (
+	stateMagic   = "LBST"
+	StateVersion = 7 // Increased when the layout of the components state changes
+)
+
+var (
+	ErrInvalidState     = errors.New("invalid save state")
+	ErrStateVersion     = errors.New("unsupported save state version")
+	ErrStateWrongGame   = errors.New("save state belongs to a different game")
+	ErrStateNoCartridge = errors.New("no game loaded")
+)
+
+// SaveState writes the state of the whole machine to w
+func (gb *GameBoy) SaveState(w io.Writer) error {
+	if gb.Memory == nil || gb.Memory.Cartridge == nil {
+		return ErrStateNoCartridge
+	}
+	header := gb.Memory.Cartridge.Header()
+
+	// Serialize components
+	var payload bytes.Buffer
+	p := savestate.NewEncoder(&payload)
+	gb.CPU.SaveState(p)
+	gb.Memory.SaveState(p)
+	gb.PPU.SaveState(p)
+	gb.APU.SaveState(p)
+	gb.Timer.SaveState(p)
+	gb.SerialPort.SaveState(p)
+	gb.Infrared.SaveState(p)
+	gb.Joypad.SaveState(p)
+	gb.Memory.Cartridge.SaveState(p)
+	if gb.SGB != nil {
+		gb.SGB.SaveState(p)
+	}
+	if err := p.Err(); err != nil {
+		return err
+	}
+
+	e := savestate.NewEncoder(w)
+	e.U8s([]uint8(stateMagic))
+	e.U32(StateVersion)
+	e.String(header.Title)
+	e.U8(header.HeaderChecksum)
+	e.U16(header.GlobalChecksum)
+	e.U8(uint8(gb.EmulationModel))
+	e.Bytes(payload.Bytes())
+	e.U32(crc32.ChecksumIEEE(payload.Bytes()))
+	return e.Err()
+}
+
+// LoadState restores a state written by SaveState. The state must belong to the game currently loaded.
+// The state is validated before being applied, so if an error is returned the machine is left untouched.
+func (gb *GameBoy) LoadState(r io.Reader) error {
+	if gb.Memory == nil || gb.Memory.Cartridge == nil {
+		return ErrStateNoCartridge
+	}
+	header := gb.Memory.Cartridge.Header()
+
+	d := savestate.NewDecoder(r)
+	magic := make([]uint8, len(stateMagic))
+	d.U8s(magic)
+	if d.Err() != nil || string(magic) != stateMagic {
+		return ErrInvalidState
+	}
+
+	var version uint32
+	d.U32(&version)
+	if d.Err() == nil && version != StateVersion {
+		return fmt.Errorf("%w: %d", ErrStateVersion, version)
+	}
+
+	var (
+		headerChecksum uint8
+		globalChecksum uint16
+		model          uint8
+	)
+	title := d.String()
+	d.U8(&headerChecksum)
+	d.U16(&globalChecksum)
+	d.U8(&model)
+	payload := d.Bytes()
+
+	var checksum uint32
+	d.U32(&checksum)
+	if err := d.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidState, err)
+	}
+
+	if title != header.Title || headerChecksum != header.HeaderChecksum || globalChecksum != header.GlobalChecksum {
+		return fmt.Errorf("%w: state is for %q, loaded game is %q", ErrStateWrongGame, title, header.Title)
+	}
+	if m := SystemModel(model); m != DMG && m != CGB && m != SGB {
+		return fmt.Errorf("%w: unknown model %d", ErrInvalidState, model)
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidState)
+	}
+
+	// Decode into a scratch machine first, so that the components are replaced only if the state
+	// is valid. The cartridge is shared, its state is restored afterwards.
+	rom := gb.Memory.Cartridge
+	scratch := &GameBoy{EmulationModel: SystemModel(model), sampleRate: gb.sampleRate}
+	scratch.newComponents(rom)
+
+	var cartridgeState bytes.Buffer
+	rom.SaveState(savestate.NewEncoder(&cartridgeState))
+	err := scratch.loadComponents(payload)
+	rom.LoadState(savestate.NewDecoder(&cartridgeState))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidState, err)
+	}
+
+	// State was saved with a different model, rebuild components
+	if SystemModel(model) != gb.EmulationModel {
+		gb.EmulationModel = SystemModel(model)
+		gb.initComponents(rom)
+	}
+	if err := gb.loadComponents(payload); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidState, err)
+	}
+	return nil
+}
+
+// loadComponents restores the components state written by SaveState
+func (gb *GameBoy) loadComponents(payload []uint8) error {
+	p := savestate.NewDecoder(bytes.NewReader(payload))
+	gb.CPU.LoadState(p)
+	gb.Memory.LoadState(p)
+	gb.PPU.LoadState(p)
+	gb.APU.LoadState(p)
+	gb.Timer.LoadState(p)
+	gb.SerialPort.LoadState(p)
+	gb.Infrared.LoadState(p)
+	gb.Joypad.LoadState(p)
+	gb.Memory.Cartridge.LoadState(p)
+	if gb.SGB != nil {
+		gb.SGB.LoadState(p)
+	}
+	return p.Err()
+}
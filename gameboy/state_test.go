@@ -0,0 +1,232 @@
+package gameboy
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"testing"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/cartridge"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+)
+
+// loopProgram writes to work RAM, external RAM and the audio registers in a loop
+var loopProgram = []uint8{
+	0x3E, 0x0A, // LD A,$0A
+	0xEA, 0x00, 0x00, // LD ($0000),A ; enable RAM
+	0x21, 0x00, 0xC0, // LD HL,$C000
+	// loop:
+	0x3C,             // INC A
+	0x22,             // LD (HL+),A
+	0xEA, 0x00, 0xA0, // LD ($A000),A
+	0xE0, 0x13, // LDH ($13),A ; NR13
+	0xCB, 0x64, // BIT 4,H
+	0x28, 0xF5, // JR Z,loop
+	0x21, 0x00, 0xC0, // LD HL,$C000
+	0x18, 0xF0, // JR loop
+}
+
+// testROM builds a 32 KiB MBC1+RAM cartridge running program from $0150
+func testROM(title string, program []uint8) []uint8 {
+	rom := make([]uint8, 0x8000)
+	copy(rom[0x100:], []uint8{0x00, 0xC3, 0x50, 0x01}) // NOP; JP $0150
+	copy(rom[0x104:], cartridge.NintendoLogo[:])
+	copy(rom[0x134:], title)
+	rom[0x147] = 0x02 // MBC1 + RAM
+	rom[0x148] = 0x00 // 32 KiB
+	rom[0x149] = 0x02 // 8 KiB
+	copy(rom[0x150:], program)
+
+	var checksum uint8
+	for _, b := range rom[0x134:0x14D] {
+		checksum = checksum - b - 1
+	}
+	rom[0x14D] = checksum
+	return rom
+}
+
+// newTestGameBoy starts the program with the boot ROM skipped. Nobody consumes
+// the audio samples, the APU must drop them without blocking.
+func newTestGameBoy(t *testing.T, model SystemModel, title string, program []uint8) *GameBoy {
+	t.Helper()
+
+	rom, err := cartridge.NewCartridge(testROM(title, program), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gb := New(make(chan float32, 1024), 44100)
+	gb.Model = model
+	gb.Load(rom)
+	gb.LoadBootROM(nil)
+	return gb
+}
+
+func run(gb *GameBoy, instructions int) {
+	for range instructions {
+		gb.CPU.ExecuteInstruction()
+	}
+}
+
+func saveState(t *testing.T, gb *GameBoy) []uint8 {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := gb.SaveState(&buf); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	for _, model := range []SystemModel{DMG, CGB, SGB} {
+		gb := newTestGameBoy(t, model, "STATE TEST", loopProgram)
+		run(gb, 50000)
+		state := saveState(t, gb)
+
+		run(gb, 30000)
+		expected := saveState(t, gb)
+
+		// Restore on a fresh machine and run the same number of instructions
+		other := newTestGameBoy(t, model, "STATE TEST", loopProgram)
+		if err := other.LoadState(bytes.NewReader(state)); err != nil {
+			t.Fatalf("LoadState: %v", err)
+		}
+		if got := saveState(t, other); !bytes.Equal(got, state) {
+			t.Errorf("model %d: state changed after load", model)
+		}
+
+		run(other, 30000)
+		if got := saveState(t, other); !bytes.Equal(got, expected) {
+			t.Errorf("model %d: emulation diverged after load", model)
+		}
+	}
+}
+
+func TestStateModelSwitch(t *testing.T) {
+	gb := newTestGameBoy(t, DMG, "STATE TEST", loopProgram)
+	run(gb, 10000)
+	state := saveState(t, gb)
+
+	other := newTestGameBoy(t, CGB, "STATE TEST", loopProgram)
+	if err := other.LoadState(bytes.NewReader(state)); err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if other.EmulationModel != DMG {
+		t.Errorf("expected DMG emulation after load, got %d", other.EmulationModel)
+	}
+	if got := saveState(t, other); !bytes.Equal(got, state) {
+		t.Errorf("state changed after load")
+	}
+}
+
+func TestStateRejected(t *testing.T) {
+	gb := newTestGameBoy(t, DMG, "STATE TEST", loopProgram)
+	run(gb, 1000)
+	state := saveState(t, gb)
+
+	t.Run("wrong game", func(t *testing.T) {
+		other := newTestGameBoy(t, DMG, "OTHER GAME", loopProgram)
+		if err := other.LoadState(bytes.NewReader(state)); !errors.Is(err, ErrStateWrongGame) {
+			t.Errorf("expected ErrStateWrongGame, got %v", err)
+		}
+	})
+
+	t.Run("bad magic", func(t *testing.T) {
+		corrupted := bytes.Clone(state)
+		corrupted[0] = 'X'
+		if err := gb.LoadState(bytes.NewReader(corrupted)); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("expected ErrInvalidState, got %v", err)
+		}
+	})
+
+	t.Run("version", func(t *testing.T) {
+		corrupted := bytes.Clone(state)
+		corrupted[4] = StateVersion + 1
+		if err := gb.LoadState(bytes.NewReader(corrupted)); !errors.Is(err, ErrStateVersion) {
+			t.Errorf("expected ErrStateVersion, got %v", err)
+		}
+	})
+
+	t.Run("checksum", func(t *testing.T) {
+		corrupted := bytes.Clone(state)
+		corrupted[len(corrupted)-100] ^= 0xFF
+		if err := gb.LoadState(bytes.NewReader(corrupted)); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("expected ErrInvalidState, got %v", err)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		if err := gb.LoadState(bytes.NewReader(state[:len(state)/2])); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("expected ErrInvalidState, got %v", err)
+		}
+	})
+
+	// Failed loads must leave the machine untouched
+	if got := saveState(t, gb); !bytes.Equal(got, state) {
+		t.Errorf("state changed after failed loads")
+	}
+}
+
+// truncatePayload cuts the components state in half, keeping a valid checksum
+func truncatePayload(t *testing.T, state []uint8) []uint8 {
+	t.Helper()
+
+	d := savestate.NewDecoder(bytes.NewReader(state))
+	magic := make([]uint8, len(stateMagic))
+	d.U8s(magic)
+	var (
+		version        uint32
+		headerChecksum uint8
+		globalChecksum uint16
+		model          uint8
+	)
+	d.U32(&version)
+	title := d.String()
+	d.U8(&headerChecksum)
+	d.U16(&globalChecksum)
+	d.U8(&model)
+	payload := d.Bytes()
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+	payload = payload[:len(payload)/2]
+
+	var buf bytes.Buffer
+	e := savestate.NewEncoder(&buf)
+	e.U8s(magic)
+	e.U32(version)
+	e.String(title)
+	e.U8(headerChecksum)
+	e.U16(globalChecksum)
+	e.U8(model)
+	e.Bytes(payload)
+	e.U32(crc32.ChecksumIEEE(payload))
+	if err := e.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestStateCorruptPayload(t *testing.T) {
+	for _, model := range []SystemModel{DMG, CGB} {
+		gb := newTestGameBoy(t, DMG, "STATE TEST", loopProgram)
+		run(gb, 10000)
+		corrupted := truncatePayload(t, saveState(t, gb))
+
+		// The machine is left untouched, even when the state was saved with another model
+		other := newTestGameBoy(t, model, "STATE TEST", loopProgram)
+		run(other, 20000)
+		before := saveState(t, other)
+
+		if err := other.LoadState(bytes.NewReader(corrupted)); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("model %d: expected ErrInvalidState, got %v", model, err)
+		}
+		if other.EmulationModel != model {
+			t.Errorf("model %d: emulation model changed to %d", model, other.EmulationModel)
+		}
+		if got := saveState(t, other); !bytes.Equal(got, before) {
+			t.Errorf("model %d: state changed after failed load", model)
+		}
+	}
+}
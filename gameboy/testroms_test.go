@@ -0,0 +1,327 @@
+package gameboy
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/cartridge"
+)
+
+// Test ROMs are not distributed with the repository, they are loaded from testdata:
+//
+//	testdata/blargg/   https://github.com/retrio/gb-test-roms
+//	testdata/mooneye/  https://github.com/Gekkio/mooneye-test-suite (built ROMs)
+//	testdata/boot/     dmg_boot.bin and cgb_boot.bin (optional, boot is skipped otherwise)
+//
+// Run `go test -run TestROMs -v` to print the result table,
+// add `-args -report=results.txt` to write it to a file.
+var romsReport = flag.String("report", "", "file where the test ROMs result table is written")
+
+const (
+	// Emulated time after which a test is considered failed
+	romTestTimeout = 120 * 60 * FrameTicks
+)
+
+type romResult struct {
+	suite  string
+	name   string
+	status string
+	detail string
+}
+
+type romResults struct {
+	mu      sync.Mutex
+	results []romResult
+}
+
+func (r *romResults) add(result romResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, result)
+}
+
+// table formats the results as a table, sorted by suite and name
+func (r *romResults) table() string {
+	slices.SortFunc(r.results, func(a, b romResult) int {
+		return strings.Compare(a.suite+"/"+a.name, b.suite+"/"+b.name)
+	})
+
+	var buf bytes.Buffer
+	var passed, failed, skipped int
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SUITE\tTEST\tRESULT\tDETAIL")
+	for _, res := range r.results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", res.suite, res.name, res.status, res.detail)
+		switch res.status {
+		case "PASS":
+			passed++
+		case "FAIL":
+			failed++
+		default:
+			skipped++
+		}
+	}
+	w.Flush()
+	fmt.Fprintf(&buf, "%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+	return buf.String()
+}
+
+func TestROMs(t *testing.T) {
+	var results romResults
+
+	t.Run("blargg", func(t *testing.T) {
+		for _, rom := range findTestROMs(t, "blargg") {
+			model := DMG
+			if strings.Contains(rom, "cgb") {
+				model = CGB
+			}
+			runTestROM(t, &results, "blargg", rom, model, true, runBlargg)
+		}
+	})
+
+	t.Run("mooneye", func(t *testing.T) {
+		for _, rom := range findTestROMs(t, "mooneye") {
+			model, ok := mooneyeModel(rom)
+			runTestROM(t, &results, "mooneye", rom, model, ok, runMooneye)
+		}
+	})
+
+	if len(results.results) == 0 {
+		return
+	}
+	table := results.table()
+	t.Log("\n" + table)
+	if *romsReport != "" {
+		if err := os.WriteFile(*romsReport, []byte(table), 0644); err != nil {
+			t.Errorf("writing report: %v", err)
+		}
+	}
+}
+
+// findTestROMs returns the ROMs of the suite relative to the suite directory
+func findTestROMs(t *testing.T, suite string) []string {
+	dir := filepath.Join("testdata", suite)
+	if _, err := os.Stat(dir); err != nil {
+		t.Skipf("%s not found", dir)
+	}
+
+	var roms []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip tests that need visual inspection and helpers
+		if d.IsDir() && (d.Name() == "manual-only" || d.Name() == "utils" || d.Name() == "source") {
+			return filepath.SkipDir
+		}
+
+		ext := filepath.Ext(path)
+		if !d.IsDir() && (ext == ".gb" || ext == ".gbc") {
+			rel, _ := filepath.Rel(dir, path)
+			roms = append(roms, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return roms
+}
+
+// mooneyeModel returns the model to use from the ROM name suffix (e.g. boot_regs-dmgABC), where
+// G, S, C and A stand for the DMG, SGB, CGB and AGB families. It returns false for tests targeting
+// models that are not emulated (DMG0, MGB, SGB2, AGB, AGS).
+func mooneyeModel(rom string) (SystemModel, bool) {
+	name := strings.TrimSuffix(filepath.Base(rom), filepath.Ext(rom))
+	i := strings.LastIndex(name, "-")
+	if i < 0 {
+		return DMG, true
+	}
+
+	switch models := strings.ToLower(name[i+1:]); {
+	case strings.HasPrefix(models, "dmg0"), strings.HasPrefix(models, "mgb"), strings.HasPrefix(models, "sgb2"),
+		strings.HasPrefix(models, "agb"), strings.HasPrefix(models, "ags"):
+		return DMG, false
+	case strings.HasPrefix(models, "dmg"), strings.HasPrefix(models, "g"):
+		return DMG, true
+	case strings.HasPrefix(models, "sgb"), strings.HasPrefix(models, "s"):
+		return SGB, true
+	case strings.HasPrefix(models, "cgb"), strings.HasPrefix(models, "c"):
+		return CGB, true
+	default:
+		return DMG, false
+	}
+}
+
+func TestMooneyeModel(t *testing.T) {
+	tests := []struct {
+		rom       string
+		model     SystemModel
+		supported bool
+	}{
+		{"add_sp_e_timing.gb", DMG, true},
+		{"boot_regs-dmgABC.gb", DMG, true},
+		{"boot_div-dmgABCmgb.gb", DMG, true},
+		{"boot_hwio-G.gb", DMG, true},
+		{"boot_regs-sgb.gb", SGB, true},
+		{"boot_hwio-S.gb", SGB, true},
+		{"boot_regs-cgb.gb", CGB, true},
+		{"boot_div-cgbABCDE.gb", CGB, true},
+		{"boot_hwio-C.gb", CGB, true},
+		{"boot_regs-dmg0.gb", DMG, false},
+		{"boot_regs-mgb.gb", DMG, false},
+		{"boot_regs-sgb2.gb", DMG, false},
+		{"boot_regs-A.gb", DMG, false},
+		{"boot_div-agb.gb", DMG, false},
+		{"boot_div-ags.gb", DMG, false},
+	}
+
+	for _, test := range tests {
+		model, supported := mooneyeModel(test.rom)
+		if supported != test.supported || (supported && model != test.model) {
+			t.Errorf("%s: got %v, %v, expected %v, %v", test.rom, model, supported, test.model, test.supported)
+		}
+	}
+}
+
+func runTestROM(t *testing.T, results *romResults, suite, rom string, model SystemModel, supported bool,
+	run func(*GameBoy) (bool, string)) {
+	t.Run(rom, func(t *testing.T) {
+		t.Parallel()
+
+		result := romResult{suite: suite, name: rom, status: "SKIP"}
+		defer func() { results.add(result) }()
+
+		if !supported {
+			result.detail = "model not emulated"
+			t.Skip(result.detail)
+		}
+
+		gb, err := loadTestROM(filepath.Join("testdata", suite, rom), model)
+		if err != nil {
+			result.status, result.detail = "FAIL", err.Error()
+			t.Fatal(err)
+		}
+
+		passed, detail := run(gb)
+		result.detail = detail
+		if passed {
+			result.status = "PASS"
+		} else {
+			result.status = "FAIL"
+			t.Error(detail)
+		}
+	})
+}
+
+func loadTestROM(path string, model SystemModel) (*GameBoy, error) {
+	romData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rom, err := cartridge.NewCartridge(romData, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	gb := New(nil, 44100)
+	gb.Model = model
+	gb.Load(rom)
+
+	bootRom := "dmg_boot.bin"
+	switch gb.EmulationModel {
+	case CGB:
+		bootRom = "cgb_boot.bin"
+	case SGB:
+		bootRom = "sgb_boot.bin"
+	}
+	bootRomData, err := os.ReadFile(filepath.Join("testdata", "boot", bootRom))
+	if err != nil {
+		bootRomData = nil
+	}
+	gb.LoadBootROM(bootRomData)
+	return gb, nil
+}
+
+// runMooneye runs the ROM until LD B,B is executed, then registers contain
+// the Fibonacci sequence 3/5/8/13/21/34 if the test passed, $42 otherwise
+func runMooneye(gb *GameBoy) (bool, string) {
+	const ldBB = 0x40
+
+	for gb.Ticks() < romTestTimeout {
+		breakpoint := gb.Memory.DebugRead(gb.CPU.PC) == ldBB
+		gb.Step()
+		if !breakpoint {
+			continue
+		}
+
+		c := gb.CPU
+		if c.B == 3 && c.C == 5 && c.D == 8 && c.E == 13 && c.H == 21 && c.L == 34 {
+			return true, ""
+		}
+		return false, fmt.Sprintf("B=%02X C=%02X D=%02X E=%02X H=%02X L=%02X", c.B, c.C, c.D, c.E, c.H, c.L)
+	}
+	return false, "timeout"
+}
+
+// runBlargg runs the ROM until it prints the result to the serial port,
+// or until it writes the result at $A000 (signature $DE $B0 $61 at $A001)
+func runBlargg(gb *GameBoy) (bool, string) {
+	var output strings.Builder
+	gb.SerialPort.TransferCallback = func(v uint8) { output.WriteByte(v) }
+
+	for gb.Ticks() < romTestTimeout {
+		gb.RunFrame()
+
+		// Serial output
+		text := output.String()
+		if strings.Contains(text, "Passed") {
+			return true, ""
+		}
+		if strings.Contains(text, "Failed") {
+			return false, lastLine(text)
+		}
+
+		// Memory signature
+		if gb.Memory.DebugRead(0xA001) == 0xDE && gb.Memory.DebugRead(0xA002) == 0xB0 && gb.Memory.DebugRead(0xA003) == 0x61 {
+			status := gb.Memory.DebugRead(0xA000)
+			if status == 0x80 { // Still running
+				continue
+			}
+			if status == 0 {
+				return true, ""
+			}
+			return false, fmt.Sprintf("result code %d: %s", status, lastLine(blarggMemoryText(gb)))
+		}
+	}
+	return false, "timeout"
+}
+
+// blarggMemoryText reads the zero terminated text at $A004
+func blarggMemoryText(gb *GameBoy) string {
+	var text strings.Builder
+	for addr := uint16(0xA004); addr < 0xC000; addr++ {
+		c := gb.Memory.DebugRead(addr)
+		if c == 0 {
+			break
+		}
+		text.WriteByte(c)
+	}
+	return text.String()
+}
+
+// lastLine returns the last non-empty line of the text
+func lastLine(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,27 @@
+package timer
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/savestate"
+
+func (t *Timer) SaveState(e *savestate.Encoder) {
+	e.U8(t.TIMA)
+	e.U8(t.TMA)
+	e.U8(t.TAC)
+	e.U16(t.systemCounter)
+	e.U8(t.prevState)
+	e.U8(t.prevBit12)
+	e.Bool(t.timaOverflow)
+	e.Bool(t.timaReloaded)
+	e.Int(t.speedFactor)
+}
+
+func (t *Timer) LoadState(d *savestate.Decoder) {
+	d.U8(&t.TIMA)
+	d.U8(&t.TMA)
+	d.U8(&t.TAC)
+	d.U16(&t.systemCounter)
+	d.U8(&t.prevState)
+	d.U8(&t.prevBit12)
+	d.Bool(&t.timaOverflow)
+	d.Bool(&t.timaReloaded)
+	d.Int(&t.speedFactor)
+}
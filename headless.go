@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image/png"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/cartridge"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/infrared"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/palette"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/printer"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savefile"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/serial"
+)
+
+// runHeadless runs the emulator without window and audio device, as fast as possible.
+// When it stops, the game is saved and the last frame is written to the screenshot file.
+func runHeadless() error {
+	if *romPath == "" {
+		return errors.New("ROM file path is required in headless mode")
+	}
+
+	gb := gameboy.New(nil, 44100)
+	model, err := gameboy.ParseModel(*systemModel)
+	if err != nil {
+		return err
+	}
+	gb.Model = model
+	multicartMode, err := cartridge.ParseMulticartMode(*multicart)
+	if err != nil {
+		return err
+	}
+	rtcClock, err := cartridge.ParseClock(*clock)
+	if err != nil {
+		return err
+	}
+	rtcProgress, err := cartridge.ParseRTCMode(*rtcMode)
+	if err != nil {
+		return err
+	}
+	imageSource, err := cartridge.NewImageSource(*camera)
+	if err != nil {
+		return err
+	}
+	gb.SetImageSource(imageSource)
+	if err := setHeadlessSerialDevice(gb); err != nil {
+		return err
+	}
+	// Unplugging the device closes its log file
+	defer gb.SetSerialDevice(nil)
+	if err := setHeadlessInfrared(gb); err != nil {
+		return err
+	}
+
+	// Load ROM, save and boot ROM
+	rom, err := savefile.LoadCartridge(*romPath, rtcClock, multicartMode, rtcProgress)
+	if err != nil {
+		return err
+	}
+	gb.Load(rom)
+	autosaver := savefile.NewAutosaver(savefile.Path(*romPath), *autosave, *saveBackups)
+
+	var bootRomData []uint8
+	if *bootRom != "" {
+		if bootRomData, err = os.ReadFile(*bootRom); err != nil {
+			return err
+		}
+	}
+	gb.LoadBootROM(bootRomData)
+
+	// Run until the requested number of frames is reached or until interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for frame := 0; *frames == 0 || frame < *frames; frame++ {
+		if ctx.Err() != nil {
+			break
+		}
+		gb.RunFrame()
+		if gb.SaveChanged() {
+			autosaver.Changed(gb.Memory.Cartridge.RAMDump())
+		}
+	}
+
+	if err := autosaver.Save(gb.Memory.Cartridge.RAMDump()); err != nil {
+		log.Println("error writing game save:", err)
+	}
+
+	if *screenshot != "" {
+		return saveScreenshot(gb, *screenshot)
+	}
+	return nil
+}
+
+// setHeadlessSerialDevice plugs the device selected by the -serial and -printer flags
+func setHeadlessSerialDevice(gb *gameboy.GameBoy) error {
+	name := *serialDevice
+	if *printerDir != "" {
+		if name != "" {
+			return errors.New("the printer and another serial device cannot be used together")
+		}
+		name = "printer=" + *printerDir
+	}
+	kind, arg, _ := strings.Cut(name, "=")
+
+	switch kind {
+	case "master", "slave", "dmg07":
+		log.Println("[WARN] serial link is not available in headless mode")
+	case "printer":
+		gb.SetSerialDevice(serial.Bytes(printer.New(arg)))
+	default:
+		device, err := serial.ParseDevice(name)
+		if err != nil {
+			return err
+		}
+		gb.SetSerialDevice(device)
+	}
+	return nil
+}
+
+// setHeadlessInfrared points the IR port at the device selected by the -ir flag
+func setHeadlessInfrared(gb *gameboy.GameBoy) error {
+	switch *irDevice {
+	case "master", "slave", "player2":
+		log.Println("[WARN] infrared link is not available in headless mode")
+	default:
+		device, err := infrared.ParseDevice(*irDevice)
+		if err != nil {
+			return err
+		}
+		gb.SetInfraredDevice(device)
+	}
+	return nil
+}
+
+func saveScreenshot(gb *gameboy.GameBoy, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Super Game Boy screenshots include the border
+	if gb.SGB != nil {
+		return png.Encode(f, palette.SGBFrame(gb.SGB.GetFrame()))
+	}
+
+	var p palette.Palette = palette.DMG{}
+	if gb.EmulationModel == gameboy.CGB {
+		p = palette.CGB{}
+	}
+	return png.Encode(f, palette.Frame(gb.PPU.GetFrame(), p))
+}
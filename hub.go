@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/dmg07"
+)
+
+// runHub runs a DMG-07 4 Player Adapter that emulators join with -serial dmg07, until interrupted:
+//
+//	lucky-boy dmg07 [-addr host:port]
+func runHub(args []string) error {
+	flags := flag.NewFlagSet("dmg07", flag.ExitOnError)
+	addr := flags.String("addr", "localhost:4321", "Address the adapter listens on (host:port)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: lucky-boy dmg07 [-addr host:port]")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	server, err := dmg07.ListenHub(*addr)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+	log.Println("[DMG-07] listening on", server.Addr())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	<-ctx.Done()
+	return nil
+}
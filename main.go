@@ -2,26 +2,59 @@ package main
 
 import (
 	"flag"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/cartridge"
 	"github.com/danielecanzoneri/lucky-boy/ui"
 	"log"
-)
-
-const (
-	socketPort = "4321"
+	"os"
+	"time"
 )
 
 var (
 	startWithDebugger = flag.Bool("debug", false, "Start emulator with debugger enabled")
 	bootRom           = flag.String("boot-rom", "", "Boot ROM filename")
 	romPath           = flag.String("rom", "", "ROM filename")
-	serial            = flag.String("serial", "", "Serial role (master or slave)")
+	player2ROM        = flag.String("player2", "", "Run a second Game Boy with this ROM, linked to the first one in the same window (Tab switches the input)")
+	serialDevice      = flag.String("serial", "", "Serial device (none, loopback, log[=FILE], printer[=DIR]), link cable role (master, slave) or dmg07 (4 player adapter hub, shared with player 2 if running)")
+	serialAddr        = flag.String("serial-addr", "localhost:4321", "Link cable address (host:port): the master listens on it, the slave and the DMG-07 players connect to it")
+	irDevice          = flag.String("ir", "", "Infrared device (none, noise[=SEED], player2) or IR link role (master, slave)")
+	irAddr            = flag.String("ir-addr", "localhost:4322", "IR link address (host:port): the master listens on it, the slave connects to it")
 	shader            = flag.Bool("shader", true, "Use GBC color correction shader")
-	systemModel       = flag.String("model", "auto", "GameBoy model (auto, dmg, cgb)")
+	systemModel       = flag.String("model", "auto", "GameBoy model (auto, dmg, cgb, sgb)")
+	multicart         = flag.String("mbc1m", "auto", "MBC1 multicart wiring (auto, on, off)")
+	clock             = flag.String("clock", "real", "Cartridge RTC clock (real, frozen[=RFC 3339 time], offset=duration)")
+	rtcMode           = flag.String("rtc", "emulated", "Cartridge RTC progress (emulated, wall)")
+	printerDir        = flag.String("printer", "", "Connect a Game Boy Printer saving the printed images as PNG files in this directory")
+	camera            = flag.String("camera", "", "Game Boy Camera image: PNG file or directory of PNG frames (test pattern if empty)")
+	autosave          = flag.Duration("autosave", 2*time.Second, "Save the game this long after it stops writing the cartridge RAM (0 disables autosave)")
+	saveBackups       = flag.Int("save-backups", 3, "Number of previous saves kept as backups (.sav.1 is the most recent)")
+	headless          = flag.Bool("headless", false, "Run without window and audio")
+	frames            = flag.Int("frames", 0, "Number of frames to run in headless mode (0 runs until interrupted)")
+	screenshot        = flag.String("screenshot", "", "Save the last frame as PNG when headless mode ends")
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "convert-save" {
+		if err := runConvertSave(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "dmg07" {
+		if err := runHub(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	flag.Parse()
 
+	if *headless {
+		if err := runHeadless(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Init emulator
 	gui, err := ui.New(*shader)
 	if err != nil {
@@ -38,11 +71,50 @@ func main() {
 	if err = gui.SetModel(*systemModel); err != nil {
 		log.Fatal(err)
 	}
-
-	err = gui.LoadROM(*romPath)
+	if err = gui.SetMulticartMode(*multicart); err != nil {
+		log.Fatal(err)
+	}
+	if err = gui.SetClock(*clock); err != nil {
+		log.Fatal(err)
+	}
+	if err = gui.SetRTCMode(*rtcMode); err != nil {
+		log.Fatal(err)
+	}
+	imageSource, err := cartridge.NewImageSource(*camera)
 	if err != nil {
 		log.Fatal(err)
 	}
+	gui.GameBoy.SetImageSource(imageSource)
+	gui.SetAutosave(*autosave, *saveBackups)
+	if *printerDir != "" {
+		if *serialDevice != "" {
+			log.Fatal("the printer and another serial device cannot be used together")
+		}
+		*serialDevice = "printer=" + *printerDir
+	}
+
+	// If the ROM cannot be loaded, ask for another one
+	for {
+		err = gui.LoadROM(*romPath)
+		if err == nil {
+			break
+		}
+
+		gui.ShowError(err)
+		*romPath, err = gui.AskRomPath()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *player2ROM != "" {
+		if *serialDevice != "" && *serialDevice != "dmg07" {
+			log.Fatal("player 2 is linked to the serial port, no other serial device can be used")
+		}
+		if err = gui.SetPlayer2(*player2ROM); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// Load Boot ROM
 	if err = gui.LoadBootROM(*bootRom); err != nil {
@@ -50,14 +122,40 @@ func main() {
 	}
 
 	// Serial port data exchange
-	switch *serial {
+	switch *serialDevice {
+	case "":
 	case "master":
-		gui.Listen(socketPort)
+		if err = gui.Listen(*serialAddr); err != nil {
+			log.Fatal(err)
+		}
 	case "slave":
-		gui.Connect(socketPort)
+		gui.Connect(*serialAddr)
+	case "dmg07":
+		// Player 2 shares an adapter in the same process
+		if *player2ROM == "" {
+			gui.ConnectHub(*serialAddr)
+		} else if err = gui.SetSerialDevice(*serialDevice); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		if err = gui.SetSerialDevice(*serialDevice); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Infrared light
+	switch *irDevice {
 	case "":
+	case "master":
+		if err = gui.ListenInfrared(*irAddr); err != nil {
+			log.Fatal(err)
+		}
+	case "slave":
+		gui.ConnectInfrared(*irAddr)
 	default:
-		log.Printf("Invalid serial role %q", *serial)
+		if err = gui.SetInfrared(*irDevice); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	if *startWithDebugger {
@@ -68,8 +68,7 @@ func (ui *UI) Read(buf []byte) (n int, err error) {
 				continue
 			}
 
-			ui.GameBoy.Joypad.DetectKeysPressed()
-			ui.GameBoy.CPU.ExecuteInstruction()
+			ui.step()
 
 			if ui.debugger.Active {
 				pc := ui.GameBoy.CPU.ReadPC()
@@ -87,5 +86,6 @@ func (ui *UI) Read(buf []byte) (n int, err error) {
 		}
 	}
 
+	ui.autosave()
 	return bufferPosition, nil
 }
@@ -1,58 +1,69 @@
 package ui
 
 import (
+	"fmt"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/dmg07"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/serial"
-	"log"
-	"net"
 )
 
-// Listen on the specified port for incoming connections
-func (ui *UI) Listen(socketPort string) {
-	ln, err := net.Listen("tcp", "localhost:"+socketPort)
+// Listen waits for another emulator connecting to addr (host:port)
+func (ui *UI) Listen(addr string) error {
+	link, err := serial.ListenLink(addr, ui.GameBoy.EmulationModel.String())
 	if err != nil {
-		log.Println("[ERROR] Listening: ", err)
-		return
+		return err
 	}
 
-	go func() {
-		ui.GameBoy.SerialPort.State = serial.Connecting
+	ui.plugLink(link)
+	return nil
+}
 
-		// Wait for an incoming connection
-		conn, err := ln.Accept()
-		if err != nil {
-			log.Println("[ERROR] Accepting incoming connection", err)
-			ui.GameBoy.SerialPort.State = serial.Disconnected
-			return
-		}
+// Connect connects to another emulator listening on addr (host:port), retrying until it is reachable
+func (ui *UI) Connect(addr string) {
+	ui.plugLink(serial.DialLink(addr, ui.GameBoy.EmulationModel.String()))
+}
 
-		// Important for low latency
-		tcpConn := conn.(*net.TCPConn)
-		err = tcpConn.SetNoDelay(true)
-		if err != nil {
-			log.Println("[ERROR] Setting socket no delay: ", err)
-		}
+// plugLink plugs the link cable into the serial port
+func (ui *UI) plugLink(link *serial.Link) {
+	ui.link = link
+	ui.GameBoy.SetSerialDevice(ui.link)
+	ui.serialDevice = "link"
+}
 
-		ui.GameBoy.SerialPort.Conn = conn
-		go ui.GameBoy.SerialPort.Listen()
-		ui.GameBoy.SerialPort.State = serial.Connected
-	}()
+// ConnectHub plugs the serial port into a DMG-07 hub listening on addr (host:port), retrying until
+// it is reachable
+func (ui *UI) ConnectHub(addr string) {
+	ui.hubClient = dmg07.DialHub(addr)
+	ui.GameBoy.SetSerialDevice(ui.hubClient)
+	ui.serialDevice = "dmg07"
 }
 
-// Connect on the specified port to another socket
-func (ui *UI) Connect(socketPort string) {
-	conn, err := net.Dial("tcp", "localhost:"+socketPort)
-	if err != nil {
-		log.Println("[ERROR] Connecting to socket: ", err)
+// updateLinkState notifies when the other emulator (or the DMG-07 hub) is connected or the cable
+// is unplugged
+func (ui *UI) updateLinkState() {
+	if ui.hubClient != nil {
+		if player := ui.hubClient.Player(); player != ui.hubPlayer {
+			ui.hubPlayer = player
+			if player != 0 {
+				ui.debugString = fmt.Sprintf("DMG-07 connected (player %d)", player)
+			} else {
+				ui.debugString = "DMG-07 unplugged"
+			}
+			ui.debugStringTimer = 120
+		}
 	}
 
-	// Important for low latency
-	tcpConn := conn.(*net.TCPConn)
-	err = tcpConn.SetNoDelay(true)
-	if err != nil {
-		log.Println("[ERROR] Setting socket no delay: ", err)
+	if ui.link == nil {
+		return
 	}
 
-	ui.GameBoy.SerialPort.Conn = conn
-	go ui.GameBoy.SerialPort.Listen()
-	ui.GameBoy.SerialPort.State = serial.Connected
+	if connected := ui.link.Connected(); connected != ui.linkConnected {
+		ui.linkConnected = connected
+		if connected {
+			ui.debugString = "Link cable connected (" + ui.link.PeerModel() + ")"
+		} else {
+			ui.debugString = "Link cable unplugged"
+		}
+		ui.debugStringTimer = 120
+	}
 }
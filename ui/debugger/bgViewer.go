@@ -6,6 +6,7 @@ import (
 	"github.com/danielecanzoneri/lucky-boy/gameboy"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/ppu"
 	"github.com/danielecanzoneri/lucky-boy/ui/graphics"
+	"github.com/danielecanzoneri/lucky-boy/ui/graphics/palette"
 	"github.com/ebitenui/ebitenui"
 	"github.com/ebitenui/ebitenui/widget"
 )
@@ -38,10 +39,10 @@ func (t *bgTile) Sync(gb *gameboy.GameBoy) {
 	t.address = gb.PPU.DebugGetBGTileMapAddr() + (t.row * 32) + t.col
 	t.tileId = gb.PPU.GetTileId(t.address - 0x9800)
 
-	var systemPalette theme.Palette = theme.DMGPalette{}
+	var systemPalette palette.Palette = palette.DMG{}
 	var colorPalette ppu.Palette = gb.PPU.BGP
 	if gb.EmulationModel == gameboy.CGB {
-		systemPalette = theme.CGBPalette{}
+		systemPalette = palette.CGB{}
 
 		bgPalette := gb.PPU.DebugGetBGPalette()
 		if gb.PPU.DmgCompatibility {
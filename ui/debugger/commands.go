@@ -40,6 +40,7 @@ func (d *Debugger) Continue() {
 	}
 
 	d.Running = true
+	d.rtcViewer.setEditable(false)
 
 	// Unselect current entry
 	d.disassembler.currentInstruction = -1
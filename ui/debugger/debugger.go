@@ -23,6 +23,8 @@ type Debugger struct {
 	bgViewer    *bgViewer
 	tilesViewer *tilesViewer
 
+	rtcViewer *rtcViewer
+
 	// State
 	gameBoy *gameboy.GameBoy
 	Active  bool
@@ -61,6 +63,8 @@ func New(gb *gameboy.GameBoy) *Debugger {
 	d.bgViewer = d.newBGViewer()
 	d.tilesViewer = d.newTilesViewer()
 
+	d.rtcViewer = d.newRTCViewer()
+
 	// Add widgets to the root container
 	registersContainer := widget.NewContainer(
 		widget.ContainerOpts.Layout(widget.NewRowLayout(
@@ -92,6 +96,7 @@ func (d *Debugger) Sync() {
 	d.disassembler.Sync(d.gameBoy)
 	d.memoryViewer.Sync(d.gameBoy)
 	d.registersViewer.Sync(d.gameBoy)
+	d.rtcViewer.Sync(d.gameBoy)
 }
 
 func (d *Debugger) Update() error {
@@ -6,6 +6,7 @@ import (
 	"github.com/danielecanzoneri/lucky-boy/gameboy"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/ppu"
 	"github.com/danielecanzoneri/lucky-boy/ui/graphics"
+	"github.com/danielecanzoneri/lucky-boy/ui/graphics/palette"
 	"github.com/ebitenui/ebitenui"
 	"github.com/ebitenui/ebitenui/widget"
 )
@@ -57,11 +58,11 @@ func (obj *oamViewerObject) Sync(gb *gameboy.GameBoy) {
 	obj.tileLabel.Label = fmt.Sprintf("%02X", oamObj.Read(2))
 	obj.attributeLabel.Label = fmt.Sprintf("%02X", oamObj.Read(3))
 
-	var systemPalette theme.Palette = theme.DMGPalette{}
+	var systemPalette palette.Palette = palette.DMG{}
 	paletteId := ppu.TileAttribute(oamObj.Read(3)).DMGPalette()
 	var colorPalette ppu.Palette = gb.PPU.OBP[paletteId]
 	if gb.EmulationModel == gameboy.CGB {
-		systemPalette = theme.CGBPalette{}
+		systemPalette = palette.CGB{}
 
 		objPalette := gb.PPU.DebugGetOBJPalette()
 		if gb.PPU.DmgCompatibility {
@@ -0,0 +1,283 @@
+package debugger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/cartridge"
+	"github.com/danielecanzoneri/lucky-boy/ui/graphics"
+	"github.com/ebitenui/ebitenui"
+	"github.com/ebitenui/ebitenui/image"
+	"github.com/ebitenui/ebitenui/widget"
+)
+
+var textInputImage = &widget.TextInputImage{
+	Idle:     image.NewNineSliceColor(theme.Debugger.Main.Color),
+	Disabled: image.NewNineSliceColor(theme.Debugger.Main.Color),
+}
+
+var textInputColor = &widget.TextInputColor{
+	Idle:          theme.Debugger.LabelColor,
+	Disabled:      theme.Debugger.LabelColor,
+	Caret:         theme.Debugger.TitleColor,
+	DisabledCaret: theme.Debugger.LabelColor,
+}
+
+// rtcViewer shows and edits the MBC3 real time clock
+type rtcViewer struct {
+	// Pointer to the UI for showing the window
+	ui      *ebitenui.UI
+	gameBoy *gameboy.GameBoy
+	// The clock runs on the emulation goroutine, it can only be edited while the debugger is paused
+	debugger *Debugger
+
+	seconds, minutes, hours, days *widget.TextInput
+	halt, carry                   *widget.Button
+	advance                       *widget.TextInput
+	mode                          *widget.Button
+	writeButton, advanceButton    *widget.Button
+	status                        *widget.Text
+
+	// Flags edited with the buttons
+	haltFlag, carryFlag bool
+
+	// Window info
+	windowInfo *windowInfo
+
+	// Handler to close the window
+	closeWindow widget.RemoveWindowFunc
+}
+
+func newTextInput(placeholder string) *widget.TextInput {
+	return widget.NewTextInput(
+		widget.TextInputOpts.Image(textInputImage),
+		widget.TextInputOpts.Color(textInputColor),
+		widget.TextInputOpts.Face(&font),
+		widget.TextInputOpts.Padding(theme.Debugger.Insets),
+		widget.TextInputOpts.Placeholder(placeholder),
+		widget.TextInputOpts.WidgetOpts(widget.WidgetOpts.MinSize(96, 0)),
+	)
+}
+
+func newButton(label string, onClick func()) *widget.Button {
+	return widget.NewButton(
+		widget.ButtonOpts.Image(theme.Debugger.Button.Image),
+		widget.ButtonOpts.TextPadding(theme.Debugger.Insets),
+		widget.ButtonOpts.Text(label, &font, theme.Debugger.Button.TextColor),
+		widget.ButtonOpts.ClickedHandler(func(args *widget.ButtonClickedEventArgs) {
+			onClick()
+		}),
+	)
+}
+
+func (d *Debugger) newRTCViewer() *rtcViewer {
+	v := &rtcViewer{ui: d.UI, gameBoy: d.gameBoy, debugger: d}
+
+	v.seconds = newTextInput("0-63")
+	v.minutes = newTextInput("0-63")
+	v.hours = newTextInput("0-31")
+	v.days = newTextInput("0-511")
+	v.halt = newButton("", func() {
+		v.haltFlag = !v.haltFlag
+		v.syncFlags()
+	})
+	v.carry = newButton("", func() {
+		v.carryFlag = !v.carryFlag
+		v.syncFlags()
+	})
+	v.advance = newTextInput("e.g. 1d2h30m")
+	v.mode = newButton("", v.toggleMode)
+	v.status = newLabel("", theme.Debugger.HeaderColor)
+
+	grid := widget.NewContainer(
+		widget.ContainerOpts.Layout(widget.NewGridLayout(
+			widget.GridLayoutOpts.Columns(2),
+			widget.GridLayoutOpts.Padding(theme.Debugger.Insets),
+			widget.GridLayoutOpts.Spacing(theme.Debugger.Padding, theme.Debugger.Padding),
+		)),
+	)
+	v.writeButton = newButton("Write", v.write)
+	v.advanceButton = newButton("Advance", v.advanceTime)
+
+	grid.AddChild(
+		newLabel("Seconds", theme.Debugger.TitleColor), v.seconds,
+		newLabel("Minutes", theme.Debugger.TitleColor), v.minutes,
+		newLabel("Hours", theme.Debugger.TitleColor), v.hours,
+		newLabel("Days", theme.Debugger.TitleColor), v.days,
+		newLabel("Halt", theme.Debugger.TitleColor), v.halt,
+		newLabel("Carry", theme.Debugger.TitleColor), v.carry,
+		v.writeButton, newButton("Reload", func() { v.Sync(v.gameBoy) }),
+		v.advance, v.advanceButton,
+		newLabel("Progress", theme.Debugger.TitleColor), v.mode,
+	)
+
+	root := newContainer(widget.DirectionVertical, grid, v.status)
+	v.windowInfo = newWindow("RTC", root, &v.closeWindow)
+	return v
+}
+
+func (v *rtcViewer) Window() *widget.Window {
+	return v.windowInfo.Window
+}
+
+func (v *rtcViewer) Contents() *widget.Container {
+	return v.windowInfo.Contents
+}
+
+func (v *rtcViewer) TitleBar() *widget.Container {
+	return v.windowInfo.TitleBar
+}
+
+func (v *rtcViewer) SetCloseHandler(closeFunc widget.RemoveWindowFunc) widget.RemoveWindowFunc {
+	old := v.closeWindow
+	v.closeWindow = closeFunc
+	return old
+}
+
+// cartridge returns the MBC3 with RTC currently loaded, if any
+func (v *rtcViewer) cartridge() *cartridge.MBC3 {
+	if v.gameBoy.Memory == nil {
+		return nil
+	}
+	if mbc, ok := v.gameBoy.Memory.Cartridge.(*cartridge.MBC3); ok && mbc.HasRTC() {
+		return mbc
+	}
+	return nil
+}
+
+func (v *rtcViewer) Sync(gb *gameboy.GameBoy) {
+	if !v.ui.IsWindowOpen(v.Window()) {
+		return
+	}
+
+	mbc := v.cartridge()
+	if mbc == nil {
+		v.status.Label = "No RTC in this cartridge"
+		return
+	}
+	v.status.Label = ""
+
+	rtc := mbc.RTC()
+	v.seconds.SetText(strconv.Itoa(int(rtc.Seconds)))
+	v.minutes.SetText(strconv.Itoa(int(rtc.Minutes)))
+	v.hours.SetText(strconv.Itoa(int(rtc.Hours)))
+	v.days.SetText(strconv.Itoa(int(rtc.Days)))
+	v.haltFlag, v.carryFlag = rtc.Halt, rtc.Carry
+	v.syncFlags()
+	v.mode.SetText(mbc.RTCMode().String())
+	v.setEditable(!v.debugger.Running)
+}
+
+// setEditable enables or disables the controls editing the clock
+func (v *rtcViewer) setEditable(editable bool) {
+	for _, w := range []widget.HasWidget{
+		v.seconds, v.minutes, v.hours, v.days, v.halt, v.carry, v.advance, v.writeButton, v.advanceButton, v.mode,
+	} {
+		w.GetWidget().Disabled = !editable
+	}
+}
+
+// editable returns the MBC3 if the clock can be edited (nil if there is no RTC or the game is running)
+func (v *rtcViewer) editable() *cartridge.MBC3 {
+	if v.debugger.Running {
+		v.status.Label = "Pause the game to edit the clock"
+		return nil
+	}
+	return v.cartridge()
+}
+
+func (v *rtcViewer) syncFlags() {
+	flag := func(set bool) string {
+		if set {
+			return "1"
+		}
+		return "0"
+	}
+	v.halt.SetText(flag(v.haltFlag))
+	v.carry.SetText(flag(v.carryFlag))
+}
+
+// write sets the RTC registers to the values of the inputs
+func (v *rtcViewer) write() {
+	mbc := v.editable()
+	if mbc == nil {
+		return
+	}
+
+	var rtc cartridge.RTCRegisters
+	fields := []struct {
+		input *widget.TextInput
+		max   int
+		set   func(value int)
+	}{
+		{v.seconds, 0x3F, func(value int) { rtc.Seconds = uint8(value) }},
+		{v.minutes, 0x3F, func(value int) { rtc.Minutes = uint8(value) }},
+		{v.hours, 0x1F, func(value int) { rtc.Hours = uint8(value) }},
+		{v.days, 0x1FF, func(value int) { rtc.Days = uint16(value) }},
+	}
+	for _, field := range fields {
+		value, err := strconv.Atoi(strings.TrimSpace(field.input.GetText()))
+		if err != nil || value < 0 || value > field.max {
+			v.status.Label = fmt.Sprintf("Invalid value %q", field.input.GetText())
+			return
+		}
+		field.set(value)
+	}
+	rtc.Halt, rtc.Carry = v.haltFlag, v.carryFlag
+
+	mbc.SetRTC(rtc)
+	v.Sync(v.gameBoy)
+}
+
+func (v *rtcViewer) advanceTime() {
+	mbc := v.editable()
+	if mbc == nil {
+		return
+	}
+
+	d, err := parseDuration(v.advance.GetText())
+	if err != nil || d < 0 {
+		v.status.Label = fmt.Sprintf("Invalid duration %q", v.advance.GetText())
+		return
+	}
+
+	mbc.AdvanceRTC(d)
+	v.Sync(v.gameBoy)
+}
+
+func (v *rtcViewer) toggleMode() {
+	mbc := v.editable()
+	if mbc == nil {
+		return
+	}
+
+	if mbc.RTCMode() == cartridge.RTCEmulated {
+		mbc.SetRTCMode(cartridge.RTCWallTime)
+	} else {
+		mbc.SetRTCMode(cartridge.RTCEmulated)
+	}
+	v.Sync(v.gameBoy)
+}
+
+// parseDuration parses a Go duration that can start with a number of days (e.g. "2d12h")
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+
+	var days time.Duration
+	if before, after, found := strings.Cut(s, "d"); found {
+		n, err := strconv.Atoi(before)
+		if err != nil {
+			return 0, err
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		if s = after; s == "" {
+			return days, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	return days + d, err
+}
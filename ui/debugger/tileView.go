@@ -4,7 +4,7 @@ import (
 	"image/color"
 
 	"github.com/danielecanzoneri/lucky-boy/gameboy/ppu"
-	"github.com/danielecanzoneri/lucky-boy/ui/graphics"
+	"github.com/danielecanzoneri/lucky-boy/ui/graphics/palette"
 	"github.com/ebitenui/ebitenui/widget"
 	"github.com/hajimehoshi/ebiten/v2"
 )
@@ -113,7 +113,7 @@ func (tv *tileView) ensureInitialized() {
 
 // renderPixels renders pixel data to the tile using the shared buffer
 // pixels is an array of 8 rows, each with 8 pixel values
-func (tv *tileView) renderPixels(pixels [8][8]uint8, systemPalette theme.Palette, colorPalette ppu.Palette) {
+func (tv *tileView) renderPixels(pixels [8][8]uint8, systemPalette palette.Palette, colorPalette ppu.Palette) {
 	tv.ensureInitialized()
 	buf := sharedTileRenderer.renderBuffer
 
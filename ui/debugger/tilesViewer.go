@@ -6,6 +6,7 @@ import (
 
 	"github.com/danielecanzoneri/lucky-boy/gameboy"
 	"github.com/danielecanzoneri/lucky-boy/ui/graphics"
+	"github.com/danielecanzoneri/lucky-boy/ui/graphics/palette"
 	"github.com/ebitenui/ebitenui"
 	"github.com/ebitenui/ebitenui/widget"
 )
@@ -43,10 +44,10 @@ func newTileData(bank uint8, address uint16, syncTile func(bank uint8, address u
 func (t *tileData) Sync(gb *gameboy.GameBoy) {
 	tileOffset := (t.address - 0x8000) >> 4
 
-	var systemPalette theme.Palette = theme.DMGPalette{}
+	var systemPalette palette.Palette = palette.DMG{}
 	var colorPalette ppu.Palette = basicDMGPalette
 	if gb.EmulationModel == gameboy.CGB {
-		systemPalette = theme.CGBPalette{}
+		systemPalette = palette.CGB{}
 		colorPalette = basicCGBPalette
 	}
 
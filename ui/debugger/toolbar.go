@@ -71,6 +71,11 @@ func (d *Debugger) newToolbar() *toolbar {
 		ebiten.KeyShift, ebiten.KeyB)
 	ppuMenu.addEntryWithShortcut("TilesViewer", func() { d.showWindow(d.tilesViewer) },
 		ebiten.KeyShift, ebiten.KeyT)
+
+	// Cartridge menu
+	cartridgeMenu := t.newMenu("Cartridge")
+	cartridgeMenu.addEntryWithShortcut("RTC", func() { d.showWindow(d.rtcViewer) },
+		ebiten.KeyShift, ebiten.KeyC)
 	return t
 }
 
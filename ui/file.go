@@ -2,30 +2,81 @@ package ui
 
 import (
 	"errors"
-	"fmt"
 	"github.com/danielecanzoneri/lucky-boy/gameboy"
-	"github.com/danielecanzoneri/lucky-boy/ui/graphics"
+	"github.com/danielecanzoneri/lucky-boy/ui/graphics/palette"
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/danielecanzoneri/lucky-boy/gameboy/cartridge"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savefile"
+	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/sqweek/dialog"
 )
 
 func (ui *UI) Save() {
-	ramDump := ui.GameBoy.Memory.Cartridge.RAMDump()
-	if ramDump == nil {
+	if ui.player2Autosaver != nil {
+		if err := ui.player2Autosaver.Save(ui.player2.Memory.Cartridge.RAMDump()); err != nil {
+			log.Println("error writing game save of player 2:", err)
+		}
+	}
+
+	if ui.autosaver == nil {
 		return
 	}
 
-	savFile := getSavFileName(ui.fileName)
-	err := os.WriteFile(savFile, ramDump, 0644)
+	err := ui.autosaver.Save(ui.GameBoy.Memory.Cartridge.RAMDump())
 	if err != nil {
 		log.Println("error writing game save:", err)
 	}
 }
 
+// SetAutosave sets how long after the last write to the cartridge RAM the game is saved
+// (0 disables autosave) and how many previous saves are kept as backups
+func (ui *UI) SetAutosave(delay time.Duration, backups int) {
+	ui.autosaveDelay = delay
+	ui.saveBackups = backups
+}
+
+// autosave schedules saving the game when the battery backed memory has changed
+func (ui *UI) autosave() {
+	if ui.autosaver != nil && ui.GameBoy.SaveChanged() {
+		ui.autosaver.Changed(ui.GameBoy.Memory.Cartridge.RAMDump())
+	}
+	if ui.player2Autosaver != nil && ui.player2.SaveChanged() {
+		ui.player2Autosaver.Changed(ui.player2.Memory.Cartridge.RAMDump())
+	}
+}
+
+// SaveState writes the emulator state next to the ROM file
+func (ui *UI) SaveState() error {
+	ui.emulation.Lock()
+	defer ui.emulation.Unlock()
+
+	f, err := os.Create(getStateFileName(ui.fileName))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return ui.GameBoy.SaveState(f)
+}
+
+// LoadState restores the emulator state saved with SaveState
+func (ui *UI) LoadState() error {
+	ui.emulation.Lock()
+	defer ui.emulation.Unlock()
+
+	f, err := os.Open(getStateFileName(ui.fileName))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return ui.GameBoy.LoadState(f)
+}
+
 func (ui *UI) LoadBootROM(bootRom string) (err error) {
 	var data []uint8
 
@@ -35,6 +86,9 @@ func (ui *UI) LoadBootROM(bootRom string) (err error) {
 	}
 
 	ui.GameBoy.LoadBootROM(data)
+	if ui.player2 != nil {
+		ui.player2.LoadBootROM(data)
+	}
 	return
 }
 
@@ -55,56 +109,96 @@ func (ui *UI) AskRomPath() (string, error) {
 	return romPath, nil
 }
 
+// ShowError reports an error to the user with a message box and on screen
+func (ui *UI) ShowError(err error) {
+	log.Println("[ERROR]", err)
+	ui.debugString = err.Error()
+	ui.debugStringTimer = 180
+
+	dialog.Message("%s", err).Title("Error").Error()
+}
+
 func (ui *UI) LoadROM(romPath string) error {
-	// Open the ROM file
-	cartridgeData, err := os.ReadFile(romPath)
+	rom, err := ui.loadCartridge(romPath)
 	if err != nil {
 		return err
 	}
+	ui.GameBoy.Load(rom)
+	ui.palette = gameBoyPalette(ui.GameBoy)
+
+	ui.gameTitle = rom.Header().Title
+	ui.fileName = romPath
 
-	// Open the SAV file
-	savFile := getSavFileName(romPath)
-	savData, err := os.ReadFile(savFile)
+	// The Super Game Boy screen is larger
+	ebiten.SetWindowSize(ui.Layout(0, 0))
+
+	if ui.autosaver != nil {
+		ui.autosaver.Close()
+	}
+	ui.autosaver = savefile.NewAutosaver(savefile.Path(romPath), ui.autosaveDelay, ui.saveBackups)
+
+	return nil
+}
+
+// loadCartridge reads the ROM and its save
+func (ui *UI) loadCartridge(romPath string) (cartridge.Cartridge, error) {
+	return savefile.LoadCartridge(romPath, ui.clock, ui.multicart, ui.rtcMode)
+}
+
+// gameBoyPalette returns the palette of the colors of the emulated model
+func gameBoyPalette(gb *gameboy.GameBoy) palette.Palette {
+	if gb.EmulationModel == gameboy.DMG {
+		return palette.DMG{}
+	}
+	// CGB and SGB colors are RGB555
+	return palette.CGB{}
+}
+
+func (ui *UI) SetModel(model string) error {
+	m, err := gameboy.ParseModel(model)
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			savData = nil
-		} else {
-			return err
-		}
+		return err
 	}
 
-	rom := cartridge.NewCartridge(cartridgeData, savData)
-	ui.GameBoy.Load(rom)
+	ui.GameBoy.Model = m
+	return nil
+}
 
-	if ui.GameBoy.EmulationModel == gameboy.DMG {
-		ui.palette = theme.DMGPalette{}
-	} else {
-		ui.palette = theme.CGBPalette{}
+// SetMulticartMode overrides the MBC1M detection of the next loaded ROMs (auto, on, off)
+func (ui *UI) SetMulticartMode(mode string) error {
+	m, err := cartridge.ParseMulticartMode(mode)
+	if err != nil {
+		return err
 	}
 
-	ui.gameTitle = rom.Header().Title
-	ui.fileName = romPath
+	ui.multicart = m
+	return nil
+}
 
+// SetClock sets the clock of the cartridge RTC of the next loaded ROMs (see cartridge.ParseClock)
+func (ui *UI) SetClock(clock string) error {
+	c, err := cartridge.ParseClock(clock)
+	if err != nil {
+		return err
+	}
+
+	ui.clock = c
 	return nil
 }
 
-func (ui *UI) SetModel(model string) error {
-	switch model {
-	case "auto":
-		ui.GameBoy.Model = gameboy.Auto
-	case "dmg":
-		ui.GameBoy.Model = gameboy.DMG
-	case "cgb":
-		ui.GameBoy.Model = gameboy.CGB
-	default:
-		return fmt.Errorf("invalid model type: %s", model)
+// SetRTCMode sets how the cartridge RTC of the next loaded ROMs advances (emulated, wall)
+func (ui *UI) SetRTCMode(mode string) error {
+	m, err := cartridge.ParseRTCMode(mode)
+	if err != nil {
+		return err
 	}
 
+	ui.rtcMode = m
 	return nil
 }
 
-func getSavFileName(romPath string) string {
+func getStateFileName(romPath string) string {
 	// Remove gb extension
-	savFile := romPath[:len(romPath)-len(filepath.Ext(romPath))]
-	return savFile + ".sav"
+	stateFile := romPath[:len(romPath)-len(filepath.Ext(romPath))]
+	return stateFile + ".state"
 }
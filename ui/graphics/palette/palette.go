@@ -0,0 +1,12 @@
+// Package palette converts the colors produced by the PPU to RGB colors for the frontend,
+// the conversion itself is done by the emulator core
+package palette
+
+import "github.com/danielecanzoneri/lucky-boy/gameboy/palette"
+
+type (
+	Palette  = palette.Palette
+	DMG      = palette.DMG
+	CGB      = palette.CGB
+	CGBColor = palette.CGBColor
+)
@@ -0,0 +1,62 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/infrared"
+)
+
+// SetInfrared points the IR port at a device: none, noise[=SEED] (ambient light flashes) or
+// player2 (the IR ports of the two players face each other)
+func (ui *UI) SetInfrared(name string) error {
+	if name == "player2" {
+		if ui.lockstep == nil {
+			return fmt.Errorf("player 2 not running")
+		}
+		ui.lockstep.ConnectInfrared()
+		return nil
+	}
+
+	device, err := infrared.ParseDevice(name)
+	if err != nil {
+		return err
+	}
+	ui.GameBoy.SetInfraredDevice(device)
+	return nil
+}
+
+// ListenInfrared waits for another emulator connecting its IR port to addr (host:port)
+func (ui *UI) ListenInfrared(addr string) error {
+	link, err := infrared.ListenLink(addr)
+	if err != nil {
+		return err
+	}
+
+	ui.irLink = link
+	ui.GameBoy.SetInfraredDevice(link)
+	return nil
+}
+
+// ConnectInfrared points the IR port at another emulator listening on addr (host:port),
+// retrying until it is reachable
+func (ui *UI) ConnectInfrared(addr string) {
+	ui.irLink = infrared.DialLink(addr)
+	ui.GameBoy.SetInfraredDevice(ui.irLink)
+}
+
+// updateInfraredState notifies when the other emulator facing the IR port connects or leaves
+func (ui *UI) updateInfraredState() {
+	if ui.irLink == nil {
+		return
+	}
+
+	if connected := ui.irLink.Connected(); connected != ui.irLinkConnected {
+		ui.irLinkConnected = connected
+		if connected {
+			ui.debugString = "Infrared connected"
+		} else {
+			ui.debugString = "Infrared disconnected"
+		}
+		ui.debugStringTimer = 120
+	}
+}
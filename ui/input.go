@@ -1,16 +1,21 @@
 package ui
 
 import (
+	"errors"
 	"log"
 
 	"github.com/danielecanzoneri/lucky-boy/gameboy/joypad"
 	"github.com/danielecanzoneri/lucky-boy/ui/debugger"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/sqweek/dialog"
 )
 
 // ebitenInputProvider implements joypad.InputProvider using ebiten
-type ebitenInputProvider struct{}
+type ebitenInputProvider struct {
+	// Keys are read only while the Game Boy has the focus (two players share the keyboard)
+	focused func() bool
+}
 
 var buttonsKeyMapping = map[joypad.Key]ebiten.Key{
 	joypad.KeyStart:  ebiten.KeyX,
@@ -27,6 +32,10 @@ var dPadKeyMapping = map[joypad.Key]ebiten.Key{
 }
 
 func (p *ebitenInputProvider) IsKeyPressed(key joypad.Key) bool {
+	if !p.focused() {
+		return false
+	}
+
 	// Check buttons mapping
 	if ebitenKey, ok := buttonsKeyMapping[key]; ok {
 		return ebiten.IsKeyPressed(ebitenKey)
@@ -38,6 +47,40 @@ func (p *ebitenInputProvider) IsKeyPressed(key joypad.Key) bool {
 	return false
 }
 
+// ebitenTiltProvider implements cartridge.TiltProvider using the keyboard (I, J, K, L)
+// or the left stick of the first gamepad
+type ebitenTiltProvider struct {
+	gamepads []ebiten.GamepadID
+}
+
+// Tilt applied while a key is pressed (in g)
+const keyboardTilt = 0.5
+
+func (p *ebitenTiltProvider) Tilt() (x, y float64) {
+	p.gamepads = ebiten.AppendGamepadIDs(p.gamepads[:0])
+	for _, id := range p.gamepads {
+		if ebiten.IsStandardGamepadLayoutAvailable(id) {
+			x = ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickHorizontal)
+			y = ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickVertical)
+			break
+		}
+	}
+
+	if ebiten.IsKeyPressed(ebiten.KeyJ) {
+		x = -keyboardTilt
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyL) {
+		x = keyboardTilt
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyI) {
+		y = -keyboardTilt
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyK) {
+		y = keyboardTilt
+	}
+	return x, y
+}
+
 func (ui *UI) handleInput() {
 	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
 		ui.ToggleDebugger()
@@ -54,20 +97,51 @@ func (ui *UI) handleInput() {
 		// Save game before switching
 		ui.Save()
 
+		// On error keep running the current game
 		romPath, err := ui.AskRomPath()
-		if err == nil {
-			err = ui.LoadROM(romPath)
+		switch {
+		case errors.Is(err, dialog.ErrCancelled):
+		case err != nil:
+			ui.ShowError(err)
+		default:
+			if err = ui.LoadROM(romPath); err != nil {
+				ui.ShowError(err)
+			} else {
+				ui.GameBoy.Reset()
+			}
 		}
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		ui.GameBoy.Reset()
 
 		// Start running
 		ui.Paused = false
 	}
 
+	// F5 to save state, F7 to load it
+	if inpututil.IsKeyJustPressed(ebiten.KeyF5) {
+		if err := ui.SaveState(); err != nil {
+			log.Println("error saving state:", err)
+			ui.debugString = "Save state failed"
+		} else {
+			ui.debugString = "State saved"
+		}
+		ui.debugStringTimer = 60
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF7) {
+		if err := ui.LoadState(); err != nil {
+			log.Println("error loading state:", err)
+			ui.debugString = "Load state failed"
+		} else {
+			ui.debugString = "State loaded"
+		}
+		ui.debugStringTimer = 60
+	}
+
+	ui.updatePlayerFocus()
+
+	// F6 to plug the next serial device
+	if inpututil.IsKeyJustPressed(ebiten.KeyF6) {
+		ui.nextSerialDevice()
+	}
+
 	ui.handleAudioToggle()
 
 	// Handle debugger input
@@ -0,0 +1,69 @@
+package ui
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savefile"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// SetPlayer2 runs a second Game Boy with the ROM, linked to the first one by a cable. The two
+// Game Boys run in lockstep and are shown side by side, Tab moves the input to the other one.
+// If both players use the same ROM file, the game of player 2 is not saved.
+func (ui *UI) SetPlayer2(romPath string) error {
+	rom, err := ui.loadCartridge(romPath)
+	if err != nil {
+		return err
+	}
+
+	// Audio is played only for player 1
+	gb := gameboy.New(nil, sampleRate)
+	gb.Model = ui.GameBoy.Model
+	gb.SetInputProvider(&ebitenInputProvider{focused: func() bool { return ui.focus == 1 }})
+	gb.SetTiltProvider(&ebitenTiltProvider{})
+	gb.Load(rom)
+
+	ui.player2 = gb
+	ui.player2Palette = gameBoyPalette(gb)
+	ui.lockstep = gameboy.NewLockstep(ui.GameBoy, gb)
+	ui.serialDevice = "player2"
+
+	if filepath.Clean(romPath) == filepath.Clean(ui.fileName) {
+		log.Println("[WARN] both players use the same ROM, the game of player 2 is not saved")
+	} else {
+		ui.player2Autosaver = savefile.NewAutosaver(savefile.Path(romPath), ui.autosaveDelay, ui.saveBackups)
+	}
+
+	ebiten.SetWindowSize(ui.Layout(0, 0))
+	return nil
+}
+
+// step runs the next instruction of the Game Boys
+func (ui *UI) step() {
+	ui.emulation.Lock()
+	defer ui.emulation.Unlock()
+
+	if ui.lockstep != nil {
+		ui.lockstep.Step()
+	} else {
+		ui.GameBoy.Step()
+	}
+}
+
+// updatePlayerFocus moves the input to the other player when Tab is pressed
+func (ui *UI) updatePlayerFocus() {
+	if ui.player2 == nil || !inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+		return
+	}
+
+	ui.focus ^= 1
+	if ui.focus == 0 {
+		ui.debugString = "Player 1"
+	} else {
+		ui.debugString = "Player 2"
+	}
+	ui.debugStringTimer = 60
+}
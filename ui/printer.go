@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/printer"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	// The preview is shown for 5 seconds after printing, P keeps it visible
+	printerPreviewFrames = 5 * 60
+	// Size of the printed pixels compared to the game pixels
+	printerPreviewScale  = 0.5
+	printerPreviewMargin = 8
+)
+
+var printerPreviewBackground = color.RGBA{R: 40, G: 40, B: 40, A: 255}
+
+// updatePrinterPreview loads the paper printed since the last frame
+func (ui *UI) updatePrinterPreview() {
+	if ui.printer == nil {
+		return
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		ui.printerPreview = !ui.printerPreview
+		ui.printerPreviewTimer = 0
+	}
+
+	if version := ui.printer.Version(); version != ui.printerVersion {
+		ui.printerVersion = version
+		if strip, _ := ui.printer.Strip(); strip != nil {
+			if ui.printerImage != nil {
+				ui.printerImage.Deallocate()
+			}
+			ui.printerImage = ebiten.NewImageFromImage(strip)
+		}
+		ui.printerPreviewTimer = printerPreviewFrames
+	}
+
+	if ui.printerPreviewTimer > 0 {
+		ui.printerPreviewTimer--
+	}
+}
+
+// drawPrinterPreview shows the paper coming out of the printer in the top right corner:
+// if the strip is longer than the screen, only its last rows are visible
+func (ui *UI) drawPrinterPreview(screen *ebiten.Image) {
+	if ui.printerImage == nil || (!ui.printerPreview && ui.printerPreviewTimer == 0) {
+		return
+	}
+
+	scale := printerPreviewScale * Scale
+	bounds := screen.Bounds()
+	maxRows := int(float64(bounds.Dy()-2*printerPreviewMargin) / scale)
+
+	paper := ui.printerImage
+	if rows := paper.Bounds().Dy(); rows > maxRows {
+		paper = paper.SubImage(image.Rect(0, rows-maxRows, printer.Width, rows)).(*ebiten.Image)
+	}
+
+	width := int(printer.Width * scale)
+	height := int(float64(paper.Bounds().Dy()) * scale)
+	x := bounds.Dx() - width - printerPreviewMargin
+	y := printerPreviewMargin
+	screen.SubImage(image.Rect(x-2, y-2, x+width+2, y+height+2)).(*ebiten.Image).Fill(printerPreviewBackground)
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(0, -float64(paper.Bounds().Min.Y))
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate(float64(x), float64(y))
+	screen.DrawImage(paper, op)
+}
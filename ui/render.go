@@ -9,6 +9,8 @@ import (
 
 	"github.com/danielecanzoneri/lucky-boy/gameboy"
 	"github.com/danielecanzoneri/lucky-boy/gameboy/ppu"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/sgb"
+	"github.com/danielecanzoneri/lucky-boy/ui/graphics/palette"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 )
@@ -17,12 +19,18 @@ const (
 	Scale = 3
 )
 
-var (
+// screenImages are the images where the screen of a Game Boy is drawn
+type screenImages struct {
 	// Original palette
-	frameImage *ebiten.Image
+	frame *ebiten.Image
 	// After shader image
-	shaderImage *ebiten.Image
-)
+	shader *ebiten.Image
+	// Super Game Boy screen with the border
+	sgb *ebiten.Image
+}
+
+// Screens of player 1 and player 2
+var screens [2]screenImages
 
 //go:embed graphics/gbc-shader.kage
 var shaderData []byte
@@ -68,8 +76,13 @@ func (ui *UI) initRenderer(useShader bool) {
 	ebiten.SetWindowClosingHandled(true)
 
 	// Create a single image for the entire frame
-	frameImage = ebiten.NewImage(ppu.FrameWidth, ppu.FrameHeight)
-	shaderImage = ebiten.NewImage(ppu.FrameWidth, ppu.FrameHeight)
+	for i := range screens {
+		screens[i] = screenImages{
+			frame:  ebiten.NewImage(ppu.FrameWidth, ppu.FrameHeight),
+			shader: ebiten.NewImage(ppu.FrameWidth, ppu.FrameHeight),
+			sgb:    ebiten.NewImage(sgb.ScreenWidth, sgb.ScreenHeight),
+		}
+	}
 
 	// Initial window size without the debug panel
 	screenWidth, screenHeight := ui.Layout(0, 0)
@@ -102,13 +115,18 @@ func (ui *UI) Update() error {
 	//	ui.Paused = false
 	//}
 
-	// If closing, save game
+	// If closing, save game and unplug the serial device to close its log file
 	if ebiten.IsWindowBeingClosed() {
 		ui.Save()
+		ui.plugSerialDevice(nil)
 		return ebiten.Termination
 	}
 
 	ui.handleInput()
+	ui.updateRumble()
+	ui.updatePrinterPreview()
+	ui.updateLinkState()
+	ui.updateInfraredState()
 
 	if ui.debugger.Active {
 		ebiten.SetWindowTitle(ui.gameTitle + " (debugging)")
@@ -120,25 +138,23 @@ func (ui *UI) Update() error {
 	}
 }
 
-func (ui *UI) applyShader(frame *ebiten.Image) *ebiten.Image {
-	if ui.Shader != nil && ui.GameBoy.Model == gameboy.CGB {
-		ui.shaderOpts.Images[0] = frame
-		shaderImage.DrawRectShader(
+func (ui *UI) applyShader(gb *gameboy.GameBoy, images *screenImages) *ebiten.Image {
+	if ui.Shader != nil && gb.Model == gameboy.CGB {
+		ui.shaderOpts.Images[0] = images.frame
+		images.shader.DrawRectShader(
 			ppu.FrameWidth, ppu.FrameHeight,
 			ui.Shader, ui.shaderOpts,
 		)
-		return shaderImage
+		return images.shader
 	} else {
-		return frame
+		return images.frame
 	}
 }
 
-func (ui *UI) Draw(screen *ebiten.Image) {
-	// Update the frame image with the current frame in the PPU
-	frameBuffer := ui.GameBoy.PPU.GetFrame()
-
+// framePixels converts the colors of a frame to RGBA pixels
+func (ui *UI) framePixels(p palette.Palette, width, height int, colorAt func(x, y int) uint16) []byte {
 	// Reuse pixel buffer to avoid allocations (RGBA = 4 bytes per pixel)
-	pixelBufferSize := ppu.FrameWidth * ppu.FrameHeight * 4
+	pixelBufferSize := width * height * 4
 	if cap(ui.pixelBuffer) < pixelBufferSize {
 		ui.pixelBuffer = make([]byte, pixelBufferSize)
 	}
@@ -146,49 +162,106 @@ func (ui *UI) Draw(screen *ebiten.Image) {
 
 	// Convert frame buffer to RGBA pixels in one pass
 	// Direct color conversion avoids RGBAModel.Convert overhead
-	for y := range ppu.FrameHeight {
-		for x := range ppu.FrameWidth {
-			colorId := frameBuffer[y][x]
-			c := ui.palette.Get(colorId)
+	for y := range height {
+		for x := range width {
+			c := p.Get(colorAt(x, y))
 
 			// Direct conversion to RGBA (16 bit)
 			r, g, b, a := c.RGBA()
 
-			idx := (y*ppu.FrameWidth + x) * 4
+			idx := (y*width + x) * 4
 			pixels[idx] = uint8(r >> 8)
 			pixels[idx+1] = uint8(g >> 8)
 			pixels[idx+2] = uint8(b >> 8)
 			pixels[idx+3] = uint8(a >> 8)
 		}
 	}
+	return pixels
+}
+
+// drawGameBoy draws the screen of a Game Boy and returns it, together with the part showing
+// the Game Boy screen (without the Super Game Boy border)
+func (ui *UI) drawGameBoy(gb *gameboy.GameBoy, images *screenImages, p palette.Palette) (imageToDraw, gameScreen *ebiten.Image) {
+	if s := gb.SGB; s != nil {
+		// Super Game Boy screen with the border, the debugger shows only the Game Boy screen
+		frame := s.GetFrame()
+		images.sgb.WritePixels(ui.framePixels(p, sgb.ScreenWidth, sgb.ScreenHeight, func(x, y int) uint16 {
+			return frame[y][x]
+		}))
 
-	// Write all pixels at once
-	frameImage.WritePixels(pixels)
+		return images.sgb, images.sgb.SubImage(image.Rect(
+			sgb.ScreenX, sgb.ScreenY, sgb.ScreenX+ppu.FrameWidth, sgb.ScreenY+ppu.FrameHeight,
+		)).(*ebiten.Image)
+	}
+
+	// Update the frame image with the current frame in the PPU
+	frameBuffer := gb.PPU.GetFrame()
+	images.frame.WritePixels(ui.framePixels(p, ppu.FrameWidth, ppu.FrameHeight, func(x, y int) uint16 {
+		return frameBuffer[y][x]
+	}))
 
 	// Apply shader
-	imageToDraw := ui.applyShader(frameImage)
+	imageToDraw = ui.applyShader(gb, images)
+	return imageToDraw, imageToDraw
+}
+
+func (ui *UI) Draw(screen *ebiten.Image) {
+	imageToDraw, gameScreen := ui.drawGameBoy(ui.GameBoy, &screens[0], ui.palette)
 
 	if ui.debugger.Active {
-		ui.debugger.Draw(screen, imageToDraw)
+		ui.debugger.Draw(screen, gameScreen)
 		return
 	}
 
 	// Draw the entire frame at once with scaling
 	op := &ebiten.DrawImageOptions{}
 	op.GeoM.Scale(Scale, Scale)
+	op.GeoM.Translate(ui.rumbleOffset(), 0)
+	ui.dimUnfocused(op, 0)
 	screen.DrawImage(imageToDraw, op)
 
+	// Player 2 on the right
+	if ui.player2 != nil {
+		image2, _ := ui.drawGameBoy(ui.player2, &screens[1], ui.player2Palette)
+		op = &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(Scale, Scale)
+		op.GeoM.Translate(float64(Scale*imageToDraw.Bounds().Dx()), 0)
+		ui.dimUnfocused(op, 1)
+		screen.DrawImage(image2, op)
+	}
+	ui.drawPrinterPreview(screen)
+
 	if ui.debugStringTimer > 0 {
 		ebitenutil.DebugPrint(screen, ui.debugString)
 		ui.debugStringTimer--
 	}
 }
 
+// dimUnfocused darkens the screen of the player without the input focus
+func (ui *UI) dimUnfocused(op *ebiten.DrawImageOptions, player int) {
+	if ui.player2 != nil && ui.focus != player {
+		op.ColorScale.Scale(0.6, 0.6, 0.6, 1)
+	}
+}
+
 func (ui *UI) Layout(_, _ int) (int, int) {
 	// Adjust the layout based on whether the debugger is visible
 	if ui.debugger.Active {
 		return ui.debugger.Layout(0, 0)
-	} else {
-		return Scale * ppu.FrameWidth, Scale * ppu.FrameHeight
 	}
+
+	width, height := screenSize(ui.GameBoy)
+	if ui.player2 != nil {
+		width2, height2 := screenSize(ui.player2)
+		width, height = width+width2, max(height, height2)
+	}
+	return width, height
+}
+
+// screenSize returns the size of the screen of a Game Boy in the window
+func screenSize(gb *gameboy.GameBoy) (int, int) {
+	if gb.SGB != nil {
+		return Scale * sgb.ScreenWidth, Scale * sgb.ScreenHeight
+	}
+	return Scale * ppu.FrameWidth, Scale * ppu.FrameHeight
 }
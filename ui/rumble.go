@@ -0,0 +1,49 @@
+package ui
+
+import (
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Vibration lasts a bit more than a frame, so that it is continuous while the motor runs
+const rumbleDuration = 2 * time.Second / 60
+
+// Maximum screen shake (in game pixels) when no gamepad can vibrate
+const rumbleShake = 1.5
+
+// updateRumble drives the gamepads vibration with the cartridge rumble motor
+func (ui *UI) updateRumble() {
+	ui.rumble = 0
+	if ui.Paused || (ui.debugger.Active && !ui.debugger.Running) {
+		return
+	}
+
+	ui.rumble = ui.GameBoy.RumbleIntensity()
+	if ui.rumble == 0 {
+		return
+	}
+
+	ui.gamepads = ebiten.AppendGamepadIDs(ui.gamepads[:0])
+	for _, id := range ui.gamepads {
+		ebiten.VibrateGamepad(id, &ebiten.VibrateGamepadOptions{
+			Duration:        rumbleDuration,
+			StrongMagnitude: ui.rumble,
+			WeakMagnitude:   ui.rumble,
+		})
+	}
+	ui.rumbleFrame++
+}
+
+// rumbleOffset returns how much the screen is shaken, used as fallback when there are no gamepads
+func (ui *UI) rumbleOffset() float64 {
+	if ui.rumble == 0 || len(ui.gamepads) > 0 {
+		return 0
+	}
+
+	offset := ui.rumble * rumbleShake * Scale
+	if ui.rumbleFrame%2 == 0 {
+		return -offset
+	}
+	return offset
+}
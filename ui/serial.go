@@ -0,0 +1,103 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/danielecanzoneri/lucky-boy/gameboy/printer"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/serial"
+)
+
+// Devices plugged in turn with F6, the link cable is added when it is connected, the DMG-07 when
+// connected to a hub and the cable to player 2 (or a DMG-07 shared with it) in two players mode
+var serialDeviceNames = []string{"none", "loopback", "log", "printer"}
+
+// SetSerialDevice plugs a device into the serial port: none, loopback, log[=FILE] (stdout if no file
+// is given) or printer[=DIR] (the printed images are saved in DIR)
+func (ui *UI) SetSerialDevice(name string) error {
+	kind, arg, _ := strings.Cut(name, "=")
+
+	var device serial.Device
+	switch kind {
+	case "link":
+		if ui.link == nil {
+			return fmt.Errorf("link cable not connected")
+		}
+		device = ui.link
+
+	case "player2":
+		if ui.lockstep == nil {
+			return fmt.Errorf("player 2 not running")
+		}
+		ui.lockstep.Connect()
+		ui.serialDevice = kind
+		return nil
+
+	case "dmg07":
+		switch {
+		case ui.hubClient != nil:
+			device = ui.hubClient
+		case ui.lockstep != nil:
+			ui.lockstep.ConnectAdapter()
+			ui.serialDevice = kind
+			return nil
+		default:
+			return fmt.Errorf("DMG-07 not connected")
+		}
+
+	case "printer":
+		ui.printer = printer.New(arg)
+		device = serial.Bytes(ui.printer)
+
+	default:
+		var err error
+		if device, err = serial.ParseDevice(name); err != nil {
+			return err
+		}
+	}
+
+	ui.plugSerialDevice(device)
+	ui.serialDevice = kind
+	return nil
+}
+
+// plugSerialDevice plugs a device between two instructions, so the previous one can be closed
+func (ui *UI) plugSerialDevice(device serial.Device) {
+	ui.emulation.Lock()
+	defer ui.emulation.Unlock()
+	ui.GameBoy.SetSerialDevice(device)
+}
+
+// nextSerialDevice plugs the next device of the list into the serial port
+func (ui *UI) nextSerialDevice() {
+	names := serialDeviceNames
+	if ui.link != nil {
+		names = append(names, "link")
+	}
+	if ui.lockstep != nil {
+		names = append(names, "player2")
+	}
+	if ui.hubClient != nil || ui.lockstep != nil {
+		names = append(names, "dmg07")
+	}
+
+	next := names[0]
+	for i, name := range names {
+		if name == ui.serialDevice {
+			next = names[(i+1)%len(names)]
+		}
+	}
+
+	// The printer keeps its paper
+	if next == "printer" && ui.printer != nil {
+		ui.plugSerialDevice(serial.Bytes(ui.printer))
+		ui.serialDevice = next
+	} else if err := ui.SetSerialDevice(next); err != nil {
+		log.Println("[WARN] serial device:", err)
+		return
+	}
+
+	ui.debugString = "Serial: " + next
+	ui.debugStringTimer = 60
+}
@@ -1,12 +1,21 @@
 package ui
 
 import (
-	theme "github.com/danielecanzoneri/lucky-boy/ui/graphics"
+	"fmt"
+	"github.com/danielecanzoneri/lucky-boy/ui/graphics/palette"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/danielecanzoneri/lucky-boy/ui/debugger"
 
 	"github.com/danielecanzoneri/lucky-boy/gameboy"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/cartridge"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/dmg07"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/infrared"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/printer"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/savefile"
+	"github.com/danielecanzoneri/lucky-boy/gameboy/serial"
 	"github.com/ebitengine/oto/v3"
 	"github.com/hajimehoshi/ebiten/v2"
 )
@@ -16,8 +25,22 @@ type UI struct {
 	gameTitle string
 	fileName  string
 
+	// MBC1M detection override
+	multicart cartridge.MulticartMode
+	// Battery save
+	autosaver     *savefile.Autosaver
+	autosaveDelay time.Duration
+	saveBackups   int
+
+	// Time source and mode of the cartridge RTC
+	clock   cartridge.Clock
+	rtcMode cartridge.RTCMode
+
 	// When true, stop emulation
 	Paused bool
+	// Held while the Game Boys execute an instruction on the audio goroutine,
+	// other goroutines lock it to access them between instructions
+	emulation sync.Mutex
 
 	// Audio player
 	audioBuffer chan float32
@@ -26,11 +49,41 @@ type UI struct {
 	// Turbo mode
 	turbo bool
 
+	// Rumble motor intensity in the last frame
+	rumble      float64
+	rumbleFrame uint
+	gamepads    []ebiten.GamepadID
+
+	// Second Game Boy linked to the first one, the input goes to the focused player (0 or 1)
+	player2          *gameboy.GameBoy
+	player2Palette   palette.Palette
+	player2Autosaver *savefile.Autosaver
+	lockstep         *gameboy.Lockstep
+	focus            int
+
+	// Device plugged into the serial port (F6 plugs the next one)
+	serialDevice  string
+	link          *serial.Link
+	linkConnected bool
+	hubClient     *dmg07.Client
+	hubPlayer     int
+
+	// Other emulator facing the IR port
+	irLink          *infrared.Link
+	irLinkConnected bool
+
+	// Game Boy Printer and preview of the printed paper
+	printer             *printer.Printer
+	printerImage        *ebiten.Image
+	printerVersion      uint
+	printerPreview      bool
+	printerPreviewTimer int
+
 	debugString      string
 	debugStringTimer uint
 
 	// Color Palette
-	palette theme.Palette
+	palette palette.Palette
 
 	// CGB color correction shader
 	Shader     *ebiten.Shader
@@ -62,9 +115,19 @@ func New(useShader bool) (*UI, error) {
 
 	ui.audioPlayer = player
 
+	// Notify illegal opcodes and break into the debugger
+	gb.IllegalOpcodeCallback = func(addr uint16, opcode uint8) {
+		ui.debugString = fmt.Sprintf("Illegal opcode $%02X at $%04X, CPU locked", opcode, addr)
+		ui.debugStringTimer = 300
+		if ui.debugger.Active {
+			ui.debugger.Stop()
+		}
+	}
+
 	// Set up input provider for joypad
-	inputProvider := &ebitenInputProvider{}
+	inputProvider := &ebitenInputProvider{focused: func() bool { return ui.focus == 0 }}
 	gb.SetInputProvider(inputProvider)
+	gb.SetTiltProvider(&ebitenTiltProvider{})
 
 	// Initialize the renderer
 	ui.initRenderer(useShader)